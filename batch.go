@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/postprocess"
+	"youtube_to_mp3/pkg/validator"
+)
+
+// batchStatus batch 條目的處理狀態
+type batchStatus string
+
+const (
+	statusSuccess batchStatus = "成功"
+	statusFailed  batchStatus = "失敗"
+	statusSkipped batchStatus = "跳過"
+)
+
+// batchItem batch 中的一個條目
+type batchItem struct {
+	line   int // 在列表文件中的行號
+	url    string
+	status batchStatus
+	detail string // 輸出文件或失敗/跳過原因
+}
+
+// readBatch 逐行讀取 URL，忽略空行和 # ; ] 開頭的註釋行（與 yt-dlp --batch-file 一致）
+// 重複和格式錯誤的 URL 標記為跳過
+func readBatch(r io.Reader) ([]*batchItem, error) {
+	var items []*batchItem
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], "#;]") {
+			continue
+		}
+
+		item := &batchItem{line: lineNo, url: line}
+		switch {
+		case !isURL(line):
+			item.status = statusSkipped
+			item.detail = "不是有效的 URL"
+		case seen[line]:
+			item.status = statusSkipped
+			item.detail = "重複的 URL"
+		}
+		seen[line] = true
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// isURL 判斷是否為 http(s) URL
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// runBatch 執行 batch 子命令
+func runBatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, code, ok := parseOrExit(parseBatchArgs, args, stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	input := stdin
+	if opts.batchFile != "-" {
+		file, err := os.Open(opts.batchFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "錯誤: 無法打開 URL 列表: %v\n", err)
+			return exitUsage
+		}
+		defer file.Close()
+		input = file
+	}
+
+	items, err := readBatch(input)
+	if err != nil {
+		fmt.Fprintf(stderr, "錯誤: 讀取 URL 列表失敗: %v\n", err)
+		return exitUsage
+	}
+
+	if !checkDependencies(validator.ModeDownload, opts.config, stderr) {
+		return exitDependency
+	}
+	arc, err := openArchive(opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "錯誤: %v\n", err)
+		return exitFailure
+	}
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	// 所有 worker 共用配置和下載存檔，各任務的 yt-dlp 輸出帶序號前綴
+	output := stdout
+	if opts.quiet {
+		output = io.Discard
+	}
+	factory := func(stdout, stderr io.Writer) downloader.Downloader {
+		dl := downloader.NewYtDlpDownloader(opts.config, nil)
+		dl.SetOutput(stdout, stderr)
+		dl.SetArchive(arc)
+		dl.SetPreflight(preflight(opts.config))
+		return postprocess.New(dl, postprocess.Steps(opts.config, nil)...)
+	}
+	if err := processBatch(ctx, factory, opts.jobs, items, stdout, output); err != nil {
+		fmt.Fprintf(stderr, "\n%v\n", err)
+		printSummary(stdout, items)
+		return exitInterrupted
+	}
+
+	printSummary(stdout, items)
+	return batchExitCode(items)
+}
+
+// processBatch 用 jobs 個 worker 下載所有未跳過的條目，結果按列表順序輸出到 stdout
+// yt-dlp 的輸出寫入 output，被中斷時返回取消錯誤
+func processBatch(ctx context.Context, factory downloader.DownloaderFactory, jobs int,
+	items []*batchItem, stdout, output io.Writer) error {
+	var (
+		pending []*batchItem
+		urls    []string
+	)
+	for _, item := range items {
+		if item.status != statusSkipped {
+			pending = append(pending, item)
+			urls = append(urls, item.url)
+		}
+	}
+
+	manager := downloader.NewManager(jobs, factory, output)
+	manager.OnResult(func(r downloader.JobResult) {
+		item := pending[r.Index]
+		item.finish(r.Result, r.Err)
+		fmt.Fprintf(stdout, "[%d/%d] %s %s\n", r.Index+1, len(pending), item.status, item.url)
+		if r.Result != nil {
+			for _, warning := range r.Result.AllWarnings() {
+				fmt.Fprintf(stdout, "  警告: %s\n", warning)
+			}
+		}
+	})
+	manager.Run(ctx, urls)
+
+	if err := ctx.Err(); err != nil {
+		return &downloader.CancelledError{URL: "batch", Err: err}
+	}
+	return nil
+}
+
+// finish 根據下載結果設置條目狀態
+func (item *batchItem) finish(result *downloader.Result, err error) {
+	switch {
+	case err != nil:
+		item.status = statusFailed
+		item.detail = err.Error()
+	case result.Skipped:
+		item.status = statusSkipped
+		item.detail = "已在下載存檔中"
+	case len(result.Failed()) > 0:
+		item.status = statusFailed
+		item.detail = fmt.Sprintf("播放列表 %d/%d 項失敗", len(result.Failed()), len(result.Entries))
+	default:
+		item.status = statusSuccess
+		item.detail = strings.Join(result.Files, ", ")
+	}
+}
+
+// printSummary 輸出匯總表
+func printSummary(w io.Writer, items []*batchItem) {
+	counts := make(map[batchStatus]int)
+
+	fmt.Fprintln(w, "\n=== 匯總 ===")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "行號\t狀態\tURL\t詳情")
+	for _, item := range items {
+		if item.status == "" {
+			continue
+		}
+		counts[item.status]++
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", item.line, item.status, item.url, item.detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n成功: %d  失敗: %d  跳過: %d\n",
+		counts[statusSuccess], counts[statusFailed], counts[statusSkipped])
+}
+
+// batchExitCode 全部成功返回 0，全部失敗返回 exitFailure，部分失敗返回 exitPartial
+func batchExitCode(items []*batchItem) int {
+	succeeded, failed := 0, 0
+	for _, item := range items {
+		switch item.status {
+		case statusSuccess:
+			succeeded++
+		case statusFailed:
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return exitOK
+	case succeeded == 0:
+		return exitFailure
+	default:
+		return exitPartial
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+const batchList = `# 我的列表
+https://www.youtube.com/watch?v=a
+
+; 另一種註釋
+https://www.youtube.com/watch?v=b
+not a url
+https://www.youtube.com/watch?v=a
+https://www.youtube.com/watch?v=dead
+`
+
+// sharedFactory 所有任務共用同一個 mock 下載器
+func sharedFactory(mock *mocks.Downloader) downloader.DownloaderFactory {
+	return func(stdout, stderr io.Writer) downloader.Downloader {
+		return mock
+	}
+}
+
+func TestReadBatch(t *testing.T) {
+	items, err := readBatch(strings.NewReader(batchList))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(items) != 5 {
+		t.Fatalf("Expected 5 items, got %d", len(items))
+	}
+
+	expected := []struct {
+		line   int
+		status batchStatus
+	}{
+		{2, ""}, {5, ""}, {6, statusSkipped}, {7, statusSkipped}, {8, ""},
+	}
+	for i, e := range expected {
+		if items[i].line != e.line || items[i].status != e.status {
+			t.Errorf("Item %d: expected line %d status %q, got line %d status %q",
+				i, e.line, e.status, items[i].line, items[i].status)
+		}
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	items, _ := readBatch(strings.NewReader(batchList))
+	mock := &mocks.Downloader{
+		ShouldFailOnURL: "https://www.youtube.com/watch?v=dead",
+		DownloadFunc: func(url string) (*downloader.Result, error) {
+			return &downloader.Result{URL: url, Files: []string{"output/song.mp3"}}, nil
+		},
+	}
+
+	var out bytes.Buffer
+	if err := processBatch(context.Background(), sharedFactory(mock), 2, items, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// 跳過的條目不應被下載
+	if len(mock.URLs()) != 3 {
+		t.Errorf("Expected 3 downloads, got %v", mock.URLs())
+	}
+	// 進度按列表順序輸出
+	if !strings.Contains(out.String(), "[1/3] 成功 https://www.youtube.com/watch?v=a\n[2/3] 成功") {
+		t.Errorf("Expected ordered progress, got: %s", out.String())
+	}
+	if code := batchExitCode(items); code != exitPartial {
+		t.Errorf("Expected exit code %d, got %d", exitPartial, code)
+	}
+
+	out.Reset()
+	printSummary(&out, items)
+	if !strings.Contains(out.String(), "成功: 2  失敗: 1  跳過: 2") {
+		t.Errorf("Unexpected summary: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "mock download error") {
+		t.Errorf("Expected failure reason in summary: %s", out.String())
+	}
+}
+
+func TestProcessBatchCancelled(t *testing.T) {
+	items, _ := readBatch(strings.NewReader(batchList))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := processBatch(ctx, sharedFactory(&mocks.Downloader{}), 1, items, io.Discard, io.Discard)
+
+	var cancelled *downloader.CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Errorf("Expected CancelledError, got: %v", err)
+	}
+}
+
+func TestBatchExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []batchStatus
+		code     int
+	}{
+		{"all success", []batchStatus{statusSuccess, statusSkipped}, exitOK},
+		{"all failed", []batchStatus{statusFailed, statusSkipped}, exitFailure},
+		{"partial", []batchStatus{statusSuccess, statusFailed}, exitPartial},
+		{"empty", nil, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []*batchItem
+			for _, status := range tt.statuses {
+				items = append(items, &batchItem{status: status})
+			}
+			if code := batchExitCode(items); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestRunBatchUsageErrors(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "missing.txt")
+		if code := run([]string{"batch", file}, nil, io.Discard, io.Discard); code != exitUsage {
+			t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+		}
+	})
+
+	t.Run("too many files", func(t *testing.T) {
+		if code := run([]string{"batch", "a.txt", "b.txt"}, nil, io.Discard, io.Discard); code != exitUsage {
+			t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+		}
+	})
+
+	t.Run("reads file argument", func(t *testing.T) {
+		opts, err := parseBatchArgs([]string{"-o", "music", "urls.txt"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.batchFile != "urls.txt" || opts.config.OutputDir != "music" || opts.jobs != 1 {
+			t.Errorf("Unexpected options: %+v", opts)
+		}
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		opts, err := parseBatchArgs([]string{"-j", "4"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.jobs != 4 {
+			t.Errorf("Expected 4 jobs, got %d", opts.jobs)
+		}
+		if _, err := parseBatchArgs([]string{"-jobs", "0"}, io.Discard); err == nil {
+			t.Error("Expected error for 0 jobs")
+		}
+	})
+
+	t.Run("defaults to stdin", func(t *testing.T) {
+		opts, err := parseBatchArgs(nil, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.batchFile != "-" {
+			t.Errorf("Expected batch file '-', got %q", opts.batchFile)
+		}
+	})
+}
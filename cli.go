@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// 退出碼
+const (
+	exitOK          = 0   // 全部成功
+	exitFailure     = 1   // 下載或轉換失敗
+	exitUsage       = 2   // 命令行參數錯誤
+	exitDependency  = 3   // 缺少 yt-dlp/ffmpeg
+	exitPartial     = 4   // batch 中部分 URL 失敗
+	exitInterrupted = 130 // 被 Ctrl+C 中斷
+)
+
+// version 程序版本，發布時通過 -ldflags "-X main.version=..." 注入
+var version = "dev"
+
+// usageError 命令行參數錯誤
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// options 解析後的命令行參數
+type options struct {
+	config      *config.Config
+	loaded      *config.Loaded // 分層加載的配置及每項的來源
+	urls        []string
+	paths       []string // convert 子命令的本地文件或目錄
+	batchFile   string   // batch 子命令的 URL 列表文件，"-" 表示標準輸入
+	jobs        int      // batch 子命令的並發下載數
+	quiet       bool
+	showVersion bool
+	printConfig bool   // 輸出生效的配置後退出
+	importFrom  string // 下載前合併到存檔的 yt-dlp 存檔文件
+	configFile  string // -config 指定的配置文件
+	jsonReport  bool   // doctor 子命令輸出 JSON
+}
+
+// configFlags 命令行參數名到配置項鍵的映射，只有顯式設置的參數會覆蓋配置文件和環境變量
+var configFlags = map[string]string{
+	"output":            "output_dir",
+	"o":                 "output_dir",
+	"preset":            "preset",
+	"format":            "audio_format",
+	"quality":           "audio_quality",
+	"bitrate":           "bitrate",
+	"template":          "output_template",
+	"timeout":           "timeout",
+	"playlist":          "playlist",
+	"playlist-items":    "playlist_items",
+	"playlist-reverse":  "playlist_reverse",
+	"playlist-max":      "playlist_max_items",
+	"playlist-template": "playlist_template",
+	"archive":           "archive_path",
+	"force":             "force",
+	"outputs":           "outputs",
+	"clip":              "clips",
+	"tags":              "tags",
+	"tag-genre":         "tag_genre",
+	"tag-rules":         "tag_rules",
+	"cover":             "cover",
+	"cover-square":      "cover_square",
+	"cover-size":        "cover_size",
+	"cover-jpeg":        "cover_jpeg",
+	"sponsorblock":      "sponsorblock",
+	"sponsorblock-api":  "sponsorblock_api",
+	"trim-silence":      "trim_silence",
+	"silence-threshold": "silence_threshold",
+	"silence-duration":  "silence_duration",
+	"fade-in":           "fade_in",
+	"fade-out":          "fade_out",
+	"max-duration":      "max_duration",
+	"loudness":          "loudness",
+	"loudness-target":   "loudness_target",
+	"loudness-tp":       "loudness_true_peak",
+	"loudness-lra":      "loudness_range",
+	"split-chapters":    "split_chapters",
+	"chapter-playlist":  "chapter_playlist",
+	"ytdlp-path":        "ytdlp_path",
+	"ffmpeg-path":       "ffmpeg_path",
+	"ffprobe-path":      "ffprobe_path",
+	"space-check":       "space_check",
+	"min-free-space":    "min_free_space",
+	"space-estimate":    "space_estimate",
+	"verbose":           "verbose",
+	"v":                 "verbose",
+}
+
+// flagValues 不屬於配置的命令行參數，默認命令和 batch 子命令共用
+type flagValues struct {
+	fs                                       *flag.FlagSet
+	configFile, archiveImport                string
+	clipStart, clipEnd                       string // -start 和 -end，合併到 clips
+	verbose, quiet, showVersion, printConfig bool
+}
+
+// newFlagSet 創建註冊了下載參數的 FlagSet，幫助中顯示的是內置默認值
+func newFlagSet(name string, stderr io.Writer, usage func(fs *flag.FlagSet, w io.Writer)) (*flag.FlagSet, *flagValues) {
+	defaults := config.NewConfig()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs, stderr) }
+
+	fv := &flagValues{fs: fs}
+	fs.String("output", defaults.OutputDir, "輸出目錄")
+	fs.String("o", defaults.OutputDir, "--output 的簡寫")
+	fs.String("preset", "", "輸出格式預設，設置格式、質量和比特率 ("+strings.Join(config.PresetNames(), ", ")+")")
+	fs.String("outputs", "", "一次下載輸出多種格式，例如 \"mp3-320;flac=archive\"，=後為輸出目錄或模板")
+	fs.String("format", defaults.AudioFormat, "音頻格式 (mp3, m4a, aac, opus, vorbis, flac, wav, alac, best)")
+	fs.String("quality", defaults.AudioQuality, "VBR 音頻質量 0-10，0 為最好，只在 -bitrate 為空時使用")
+	fs.String("bitrate", defaults.Bitrate, "固定比特率，例如 320k，設為空字符串使用 VBR")
+	fs.String("template", "", "輸出文件名模板，相對於輸出目錄 (默認 \"%(title)s.%(ext)s\")")
+	fs.Duration("timeout", defaults.Timeout, "單個 URL 的超時時間，例如 10m，0 表示不限制")
+	fs.Bool("playlist", false, "下載整個播放列表或頻道")
+	fs.String("playlist-items", "", "播放列表條目範圍，例如 1-10,15")
+	fs.Bool("playlist-reverse", false, "倒序下載播放列表")
+	fs.Int("playlist-max", 0, "最多下載的播放列表條目數，0 表示不限制")
+	fs.String("playlist-template", defaults.PlaylistTemplate, "播放列表條目的輸出模板，相對於輸出目錄")
+	fs.String("archive", "", "下載存檔路徑，兼容 yt-dlp --download-archive (默認 <輸出目錄>/"+config.DefaultArchiveName+")")
+	fs.StringVar(&fv.archiveImport, "archive-import", "", "下載前把 yt-dlp 存檔文件合併到下載存檔")
+	fs.Bool("force", false, "忽略下載存檔，重新下載已完成的視頻")
+	fs.Bool("tags", defaults.Tags, "下載後寫入標題、藝術家、專輯等標籤，-tags=false 關閉")
+	fs.String("tag-genre", "", "寫入的流派標籤")
+	fs.String("tag-rules", "", "從字段解析標籤的規則，例如 \""+config.ArtistTitleRule+"\"，多條用 ; 分隔，正則中的 ; 寫作 \\;")
+	fs.Bool("cover", false, "下載後把視頻縮略圖嵌入為封面 (mp3, m4a, flac)")
+	fs.Bool("cover-square", false, "把封面居中裁剪為正方形")
+	fs.Int("cover-size", 0, "封面的最大邊長（像素），0 表示不縮放")
+	fs.Bool("cover-jpeg", defaults.CoverJPEG, "把 WebP 縮略圖轉為 JPEG，-cover-jpeg=false 保留原格式")
+	fs.String("clip", "", "只下載的時間範圍，例如 \"12:30-15:45;1:02:00-inf\"，每個範圍輸出一個文件")
+	fs.StringVar(&fv.clipStart, "start", "", "截取的開始時間 (hh:mm:ss、mm:ss 或秒數，負數表示距離結尾)")
+	fs.StringVar(&fv.clipEnd, "end", "", "截取的結束時間，默認到視頻結尾")
+	fs.String("sponsorblock", "", "用 SponsorBlock 數據移除的片段類別，逗號分隔，例如 sponsor,intro,selfpromo")
+	fs.String("sponsorblock-api", defaults.SponsorBlockAPI, "SponsorBlock API 地址")
+	fs.Bool("trim-silence", false, "去掉開頭和結尾的靜音")
+	fs.Float64("silence-threshold", defaults.SilenceThreshold, "低於此音量視為靜音 (dB)")
+	fs.Duration("silence-duration", defaults.SilenceDuration, "持續超過此時長才視為靜音，例如 500ms")
+	fs.Duration("fade-in", 0, "淡入時長，例如 2s，0 表示不淡入")
+	fs.Duration("fade-out", 0, "淡出時長，例如 3s，0 表示不淡出")
+	fs.Duration("max-duration", 0, "最長保留的時長，超出部分截掉，0 表示不限制")
+	fs.String("loudness", "", "響度處理 ("+strings.Join(config.LoudnessModes, ", ")+")，loudnorm 重新編碼，replaygain 只寫標籤")
+	fs.Float64("loudness-target", defaults.LoudnessTarget, "loudnorm 的目標響度 (LUFS)")
+	fs.Float64("loudness-tp", defaults.LoudnessTruePeak, "loudnorm 的最大真峰值 (dBTP)")
+	fs.Float64("loudness-lra", defaults.LoudnessRange, "loudnorm 的目標響度範圍 (LU)")
+	fs.Bool("split-chapters", false, "按視頻章節拆分為多個文件")
+	fs.String("chapter-playlist", "", "拆分後生成的播放列表 ("+strings.Join(config.ChapterPlaylists, ", ")+")")
+	fs.String("ytdlp-path", "", "yt-dlp 可執行文件或所在目錄，默認依次查找 $YTDLP_PATH、程序目錄下的 tools、PATH")
+	fs.String("ffmpeg-path", "", "ffmpeg 可執行文件或所在目錄，默認依次查找 $FFMPEG_PATH、程序目錄下的 tools、PATH")
+	fs.String("ffprobe-path", "", "ffprobe 可執行文件或所在目錄，默認依次查找 $FFPROBE_PATH、程序目錄下的 tools、PATH")
+	fs.String("space-check", defaults.SpaceCheck, "下載前可用空間不足時的處理方式 ("+strings.Join(config.SpaceChecks, ", ")+")")
+	fs.String("min-free-space", config.FormatSize(defaults.MinFreeSpace), "下載後輸出目錄所在分區至少保留的空間，例如 500M、2G")
+	fs.Bool("space-estimate", false, "下載單個視頻前先獲取大小，檢查空間時加上預計需要的空間（多運行一次 yt-dlp）")
+	fs.BoolVar(&fv.verbose, "verbose", false, "顯示 yt-dlp 調試輸出")
+	fs.BoolVar(&fv.verbose, "v", false, "--verbose 的簡寫")
+	fs.BoolVar(&fv.quiet, "quiet", false, "不顯示 yt-dlp 輸出")
+	fs.BoolVar(&fv.quiet, "q", false, "--quiet 的簡寫")
+	fs.StringVar(&fv.configFile, "config", "", "配置文件路徑，代替 $XDG_CONFIG_HOME/"+config.AppDir+"/"+config.FileName)
+	fs.BoolVar(&fv.printConfig, "print-config", false, "輸出生效的配置及每項的來源後退出")
+	fs.BoolVar(&fv.showVersion, "version", false, "顯示版本號")
+
+	return fs, fv
+}
+
+// parseFlags 解析參數並返回位置參數，--help 時返回 flag.ErrHelp，其他錯誤包裝為 usageError
+// flag 包在第一個位置參數處停止解析，所以繼續解析其後的參數，允許 "URL -format m4a" 這樣的順序；
+// "--" 之後的參數都是位置參數
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &usageError{msg: err.Error()}
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// options 合併配置文件、環境變量和命令行參數，校驗後創建配置
+func (fv *flagValues) options() (*options, error) {
+	opts := &options{
+		quiet:       fv.quiet,
+		showVersion: fv.showVersion,
+		printConfig: fv.printConfig,
+		importFrom:  fv.archiveImport,
+		configFile:  fv.configFile,
+	}
+	if fv.showVersion {
+		return opts, nil
+	}
+	if fv.verbose && fv.quiet {
+		return nil, &usageError{msg: "--verbose 和 --quiet 不能同時使用"}
+	}
+
+	flags := make(map[string]string)
+	flagNames := make(map[string]string)
+	fv.fs.Visit(func(f *flag.Flag) {
+		if key, ok := configFlags[f.Name]; ok {
+			flags[key] = f.Value.String()
+			flagNames[key] = f.Name
+		}
+	})
+	if fv.clipStart != "" || fv.clipEnd != "" {
+		// -start 和 -end 是 -clip 的一個範圍
+		clip := fv.clipStart + "-" + fv.clipEnd
+		if fv.clipStart == "" {
+			clip = "0" + clip
+		}
+		if flags["clips"] != "" {
+			clip += ";" + flags["clips"]
+		}
+		flags["clips"] = clip
+		if flagNames["clips"] == "" {
+			flagNames["clips"] = "start"
+		}
+	}
+
+	loaded, err := config.Load(config.LoadOptions{
+		ConfigFile: fv.configFile,
+		Flags:      flags,
+		FlagNames:  flagNames,
+	})
+	if err != nil {
+		return nil, &usageError{msg: err.Error()}
+	}
+	if err := validateConfig(loaded); err != nil {
+		return nil, err
+	}
+
+	cfg := loaded.Config
+	if fv.quiet {
+		// 配置文件中的 verbose 被命令行的 --quiet 覆蓋
+		cfg.Verbose = false
+	}
+	opts.config = cfg
+	opts.loaded = loaded
+	return opts, nil
+}
+
+// validateConfig 校驗合併後的配置，錯誤信息中註明非命令行的來源
+func validateConfig(loaded *config.Loaded) error {
+	err := loaded.Config.Validate()
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	lines := make([]string, len(invalid.Errors))
+	for i, fe := range invalid.Errors {
+		lines[i] = fe.Error()
+		if source := loaded.Sources[fe.Field]; source.Kind != config.SourceFlag {
+			lines[i] += fmt.Sprintf(" (來自 %s)", source)
+		}
+	}
+	return &usageError{msg: "配置無效:\n  " + strings.Join(lines, "\n  ")}
+}
+
+// parseArgs 解析默認命令的參數，--help 時返回 flag.ErrHelp
+func parseArgs(args []string, stderr io.Writer) (*options, error) {
+	fs, fv := newFlagSet("youtube_to_mp3", stderr, printUsage)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := fv.options()
+	if err != nil || opts.showVersion || opts.printConfig {
+		return opts, err
+	}
+
+	opts.urls = positional
+	if len(opts.urls) == 0 {
+		return nil, &usageError{msg: "請提供至少一個 YouTube URL"}
+	}
+	return opts, nil
+}
+
+// parseBatchArgs 解析 batch 子命令的參數，未指定文件時從標準輸入讀取
+func parseBatchArgs(args []string, stderr io.Writer) (*options, error) {
+	fs, fv := newFlagSet("youtube_to_mp3 batch", stderr, printBatchUsage)
+	jobs := fs.Int("jobs", 1, "並發下載數")
+	fs.IntVar(jobs, "j", 1, "--jobs 的簡寫")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := fv.options()
+	if err != nil || opts.showVersion || opts.printConfig {
+		return opts, err
+	}
+
+	if *jobs < 1 {
+		return nil, &usageError{msg: fmt.Sprintf("並發下載數必須大於 0: %d", *jobs)}
+	}
+	opts.jobs = *jobs
+
+	switch len(positional) {
+	case 0:
+		opts.batchFile = "-"
+	case 1:
+		opts.batchFile = positional[0]
+	default:
+		return nil, &usageError{msg: "batch 只接受一個 URL 列表文件"}
+	}
+	return opts, nil
+}
+
+// parseConvertArgs 解析 convert 子命令的參數，下載相關的設置在本地轉換中無效
+func parseConvertArgs(args []string, stderr io.Writer) (*options, error) {
+	fs, fv := newFlagSet("youtube_to_mp3 convert", stderr, printConvertUsage)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := fv.options()
+	if err != nil || opts.showVersion || opts.printConfig {
+		return opts, err
+	}
+
+	cfg := opts.config
+	switch {
+	case opts.importFrom != "":
+		return nil, &usageError{msg: "convert 不使用下載存檔，不支持 -archive-import"}
+	case len(cfg.Clips) > 0:
+		return nil, &usageError{msg: "convert 不支持截取片段 (-clip、-start、-end)"}
+	case len(cfg.Outputs) == 0 && cfg.AudioFormat == "best":
+		return nil, &usageError{msg: "convert 需要指定音頻格式，不支持 -format best"}
+	}
+
+	opts.paths = positional
+	if len(opts.paths) == 0 {
+		return nil, &usageError{msg: "請提供至少一個文件或目錄"}
+	}
+	for _, path := range opts.paths {
+		if isURL(path) {
+			return nil, &usageError{msg: fmt.Sprintf("convert 只處理本地文件，下載 URL 請直接運行 youtube_to_mp3: %s", path)}
+		}
+	}
+	return opts, nil
+}
+
+// parseDoctorArgs 解析 doctor 子命令的參數，配置相關的參數用於檢查對應的輸出目錄和編碼器
+func parseDoctorArgs(args []string, stderr io.Writer) (*options, error) {
+	fs, fv := newFlagSet("youtube_to_mp3 doctor", stderr, printDoctorUsage)
+	jsonReport := fs.Bool("json", false, "以 JSON 格式輸出報告")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := fv.options()
+	if err != nil || opts.showVersion || opts.printConfig {
+		return opts, err
+	}
+	if len(positional) > 0 {
+		return nil, &usageError{msg: "doctor 不接受位置參數"}
+	}
+	opts.jsonReport = *jsonReport
+	return opts, nil
+}
+
+// printUsage 輸出使用說明
+func printUsage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "使用方法: youtube_to_mp3 [選項] <YouTube URL>...")
+	fmt.Fprintln(w, "         youtube_to_mp3 batch [選項] [URL 列表文件|-]")
+	fmt.Fprintln(w, "         youtube_to_mp3 convert [選項] <文件或目錄>...")
+	fmt.Fprintln(w, "         youtube_to_mp3 doctor [-json] [選項]")
+	fmt.Fprintln(w, "範例: youtube_to_mp3 -o music -bitrate 256k https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "選項:")
+	fs.PrintDefaults()
+	printPresets(w)
+}
+
+// printPresets 輸出所有預設
+func printPresets(w io.Writer) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "預設 (-preset):")
+	for _, p := range config.Presets {
+		fmt.Fprintf(w, "  %-10s %s\n", p.Name, p.Description)
+	}
+}
+
+// printBatchUsage 輸出 batch 子命令的使用說明
+func printBatchUsage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "使用方法: youtube_to_mp3 batch [選項] [URL 列表文件|-]")
+	fmt.Fprintln(w, "從文件或標準輸入逐行讀取 URL，# 開頭的行和空行會被忽略")
+	fmt.Fprintln(w, "範例: youtube_to_mp3 batch -o music urls.txt")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "選項:")
+	fs.PrintDefaults()
+	printPresets(w)
+}
+
+// printConvertUsage 輸出 convert 子命令的使用說明
+func printConvertUsage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "使用方法: youtube_to_mp3 convert [選項] <文件或目錄>...")
+	fmt.Fprintln(w, "用 ffmpeg 轉換本地的視頻或音頻文件，目錄會遞歸處理並保留子目錄結構，不需要 yt-dlp")
+	fmt.Fprintln(w, "範例: youtube_to_mp3 convert -o music ~/Videos/concerts")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "選項:")
+	fs.PrintDefaults()
+	printPresets(w)
+}
+
+// printDoctorUsage 輸出 doctor 子命令的使用說明
+func printDoctorUsage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "使用方法: youtube_to_mp3 doctor [-json] [選項]")
+	fmt.Fprintln(w, "檢查 yt-dlp、ffmpeg、ffprobe 的路徑和版本、編碼器和濾鏡、輸出目錄、配置文件和代理設置，")
+	fmt.Fprintln(w, "報告問題時請附上輸出。發現問題時退出碼為 1")
+	fmt.Fprintln(w, "範例: youtube_to_mp3 doctor -o music -format opus")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "選項:")
+	fs.PrintDefaults()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/postprocess"
+	"youtube_to_mp3/pkg/validator"
+)
+
+// runConvert 執行 convert 子命令，只需要 ffmpeg
+func runConvert(args []string, stdout, stderr io.Writer) int {
+	opts, code, ok := parseOrExit(parseConvertArgs, args, stdout, stderr)
+	if !ok {
+		return code
+	}
+	if !checkDependencies(validator.ModeConvert, opts.config, stderr) {
+		return exitDependency
+	}
+
+	cfg := opts.config
+	converter := downloader.NewConverter(cfg, nil)
+	if opts.quiet {
+		converter.SetOutput(io.Discard, stderr)
+	}
+	// 與下載共用標籤、響度等後處理步驟
+	dl := postprocess.New(converter, postprocess.Steps(cfg, nil)...)
+
+	ctx, stop := notifyContext()
+	defer stop()
+
+	failed := 0
+	for _, path := range opts.paths {
+		fmt.Fprintf(stdout, "正在轉換: %s\n", path)
+
+		result, err := dl.DownloadContext(ctx, path)
+		var cancelled *downloader.CancelledError
+		if errors.As(err, &cancelled) && ctx.Err() != nil {
+			fmt.Fprintf(stderr, "\n%v\n", err)
+			return exitInterrupted
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "\n錯誤: %v\n", err)
+			failed++
+			continue
+		}
+		if printResult(stdout, stderr, cfg, result) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(stderr, "\n%d/%d 個路徑處理失敗\n", failed, len(opts.paths))
+		return exitFailure
+	}
+
+	fmt.Fprintln(stdout, "\n✓ 全部完成！")
+	return exitOK
+}
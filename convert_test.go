@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseConvertArgs(t *testing.T) {
+	t.Run("paths", func(t *testing.T) {
+		opts, err := parseConvertArgs([]string{"-o", "music", "-preset", "flac", "video.mkv", "concerts"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if strings.Join(opts.paths, ",") != "video.mkv,concerts" || opts.config.OutputDir != "music" || opts.config.AudioFormat != "flac" {
+			t.Errorf("Unexpected options: %+v", opts)
+		}
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no paths", []string{}, "至少一個文件或目錄"},
+		{"url", []string{"https://youtu.be/dQw4w9WgXcQ"}, "只處理本地文件"},
+		{"clip", []string{"-start", "1:00", "video.mkv"}, "截取片段"},
+		{"best format", []string{"-format", "best", "video.mkv"}, "best"},
+		{"archive import", []string{"-archive-import", "archive.txt", "video.mkv"}, "archive-import"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConvertArgs(tt.args, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+
+	t.Run("usage exit code", func(t *testing.T) {
+		if code := run([]string{"convert"}, nil, io.Discard, io.Discard); code != exitUsage {
+			t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+		}
+	})
+}
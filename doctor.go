@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/validator"
+)
+
+// doctorReport doctor 子命令的環境報告，也是 -json 的輸出格式
+type doctorReport struct {
+	Version     string             `json:"version"`
+	Platform    string             `json:"platform"`
+	Tools       []toolStatus       `json:"tools"`
+	Encoders    []capabilityStatus `json:"encoders"`
+	Filters     []capabilityStatus `json:"filters"`
+	OutputDir   dirReport          `json:"output_dir"`
+	ConfigFiles []configFileStatus `json:"config_files"`
+	Env         []envVar           `json:"env"`   // 設置了的 YT2MP3_* 環境變量
+	Proxy       []envVar           `json:"proxy"` // 設置了的代理環境變量，密碼已隱藏
+	Problems    []string           `json:"problems"`
+	Warnings    []string           `json:"warnings"` // 不影響下載的問題，例如 space_check = warn 時空間不足
+}
+
+// toolStatus 外部命令的路徑和版本
+type toolStatus struct {
+	Name       string `json:"name"`
+	Path       string `json:"path,omitempty"`
+	Source     string `json:"source,omitempty"` // 路徑的來源: config、env 變量名、tools 或 PATH
+	Version    string `json:"version,omitempty"`
+	MinVersion string `json:"min_version,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// capabilityStatus ffmpeg 編碼器或濾鏡是否可用
+type capabilityStatus struct {
+	Name       string `json:"name"`
+	Format     string `json:"format,omitempty"` // 編碼器對應的音頻格式
+	Available  bool   `json:"available"`
+	RequiredBy string `json:"required_by,omitempty"` // 當前配置中需要它的配置項
+}
+
+// dirReport 輸出目錄的狀態
+type dirReport struct {
+	Path      string `json:"path"`
+	Exists    bool   `json:"exists"`
+	Writable  bool   `json:"writable"`
+	FreeBytes uint64 `json:"free_bytes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// configFileStatus 配置文件的查找位置
+type configFileStatus struct {
+	Path   string `json:"path"`
+	Loaded bool   `json:"loaded"`
+}
+
+// envVar 環境變量
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// proxyVars yt-dlp 和 Go 的 HTTP 客戶端讀取的代理環境變量
+var proxyVars = []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"}
+
+// runDoctor 執行 doctor 子命令，發現問題時返回 exitFailure
+func runDoctor(args []string, stdout, stderr io.Writer) int {
+	opts, code, ok := parseOrExit(parseDoctorArgs, args, stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	report := buildDoctorReport(newSystemValidator(opts.config), opts, os.Getenv)
+	if opts.jsonReport {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "錯誤: %v\n", err)
+			return exitFailure
+		}
+	} else {
+		printDoctorReport(stdout, report)
+	}
+
+	if len(report.Problems) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// buildDoctorReport 檢查依賴、ffmpeg 功能、輸出目錄、配置文件和代理設置
+func buildDoctorReport(systemValidator *validator.SystemValidator, opts *options, getenv func(string) string) *doctorReport {
+	cfg := opts.config
+	report := &doctorReport{
+		Version:     version,
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		Tools:       []toolStatus{},
+		Encoders:    []capabilityStatus{},
+		Filters:     []capabilityStatus{},
+		ConfigFiles: []configFileStatus{},
+		Env:         []envVar{},
+		Proxy:       []envVar{},
+		Problems:    []string{},
+		Warnings:    []string{},
+	}
+	problem := func(format string, args ...interface{}) {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// 依賴
+	setMinVersions(systemValidator, cfg)
+	minVersions := map[string]string{
+		config.YtDlpCommand:   cfg.MinYtDlpVersion,
+		config.FFmpegCommand:  cfg.MinFFmpegVersion,
+		config.FFprobeCommand: cfg.MinFFmpegVersion,
+	}
+	ffmpegOK := false
+	for _, name := range []string{config.YtDlpCommand, config.FFmpegCommand, config.FFprobeCommand} {
+		info, err := systemValidator.CheckTool(name)
+		tool := toolStatus{Name: name, Path: info.Path, Source: info.Source, Version: info.Version, MinVersion: minVersions[name]}
+		if err != nil {
+			tool.Error = err.Error()
+			problem("%v", err)
+		} else if name == config.FFmpegCommand {
+			ffmpegOK = true
+		}
+		report.Tools = append(report.Tools, tool)
+	}
+
+	// 編碼器和濾鏡，標出當前配置需要的
+	if ffmpegOK {
+		report.Encoders, report.Filters = capabilityReport(systemValidator, cfg, problem)
+	}
+
+	// 輸出目錄
+	dir := validator.CheckDir(cfg.OutputDir)
+	report.OutputDir = dirReport{Path: dir.Path, Exists: dir.Exists, Writable: dir.Writable, FreeBytes: dir.Free}
+	if dir.Err != nil {
+		report.OutputDir.Error = dir.Err.Error()
+	}
+	// 與下載前的檢查相同：不可寫或按 space_check 拒絕下載的為問題，其餘為警告
+	warnings, err := validator.Preflight(cfg, 0)
+	if err != nil {
+		problem("%v", err)
+	}
+	report.Warnings = append(report.Warnings, warnings...)
+
+	// 配置文件，與 config.Load 的查找順序一致
+	candidates := config.UserConfigPaths(getenv)
+	if opts.configFile != "" {
+		candidates = []string{opts.configFile}
+	}
+	candidates = append(candidates, filepath.Join(".", config.ProjectFileName))
+	loaded := make(map[string]bool)
+	if opts.loaded != nil {
+		for _, path := range opts.loaded.Files {
+			loaded[path] = true
+		}
+	}
+	for _, path := range candidates {
+		report.ConfigFiles = append(report.ConfigFiles, configFileStatus{Path: path, Loaded: loaded[path]})
+	}
+
+	// 環境變量
+	for _, key := range config.Keys() {
+		name := config.EnvName(key)
+		if value := getenv(name); value != "" {
+			report.Env = append(report.Env, envVar{Name: name, Value: value})
+		}
+	}
+	for _, name := range proxyVars {
+		if value := getenv(name); value != "" {
+			report.Proxy = append(report.Proxy, envVar{Name: name, Value: redactProxy(value)})
+		}
+	}
+	return report
+}
+
+// capabilityReport 列出每種音頻格式的編碼器和後處理使用的濾鏡，當前配置需要但不可用的記為問題
+func capabilityReport(systemValidator *validator.SystemValidator, cfg *config.Config, problem func(string, ...interface{})) (encoders, filters []capabilityStatus) {
+	encoders, filters = []capabilityStatus{}, []capabilityStatus{}
+	caps, err := systemValidator.Capabilities()
+	if err != nil {
+		problem("%v", err)
+		return encoders, filters
+	}
+
+	required := make(map[string]string)
+	for _, r := range validator.Requirements(cfg) {
+		required[r.Kind+r.Name] = r.Reason
+		if !caps.Has(r) {
+			problem("ffmpeg 缺少%s", r)
+		}
+	}
+	status := func(kind, name, format string) capabilityStatus {
+		r := validator.Requirement{Kind: kind, Name: name}
+		return capabilityStatus{Name: name, Format: format, Available: caps.Has(r), RequiredBy: required[kind+name]}
+	}
+
+	listed := make(map[string]bool)
+	for _, format := range config.AudioFormats {
+		if encoder := (config.Preset{AudioFormat: format}).Encoder(); encoder != "" {
+			encoders = append(encoders, status(validator.KindEncoder, encoder, format))
+			listed[encoder] = true
+		}
+	}
+	// 封面等不屬於音頻格式的編碼器
+	for _, r := range validator.Requirements(cfg) {
+		if r.Kind == validator.KindEncoder && !listed[r.Name] {
+			encoders = append(encoders, status(validator.KindEncoder, r.Name, ""))
+			listed[r.Name] = true
+		}
+	}
+	for _, name := range validator.Filters {
+		filters = append(filters, status(validator.KindFilter, name, ""))
+	}
+	return encoders, filters
+}
+
+// redactProxy 隱藏代理地址中的密碼
+func redactProxy(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+// printDoctorReport 輸出人類可讀的報告
+func printDoctorReport(w io.Writer, report *doctorReport) {
+	mark := func(ok bool) string {
+		if ok {
+			return "✓"
+		}
+		return "✗"
+	}
+
+	fmt.Fprintf(w, "youtube_to_mp3 %s (%s)\n", report.Version, report.Platform)
+
+	fmt.Fprintln(w, "\n依賴:")
+	for _, t := range report.Tools {
+		if t.Error != "" {
+			fmt.Fprintf(w, "  %s %s: %s\n", mark(false), t.Name, t.Error)
+			continue
+		}
+		version := t.Version
+		if version == "" {
+			version = "版本未知"
+		}
+		fmt.Fprintf(w, "  %s %s %s (%s)", mark(true), t.Name, version, t.Path)
+		if t.Source != "" {
+			fmt.Fprintf(w, "，來自 %s", t.Source)
+		}
+		if t.MinVersion != "" {
+			fmt.Fprintf(w, "，最低 %s", t.MinVersion)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Encoders) > 0 || len(report.Filters) > 0 {
+		fmt.Fprintln(w, "\n編碼器:")
+		for _, c := range report.Encoders {
+			fmt.Fprintf(w, "  %s %-6s %s%s\n", mark(c.Available), c.Format, c.Name, requiredBy(c))
+		}
+		fmt.Fprintln(w, "\n濾鏡:")
+		for _, c := range report.Filters {
+			fmt.Fprintf(w, "  %s %s%s\n", mark(c.Available), c.Name, requiredBy(c))
+		}
+	}
+
+	d := report.OutputDir
+	fmt.Fprintln(w, "\n輸出目錄:")
+	fmt.Fprintf(w, "  %s", d.Path)
+	if !d.Exists {
+		fmt.Fprint(w, "（不存在，下載時自動創建）")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  %s 可寫\n", mark(d.Writable))
+	if d.FreeBytes > 0 {
+		fmt.Fprintf(w, "  可用空間 %s\n", validator.FormatBytes(d.FreeBytes))
+	}
+
+	fmt.Fprintln(w, "\n配置文件:")
+	for _, f := range report.ConfigFiles {
+		state := "未找到"
+		if f.Loaded {
+			state = "已加載"
+		}
+		fmt.Fprintf(w, "  %s（%s）\n", f.Path, state)
+	}
+
+	fmt.Fprintln(w, "\n環境變量:")
+	printEnv(w, report.Env)
+	fmt.Fprintln(w, "\n代理:")
+	printEnv(w, report.Proxy)
+
+	if len(report.Warnings) > 0 {
+		fmt.Fprintf(w, "\n%d 個警告:\n", len(report.Warnings))
+		for _, warning := range report.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+
+	if len(report.Problems) == 0 {
+		fmt.Fprintln(w, "\n✓ 沒有發現問題")
+		return
+	}
+	fmt.Fprintf(w, "\n發現 %d 個問題:\n", len(report.Problems))
+	for _, p := range report.Problems {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+}
+
+// requiredBy 返回 "（audio_format = mp3 需要）" 形式的說明，配置不需要時返回空字符串
+func requiredBy(c capabilityStatus) string {
+	if c.RequiredBy == "" {
+		return ""
+	}
+	return "（" + c.RequiredBy + " 需要）"
+}
+
+// printEnv 逐行輸出環境變量，沒有時輸出 "(未設置)"
+func printEnv(w io.Writer, vars []envVar) {
+	if len(vars) == 0 {
+		fmt.Fprintln(w, "  (未設置)")
+		return
+	}
+	for _, v := range vars {
+		fmt.Fprintf(w, "  %s=%s\n", v.Name, v.Value)
+	}
+}
@@ -1,60 +1,312 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"youtube_to_mp3/pkg/archive"
 	"youtube_to_mp3/pkg/config"
 	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/postprocess"
 	"youtube_to_mp3/pkg/validator"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("使用方法: go run main.go <YouTube URL>")
-		fmt.Println("範例: go run main.go https://www.youtube.com/watch?v=dQw4w9WgXcQ")
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 執行程序並返回退出碼
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "batch":
+			return runBatch(args[1:], stdin, stdout, stderr)
+		case "convert":
+			return runConvert(args[1:], stdout, stderr)
+		case "doctor":
+			return runDoctor(args[1:], stdout, stderr)
+		}
 	}
 
-	youtubeURL := os.Args[1]
+	opts, code, ok := parseOrExit(parseArgs, args, stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	cfg := opts.config
+	dl, code := newDownloader(opts, stderr)
+	if dl == nil {
+		return code
+	}
 
-	fmt.Println("開始處理 YouTube 視頻...")
-	fmt.Printf("URL: %s\n\n", youtubeURL)
+	ctx, stop := notifyContext()
+	defer stop()
 
-	// 檢查依賴
-	systemValidator := validator.NewSystemValidator(nil)
-	if err := systemValidator.ValidateDependencies(); err != nil {
-		fmt.Printf("錯誤: %v\n", err)
-		os.Exit(1)
+	failed := 0
+	for _, youtubeURL := range opts.urls {
+		fmt.Fprintln(stdout, "開始處理 YouTube 視頻...")
+		fmt.Fprintf(stdout, "URL: %s\n\n", youtubeURL)
+
+		// 下載並轉換為 MP3
+		fmt.Fprintln(stdout, "正在下載並轉換...")
+		fmt.Fprintln(stdout, "(大文件轉換可能需要幾分鐘，請耐心等待...)")
+
+		result, err := dl.DownloadContext(ctx, youtubeURL)
+		var cancelled *downloader.CancelledError
+		if errors.As(err, &cancelled) && ctx.Err() != nil {
+			fmt.Fprintf(stderr, "\n%v\n", err)
+			return exitInterrupted
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "\n錯誤: %v\n", err)
+			failed++
+			continue
+		}
+
+		if printResult(stdout, stderr, cfg, result) {
+			failed++
+		}
 	}
 
-	// 創建配置
-	cfg := config.NewConfig()
+	if failed > 0 {
+		fmt.Fprintf(stderr, "\n%d/%d 個 URL 處理失敗\n", failed, len(opts.urls))
+		return exitFailure
+	}
 
-	// 創建下載器
-	dl := downloader.NewYtDlpDownloader(cfg, nil)
+	fmt.Fprintln(stdout, "\n✓ 全部完成！")
+	return exitOK
+}
 
-	// 下載並轉換為 MP3
-	fmt.Println("正在下載並轉換...")
-	fmt.Println("(大文件轉換可能需要幾分鐘，請耐心等待...)")
+// printResult 輸出單個 URL 或本地路徑的處理結果，有條目失敗時返回 true
+func printResult(stdout, stderr io.Writer, cfg *config.Config, result *downloader.Result) bool {
+	local := !isURL(result.URL)
+	if result.Skipped {
+		if local {
+			fmt.Fprintf(stdout, "輸出文件已存在，跳過 (使用 -force 重新轉換): %s\n", result.URL)
+		} else {
+			fmt.Fprintf(stdout, "已在下載存檔中，跳過 (使用 -force 重新下載): %s\n", result.VideoID)
+		}
+	}
 
-	if err := dl.Download(youtubeURL); err != nil {
-		fmt.Printf("\n錯誤: %v\n", err)
-		os.Exit(1)
+	// 播放列表或目錄中個別條目失敗不影響其他條目
+	failures := result.Failed()
+	if len(failures) > 0 {
+		for _, entry := range failures {
+			if entry.PlaylistIndex > 0 {
+				fmt.Fprintf(stderr, "\n警告: 第 %d 項 (%s) 失敗: %v\n", entry.PlaylistIndex, entry.VideoID, entry.Err)
+			} else {
+				fmt.Fprintf(stderr, "\n警告: %s 失敗: %v\n", entry.VideoID, entry.Err)
+			}
+		}
+		kind := "播放列表"
+		if local {
+			kind = "目錄"
+		}
+		fmt.Fprintf(stderr, "%s %s: %d/%d 項失敗\n", kind, result.Title, len(failures), len(result.Entries))
 	}
 
-	fmt.Println("\n轉換完成！正在查找輸出文件...")
+	for _, warning := range result.AllWarnings() {
+		fmt.Fprintf(stderr, "\n警告: %s\n", warning)
+	}
 
 	// 顯示輸出文件
-	files, err := dl.GetOutputFiles()
-	if err != nil {
-		fmt.Printf("警告: 無法查找輸出文件: %v\n", err)
-	} else if len(files) > 0 {
-		fmt.Printf("\n成功！MP3 文件已保存到: %s\n", files[len(files)-1])
+	if len(result.Files) > 0 {
+		printFiles(stdout, cfg, result)
+		printRemoved(stdout, result)
+		printLoudness(stdout, cfg, result)
 	} else {
-		fmt.Println("警告: 未找到輸出文件，但轉換過程已完成")
-		fmt.Printf("請檢查 %s 目錄\n", cfg.OutputDir)
+		fmt.Fprintln(stdout, "\n警告: 未能獲取輸出文件，但轉換過程已完成")
+		fmt.Fprintf(stdout, "請檢查 %s 目錄\n", cfg.OutputDir)
+	}
+	return len(failures) > 0
+}
+
+// printFiles 輸出結果中的文件，多格式輸出時註明每個文件的預設
+func printFiles(stdout io.Writer, cfg *config.Config, result *downloader.Result) {
+	outputs := result.Outputs
+	for _, entry := range result.Entries {
+		outputs = append(outputs, entry.Outputs...)
+	}
+	if len(outputs) == 0 {
+		for _, file := range result.Files {
+			fmt.Fprintf(stdout, "\n成功！%s 文件已保存到: %s\n", cfg.AudioFormat, file)
+		}
+		return
+	}
+	for _, output := range outputs {
+		fmt.Fprintf(stdout, "\n成功！%s 文件已保存到: %s\n", output.Preset, output.Path)
+	}
+}
+
+// printRemoved 輸出移除的 SponsorBlock 片段
+func printRemoved(stdout io.Writer, result *downloader.Result) {
+	results := append([]*downloader.Result{result}, result.Entries...)
+	for _, r := range results {
+		for _, seg := range r.Removed {
+			fmt.Fprintf(stdout, "已移除 %s: %s [%s] %s-%s\n", seg.Category, r.Title, r.VideoID,
+				config.Timestamp{Seconds: seg.Start}, config.Timestamp{Seconds: seg.End})
+		}
+	}
+}
+
+// printLoudness 輸出響度處理前後的測量值
+func printLoudness(stdout io.Writer, cfg *config.Config, result *downloader.Result) {
+	loudness := result.Loudness
+	for _, entry := range result.Entries {
+		loudness = append(loudness, entry.Loudness...)
+	}
+	for _, l := range loudness {
+		if cfg.Loudness == "replaygain" {
+			fmt.Fprintf(stdout, "響度: %s %.1f LUFS，ReplayGain %+.2f dB\n", filepath.Base(l.Path), l.Before.Integrated, l.Gain)
+			continue
+		}
+		fmt.Fprintf(stdout, "響度: %s %.1f → %.1f LUFS，真峰值 %.1f → %.1f dBTP\n",
+			filepath.Base(l.Path), l.Before.Integrated, l.After.Integrated, l.Before.TruePeak, l.After.TruePeak)
+	}
+}
+
+// parseOrExit 解析參數，處理 --help、--version、--print-config 和參數錯誤
+// ok 為 false 時應直接以 code 退出
+func parseOrExit(parse func([]string, io.Writer) (*options, error), args []string, stdout, stderr io.Writer) (opts *options, code int, ok bool) {
+	opts, err := parse(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, exitOK, false
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "錯誤: %v\n", err)
+		fmt.Fprintln(stderr, "使用 --help 查看所有選項")
+		return nil, exitUsage, false
+	}
+	if opts.showVersion {
+		fmt.Fprintf(stdout, "youtube_to_mp3 %s\n", version)
+		return nil, exitOK, false
+	}
+	if opts.printConfig {
+		opts.loaded.Dump(stdout)
+		return nil, exitOK, false
+	}
+	return opts, exitOK, true
+}
+
+// checkDependencies 檢查所選模式需要的 yt-dlp 和 ffmpeg 及其版本，以及 ffmpeg 是否支持配置需要的編碼器和濾鏡，
+// 不滿足時輸出錯誤並返回 false。verbose 模式下輸出解析出的路徑和版本
+func checkDependencies(mode validator.Mode, cfg *config.Config, stderr io.Writer) bool {
+	return checkDependenciesWith(newSystemValidator(cfg), mode, cfg, stderr)
+}
+
+// newSystemValidator 創建按配置、環境變量、tools 目錄、PATH 的順序查找命令的驗證器
+func newSystemValidator(cfg *config.Config) *validator.SystemValidator {
+	return validator.NewSystemValidator(&validator.DefaultCommandChecker{Locator: validator.NewLocator(cfg)})
+}
+
+// checkDependenciesWith 用指定的驗證器檢查依賴
+func checkDependenciesWith(systemValidator *validator.SystemValidator, mode validator.Mode, cfg *config.Config, stderr io.Writer) bool {
+	setMinVersions(systemValidator, cfg)
+	infos, err := systemValidator.Check(mode)
+	if err != nil {
+		fmt.Fprintf(stderr, "錯誤: %v\n", err)
+		return false
+	}
+	for _, info := range infos {
+		if cfg.Verbose {
+			fmt.Fprintf(stderr, "使用 %s\n", info)
+		}
+		// 下載和後處理使用與檢查時相同的命令
+		setToolPath(cfg, info)
+	}
+	if err := systemValidator.ValidateCapabilities(cfg); err != nil {
+		fmt.Fprintf(stderr, "錯誤: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// setToolPath 把解析出的命令路徑寫回配置，路徑為空時保留原值
+func setToolPath(cfg *config.Config, info validator.CommandInfo) {
+	if info.Path == "" {
+		return
+	}
+	switch info.Name {
+	case config.YtDlpCommand:
+		cfg.YtDlpPath = info.Path
+	case config.FFmpegCommand:
+		cfg.FFmpegPath = info.Path
+	case config.FFprobeCommand:
+		cfg.FFprobePath = info.Path
 	}
+}
+
+// setMinVersions 按配置設置依賴的最低版本，配置校驗已經檢查過版本號格式
+func setMinVersions(systemValidator *validator.SystemValidator, cfg *config.Config) {
+	systemValidator.SetMinVersion(config.YtDlpCommand, cfg.MinYtDlpVersion)
+	systemValidator.SetMinVersion(config.FFmpegCommand, cfg.MinFFmpegVersion)
+	// ffprobe 隨 ffmpeg 一起發布
+	systemValidator.SetMinVersion(config.FFprobeCommand, cfg.MinFFmpegVersion)
+}
+
+// preflight 返回下載前檢查輸出目錄和磁盤空間的回調，設置了 space_estimate 時需要的空間由 yt-dlp 預先獲取的大小估算
+func preflight(cfg *config.Config) downloader.PreflightFunc {
+	return func(info *downloader.VideoInfo) ([]string, error) {
+		var required int64
+		if info != nil {
+			required = validator.EstimateSize(cfg, info.Fields)
+		}
+		return validator.Preflight(cfg, required)
+	}
+}
+
+// newDownloader 檢查依賴並創建帶後處理的下載器，失敗時返回 nil 和退出碼
+func newDownloader(opts *options, stderr io.Writer) (downloader.Downloader, int) {
+	if !checkDependencies(validator.ModeDownload, opts.config, stderr) {
+		return nil, exitDependency
+	}
+	arc, err := openArchive(opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "錯誤: %v\n", err)
+		return nil, exitFailure
+	}
+
+	dl := downloader.NewYtDlpDownloader(opts.config, nil)
+	dl.SetArchive(arc)
+	dl.SetPreflight(preflight(opts.config))
+	if opts.quiet {
+		dl.SetOutput(io.Discard, stderr)
+	}
+	return postprocess.New(dl, postprocess.Steps(opts.config, nil)...), exitOK
+}
+
+// openArchive 打開下載存檔，並按需導入 yt-dlp 存檔
+func openArchive(opts *options, stderr io.Writer) (*archive.Archive, error) {
+	arc, err := archive.Open(opts.config.ArchiveFile())
+	if err != nil {
+		return nil, err
+	}
+	if opts.importFrom == "" {
+		return arc, nil
+	}
+
+	file, err := os.Open(opts.importFrom)
+	if err != nil {
+		return nil, fmt.Errorf("無法打開要導入的存檔: %v", err)
+	}
+	defer file.Close()
+
+	added, err := arc.Import(file)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(stderr, "已從 %s 導入 %d 條存檔記錄\n", opts.importFrom, added)
+	return arc, nil
+}
 
-	fmt.Println("\n✓ 全部完成！")
+// notifyContext Ctrl+C 時取消，終止 yt-dlp/ffmpeg 並清理臨時文件
+func notifyContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
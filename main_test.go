@@ -1,19 +1,430 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/validator"
+	"youtube_to_mp3/test/mocks"
 )
 
 func TestMain(m *testing.M) {
-	// 可以在這裡設置全局測試環境
+	// 不讀取本機的用戶配置文件和 YT2MP3_* 環境變量
+	dir, err := os.MkdirTemp("", "yt2mp3-test-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Setenv("XDG_CONFIG_DIRS", dir)
+	for _, key := range config.Keys() {
+		os.Unsetenv(config.EnvName(key))
+	}
+
 	code := m.Run()
+	os.RemoveAll(dir)
 	os.Exit(code)
 }
 
-// 注意：main() 函數本身很難直接測試，因為它使用 os.Exit()
-// 但我們已經通過單元測試覆蓋了所有主要組件：
+// 注意：main() 只負責調用 run() 並 os.Exit()，命令行邏輯通過 run() 測試
+// 各組件的單元測試覆蓋：
 // - config 包
 // - validator 包
 // - downloader 包
 // 並且通過集成測試驗證了端到端流程
+
+func TestParseArgs(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		opts, err := parseArgs([]string{"https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.config.OutputDir != "output" || opts.config.Bitrate != "320k" || opts.config.Playlist {
+			t.Errorf("Unexpected default config: %+v", opts.config)
+		}
+	})
+
+	t.Run("all flags and multiple urls", func(t *testing.T) {
+		args := []string{
+			"-o", "music", "--format", "opus", "-quality", "5", "-bitrate", "160k",
+			"-template", "%(uploader)s/%(title)s.%(ext)s", "-playlist", "-v",
+			"https://youtu.be/a", "https://youtu.be/b",
+		}
+		opts, err := parseArgs(args, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.OutputDir != "music" || cfg.AudioFormat != "opus" || cfg.AudioQuality != "5" || cfg.Bitrate != "160k" {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+		if cfg.OutputTemplate != filepath.Join("music", "%(uploader)s", "%(title)s.%(ext)s") {
+			t.Errorf("Unexpected template: %s", cfg.OutputTemplate)
+		}
+		if !cfg.Playlist || !cfg.Verbose {
+			t.Errorf("Expected playlist and verbose to be set: %+v", cfg)
+		}
+		if len(opts.urls) != 2 {
+			t.Errorf("Expected 2 urls, got %v", opts.urls)
+		}
+	})
+
+	t.Run("flags after urls", func(t *testing.T) {
+		opts, err := parseArgs([]string{"https://youtu.be/a", "-format", "m4a", "https://youtu.be/b", "-q"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.config.AudioFormat != "m4a" || !opts.quiet {
+			t.Errorf("Expected flags after urls to be parsed: %+v", opts.config)
+		}
+		if strings.Join(opts.urls, " ") != "https://youtu.be/a https://youtu.be/b" {
+			t.Errorf("Expected 2 urls, got %v", opts.urls)
+		}
+	})
+
+	t.Run("double dash ends flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-format", "m4a", "--", "https://youtu.be/a", "-q"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.quiet || strings.Join(opts.urls, " ") != "https://youtu.be/a -q" {
+			t.Errorf("Expected arguments after -- to be urls, got %v", opts.urls)
+		}
+	})
+
+	t.Run("space flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-space-check", "warn", "-min-free-space", "2G", "-space-estimate", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.SpaceCheck != "warn" || cfg.MinFreeSpace != 2<<30 || !cfg.SpaceEstimate {
+			t.Errorf("Unexpected space config: %+v", cfg)
+		}
+	})
+
+	t.Run("playlist flags", func(t *testing.T) {
+		args := []string{
+			"-playlist", "-playlist-items", "1-10,15", "-playlist-reverse", "-playlist-max", "20",
+			"https://www.youtube.com/playlist?list=PL123",
+		}
+		opts, err := parseArgs(args, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.PlaylistItems != "1-10,15" || !cfg.PlaylistReverse || cfg.PlaylistMaxItems != 20 {
+			t.Errorf("Unexpected playlist config: %+v", cfg)
+		}
+	})
+
+	t.Run("outputs", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-outputs", "mp3-320;flac=archive", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(opts.config.Outputs) != 2 || opts.config.Outputs[1].Path != "archive" {
+			t.Errorf("Unexpected outputs: %+v", opts.config.Outputs)
+		}
+	})
+
+	t.Run("tag flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-tags=false", "-tag-genre", "Podcast", "-tag-rules", config.ArtistTitleRule, "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.Tags || cfg.TagGenre != "Podcast" || len(cfg.TagRules) != 1 || cfg.TagRules[0].Field != "title" {
+			t.Errorf("Unexpected tag config: %+v", cfg)
+		}
+	})
+
+	t.Run("cover flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-cover", "-cover-square", "-cover-size", "600", "-cover-jpeg=false", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if !cfg.Cover || !cfg.CoverSquare || cfg.CoverSize != 600 || cfg.CoverJPEG {
+			t.Errorf("Unexpected cover config: %+v", cfg)
+		}
+	})
+
+	t.Run("chapter flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-split-chapters", "-chapter-playlist", "cue", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !opts.config.SplitChapters || opts.config.ChapterPlaylist != "cue" {
+			t.Errorf("Unexpected chapter config: %+v", opts.config)
+		}
+	})
+
+	t.Run("clip flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-start", "12:30", "-end", "945", "-clip", "-5:00-inf", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got := config.FormatClips(opts.config.Clips); got != "12:30-15:45;-5:00-inf" {
+			t.Errorf("Unexpected clips: %s", got)
+		}
+	})
+
+	t.Run("loudness flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-loudness", "loudnorm", "-loudness-target", "-14", "-loudness-tp", "-1", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.Loudness != "loudnorm" || cfg.LoudnessTarget != -14 || cfg.LoudnessTruePeak != -1 || cfg.LoudnessRange != 11 {
+			t.Errorf("Unexpected loudness config: %+v", cfg)
+		}
+	})
+
+	t.Run("trim flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-trim-silence", "-silence-threshold", "-40", "-fade-out", "3s", "-max-duration", "10m", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if !cfg.TrimSilence || cfg.SilenceThreshold != -40 || cfg.SilenceDuration != 500*time.Millisecond ||
+			cfg.FadeIn != 0 || cfg.FadeOut != 3*time.Second || cfg.MaxDuration != 10*time.Minute {
+			t.Errorf("Unexpected trim config: %+v", cfg)
+		}
+	})
+
+	t.Run("sponsorblock flags", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-sponsorblock", "sponsor, intro", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if strings.Join(cfg.SponsorBlock, "|") != "sponsor|intro" || cfg.SponsorBlockAPI != config.DefaultSponsorBlockAPI {
+			t.Errorf("Unexpected sponsorblock config: %v %s", cfg.SponsorBlock, cfg.SponsorBlockAPI)
+		}
+	})
+
+	t.Run("preset", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-preset", "flac", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.config.AudioFormat != "flac" || opts.config.Bitrate != "" {
+			t.Errorf("Unexpected preset config: %+v", opts.config)
+		}
+	})
+
+	invalid := map[string][]string{
+		"bad playlist items":     {"-playlist-items", "1..5", "https://youtu.be/a"},
+		"negative max":           {"-playlist-max", "-1", "https://youtu.be/a"},
+		"no url":                 {},
+		"unknown flag":           {"-nope", "https://youtu.be/a"},
+		"bad format":             {"-format", "mp4a", "https://youtu.be/a"},
+		"bad quality":            {"-quality", "11", "https://youtu.be/a"},
+		"bad bitrate":            {"-bitrate", "320", "https://youtu.be/a"},
+		"verbose quiet":          {"-v", "-q", "https://youtu.be/a"},
+		"non-int quality":        {"-quality", "best", "https://youtu.be/a"},
+		"unknown preset":         {"-preset", "mp3-999", "https://youtu.be/a"},
+		"bad outputs":            {"-outputs", "mp3-320;wma", "https://youtu.be/a"},
+		"bad tag rule":           {"-tag-rules", "title:(?P<singer>.+)", "https://youtu.be/a"},
+		"bad clip":               {"-clip", "15:45-12:30", "https://youtu.be/a"},
+		"bad start":              {"-start", "1:75", "https://youtu.be/a"},
+		"unknown flag after url": {"https://youtu.be/a", "-nope"},
+	}
+	for name, args := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseArgs(args, io.Discard)
+			var usage *usageError
+			if !errors.As(err, &usage) {
+				t.Errorf("Expected usage error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseArgsConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	content := "output_dir = \"music\"\nbitrate = \"192k\"\nverbose = true\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Run("file then env then flags", func(t *testing.T) {
+		t.Setenv("YT2MP3_AUDIO_FORMAT", "opus")
+		t.Setenv("YT2MP3_BITRATE", "256k")
+		opts, err := parseArgs([]string{"-config", file, "-bitrate", "128k", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := opts.config
+		if cfg.OutputDir != "music" || cfg.AudioFormat != "opus" || cfg.Bitrate != "128k" || !cfg.Verbose {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+		if source := opts.loaded.Sources["bitrate"]; source.Kind != config.SourceFlag {
+			t.Errorf("Expected bitrate from flag, got %s", source)
+		}
+	})
+
+	t.Run("quiet overrides verbose from file", func(t *testing.T) {
+		opts, err := parseArgs([]string{"-config", file, "-q", "https://youtu.be/a"}, io.Discard)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opts.config.Verbose {
+			t.Error("Expected verbose to be disabled by --quiet")
+		}
+	})
+
+	t.Run("invalid env value names its source", func(t *testing.T) {
+		t.Setenv("YT2MP3_BITRATE", "fast")
+		_, err := parseArgs([]string{"https://youtu.be/a"}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "YT2MP3_BITRATE") {
+			t.Errorf("Expected error naming YT2MP3_BITRATE, got: %v", err)
+		}
+	})
+
+	t.Run("all invalid values reported together", func(t *testing.T) {
+		t.Setenv("YT2MP3_AUDIO_QUALITY", "11")
+		_, err := parseArgs([]string{"-format", "mp4a", "-bitrate", "320", "https://youtu.be/a"}, io.Discard)
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		for _, want := range []string{"audio_format", "bitrate", "audio_quality = \"11\": 音頻質量必須是 0-10 的整數 (來自 env YT2MP3_AUDIO_QUALITY)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to contain %q, got: %v", want, err)
+			}
+		}
+	})
+
+	t.Run("missing config file", func(t *testing.T) {
+		_, err := parseArgs([]string{"-config", filepath.Join(dir, "missing.toml"), "https://youtu.be/a"}, io.Discard)
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Errorf("Expected usage error, got: %v", err)
+		}
+	})
+
+	t.Run("print config", func(t *testing.T) {
+		var stdout bytes.Buffer
+		code := run([]string{"-config", file, "-format", "flac", "-print-config"}, nil, &stdout, io.Discard)
+		if code != exitOK {
+			t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+		}
+		out := stdout.String()
+		for _, line := range []string{
+			`audio_format = "flac"  # flag -format`,
+			`bitrate = "192k"  # file ` + file,
+			`audio_quality = "0"  # default`,
+		} {
+			if !strings.Contains(out, line) {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out)
+			}
+		}
+	})
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"help", []string{"--help"}, exitOK},
+		{"version", []string{"--version"}, exitOK},
+		{"usage error", []string{"-bitrate", "fast", "https://youtu.be/a"}, exitUsage},
+		{"missing url", []string{}, exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, nil, &stdout, &stderr); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d (stderr: %s)", tt.code, code, stderr.String())
+			}
+		})
+	}
+
+	t.Run("version output", func(t *testing.T) {
+		var stdout bytes.Buffer
+		run([]string{"--version"}, nil, &stdout, io.Discard)
+		if !strings.Contains(stdout.String(), version) {
+			t.Errorf("Expected version in output, got: %s", stdout.String())
+		}
+	})
+}
+
+func TestCheckDependencies(t *testing.T) {
+	newChecker := func(ytDlpVersion string) *mocks.CommandChecker {
+		checker := mocks.NewCommandChecker()
+		checker.SetCommandInfo("yt-dlp", validator.CommandInfo{Name: "yt-dlp", Path: "/opt/bin/yt-dlp", Version: ytDlpVersion}, nil)
+		checker.SetCommandInfo("ffmpeg", validator.CommandInfo{Name: "ffmpeg", Path: "/usr/bin/ffmpeg", Version: "6.1.1"}, nil)
+		checker.SetOutput("ffmpeg -hide_banner -encoders", " A..... = Audio\n ------\n A....D libmp3lame  libmp3lame MP3\n")
+		checker.SetOutput("ffmpeg -hide_banner -filters", " ... anull  A->A  Pass the source unchanged\n")
+		return checker
+	}
+
+	t.Run("outdated yt-dlp", func(t *testing.T) {
+		var stderr bytes.Buffer
+		v := validator.NewSystemValidator(newChecker("2021.12.01"))
+		if checkDependenciesWith(v, validator.ModeDownload, config.NewConfig(), &stderr) {
+			t.Fatal("Expected check to fail")
+		}
+		if !strings.Contains(stderr.String(), "版本過舊: 2021.12.01 (/opt/bin/yt-dlp)") {
+			t.Errorf("Expected outdated version error, got: %s", stderr.String())
+		}
+	})
+
+	t.Run("minimum from config", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.MinYtDlpVersion = ""
+		v := validator.NewSystemValidator(newChecker("2021.12.01"))
+		if !checkDependenciesWith(v, validator.ModeDownload, cfg, io.Discard) {
+			t.Error("Expected check to pass without a minimum version")
+		}
+	})
+
+	t.Run("missing filter", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cfg := config.NewConfig()
+		cfg.Loudness = "loudnorm"
+		v := validator.NewSystemValidator(newChecker("2024.08.06"))
+		if checkDependenciesWith(v, validator.ModeDownload, cfg, &stderr) {
+			t.Fatal("Expected check to fail")
+		}
+		if !strings.Contains(stderr.String(), "濾鏡 loudnorm") {
+			t.Errorf("Expected missing filter error, got: %s", stderr.String())
+		}
+	})
+
+	t.Run("verbose reports paths", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cfg := config.NewConfig()
+		cfg.Verbose = true
+		v := validator.NewSystemValidator(newChecker("2024.08.06"))
+		if !checkDependenciesWith(v, validator.ModeDownload, cfg, &stderr) {
+			t.Fatalf("Expected check to pass, got: %s", stderr.String())
+		}
+		for _, want := range []string{"使用 yt-dlp 2024.08.06 (/opt/bin/yt-dlp)", "使用 ffmpeg 6.1.1 (/usr/bin/ffmpeg)"} {
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("Expected %q in output, got: %s", want, stderr.String())
+			}
+		}
+	})
+	t.Run("resolved paths are written to config", func(t *testing.T) {
+		cfg := config.NewConfig()
+		v := validator.NewSystemValidator(newChecker("2024.08.06"))
+		if !checkDependenciesWith(v, validator.ModeDownload, cfg, io.Discard) {
+			t.Fatal("Expected check to pass")
+		}
+		if cfg.YtDlpPath != "/opt/bin/yt-dlp" || cfg.FFmpegPath != "/usr/bin/ffmpeg" {
+			t.Errorf("Expected resolved paths in config, got %q and %q", cfg.YtDlpPath, cfg.FFmpegPath)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pathsSuffix 記錄輸出路徑的附屬文件後綴
+const pathsSuffix = ".paths"
+
+// Archive 已完成下載的存檔
+//
+// 存檔文件與 yt-dlp --download-archive 格式相同，每行 "<extractor> <id>"，
+// 可以直接使用 yt-dlp 生成的存檔。輸出路徑另存在 "<存檔>.paths" 中，
+// 每行 "<extractor> <id>\t<path>"。
+type Archive struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string][]string // "<extractor> <id>" -> 輸出路徑
+}
+
+// Open 打開存檔，文件不存在時創建空存檔
+func Open(path string) (*Archive, error) {
+	a := &Archive{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Path 返回存檔文件路徑
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// Reload 重新讀取存檔，存檔文件被其他程序修改後調用
+func (a *Archive) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := make(map[string][]string)
+	if err := readLines(a.path, func(line string) {
+		if key, ok := normalize(line); ok {
+			entries[key] = nil
+		}
+	}); err != nil {
+		return fmt.Errorf("讀取下載存檔失敗: %v", err)
+	}
+
+	if err := readLines(a.path+pathsSuffix, func(line string) {
+		key, path, found := strings.Cut(line, "\t")
+		if _, ok := entries[key]; found && ok {
+			entries[key] = append(entries[key], path)
+		}
+	}); err != nil {
+		return fmt.Errorf("讀取下載存檔失敗: %v", err)
+	}
+
+	a.entries = entries
+	return nil
+}
+
+// Has 判斷視頻是否已下載過
+func (a *Archive) Has(extractor, id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.entries[key(extractor, id)]
+	return ok
+}
+
+// Paths 返回視頻的輸出路徑，從 yt-dlp 導入的條目沒有路徑
+func (a *Archive) Paths(extractor, id string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.entries[key(extractor, id)]...)
+}
+
+// Len 返回存檔中的條目數
+func (a *Archive) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+// Add 記錄已完成的視頻及其輸出路徑，已存在時只更新路徑
+func (a *Archive) Add(extractor, id string, paths []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := key(extractor, id)
+	if _, ok := a.entries[k]; !ok {
+		if err := a.appendLines([]string{k}); err != nil {
+			return err
+		}
+	}
+	a.entries[k] = append([]string(nil), paths...)
+	return a.writePaths()
+}
+
+// Import 合併 yt-dlp 格式的存檔，返回新增的條目數
+func (a *Archive) Import(r io.Reader) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var added []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		k, ok := normalize(scanner.Text())
+		if !ok {
+			continue
+		}
+		if _, exists := a.entries[k]; exists {
+			continue
+		}
+		a.entries[k] = nil
+		added = append(added, k)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("讀取導入的存檔失敗: %v", err)
+	}
+	if err := a.appendLines(added); err != nil {
+		return 0, err
+	}
+	return len(added), nil
+}
+
+// Export 按 yt-dlp 的格式輸出存檔中的條目，與 Import 對應
+func (a *Archive) Export(w io.Writer) error {
+	a.mu.Lock()
+	keys := make([]string, 0, len(a.entries))
+	for k := range a.entries {
+		keys = append(keys, k)
+	}
+	a.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintln(w, k); err != nil {
+			return fmt.Errorf("導出下載存檔失敗: %v", err)
+		}
+	}
+	return nil
+}
+
+// appendLines 向存檔文件追加條目
+func (a *Archive) appendLines(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("創建存檔目錄失敗: %v", err)
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("寫入下載存檔失敗: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return fmt.Errorf("寫入下載存檔失敗: %v", err)
+	}
+	return nil
+}
+
+// writePaths 重寫輸出路徑文件，先寫臨時文件再重命名
+func (a *Archive) writePaths() error {
+	keys := make([]string, 0, len(a.entries))
+	for k := range a.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		for _, path := range a.entries[k] {
+			fmt.Fprintf(&b, "%s\t%s\n", k, path)
+		}
+	}
+
+	tmp := a.path + pathsSuffix + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("寫入下載存檔失敗: %v", err)
+	}
+	if err := os.Rename(tmp, a.path+pathsSuffix); err != nil {
+		return fmt.Errorf("寫入下載存檔失敗: %v", err)
+	}
+	return nil
+}
+
+// readLines 逐行讀取文件，文件不存在時不報錯
+func readLines(path string, fn func(line string)) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// normalize 把 yt-dlp 存檔行規範化為 "<extractor> <id>"
+func normalize(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return "", false
+	}
+	return key(fields[0], fields[1]), true
+}
+
+// key 與 yt-dlp 一致，extractor 使用小寫
+func key(extractor, id string) string {
+	return strings.ToLower(extractor) + " " + id
+}
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenMissingFile(t *testing.T) {
+	a, err := Open(filepath.Join(t.TempDir(), "archive.txt"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if a.Len() != 0 {
+		t.Errorf("Expected empty archive, got %d entries", a.Len())
+	}
+}
+
+func TestAddAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "archive.txt")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if err := a.Add("Youtube", "abc123", []string{"output/Song.mp3"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// 重複添加只更新路徑，不重複寫入存檔
+	if err := a.Add("youtube", "abc123", []string{"output/Song (new).mp3"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "youtube abc123\n" {
+		t.Errorf("Expected yt-dlp compatible archive, got %q", content)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reopened.Has("youtube", "abc123") {
+		t.Error("Expected abc123 to be archived")
+	}
+	if paths := reopened.Paths("youtube", "abc123"); len(paths) != 1 || paths[0] != "output/Song (new).mp3" {
+		t.Errorf("Unexpected paths: %v", paths)
+	}
+	if reopened.Has("youtube", "other") {
+		t.Error("Expected other to be missing")
+	}
+}
+
+func TestImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.txt")
+	a, _ := Open(path)
+	_ = a.Add("youtube", "abc123", nil)
+
+	// yt-dlp 生成的存檔
+	ytdlp := "youtube abc123\nyoutube def456\n\nsoundcloud 789\ninvalid line here\n"
+	added, err := a.Import(strings.NewReader(ytdlp))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 new entries, got %d", added)
+	}
+	if !a.Has("youtube", "def456") || !a.Has("soundcloud", "789") {
+		t.Error("Expected imported entries to be present")
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "youtube abc123\nyoutube def456\nsoundcloud 789\n" {
+		t.Errorf("Unexpected archive content %q", content)
+	}
+}
+
+func TestReloadPicksUpExternalWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.txt")
+	a, _ := Open(path)
+
+	// 模擬 yt-dlp 直接寫入存檔
+	if err := os.WriteFile(path, []byte("youtube xyz789\n"), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	if a.Has("youtube", "xyz789") {
+		t.Error("Expected entry to be missing before reload")
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !a.Has("youtube", "xyz789") {
+		t.Error("Expected entry after reload")
+	}
+}
+
+func TestExport(t *testing.T) {
+	a, _ := Open(filepath.Join(t.TempDir(), "archive.txt"))
+	_ = a.Add("Youtube", "bbb", []string{"output/B.mp3"})
+	_ = a.Add("youtube", "aaa", nil)
+
+	var b strings.Builder
+	if err := a.Export(&b); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if b.String() != "youtube aaa\nyoutube bbb\n" {
+		t.Errorf("Unexpected export: %q", b.String())
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ClipSuffix 截取片段時加在文件名後的時間範圍，例如 "Title [00.12.30-00.15.45].mp3"
+const ClipSuffix = " [%(section_start>%H.%M.%S)s-%(section_end>%H.%M.%S)s]"
+
+// Timestamp 視頻中的時間點
+type Timestamp struct {
+	Seconds float64
+	FromEnd bool // Seconds 為距離結尾的秒數
+}
+
+// End 視頻結尾
+var End = Timestamp{FromEnd: true}
+
+// ParseTimestamp 解析 hh:mm:ss、mm:ss 或秒數，前面加 - 表示距離結尾的時間
+func ParseTimestamp(value string) (Timestamp, error) {
+	s := strings.TrimSpace(value)
+	t := Timestamp{}
+	if strings.HasPrefix(s, "-") {
+		t.FromEnd = true
+		s = s[1:]
+	}
+
+	parts := strings.Split(s, ":")
+	if s == "" || len(parts) > 3 {
+		return Timestamp{}, fmt.Errorf("時間格式應為 hh:mm:ss、mm:ss 或秒數: %q", value)
+	}
+	for i, part := range parts {
+		last := i == len(parts)-1
+		var n float64
+		var err error
+		if last {
+			n, err = strconv.ParseFloat(part, 64)
+		} else {
+			var v int
+			v, err = strconv.Atoi(part)
+			n = float64(v)
+		}
+		if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) || strings.HasPrefix(part, "+") {
+			return Timestamp{}, fmt.Errorf("時間格式應為 hh:mm:ss、mm:ss 或秒數: %q", value)
+		}
+		// 分和秒在有更高的單位時不能超過 59
+		if i > 0 && n >= 60 {
+			return Timestamp{}, fmt.Errorf("分鐘和秒數不能超過 59: %q", value)
+		}
+		t.Seconds = t.Seconds*60 + n
+	}
+	return t, nil
+}
+
+// String 返回 ParseTimestamp 接受的 [h:]mm:ss 形式
+func (t Timestamp) String() string {
+	whole := int(t.Seconds)
+	hours, minutes := whole/3600, whole%3600/60
+	// 保留到毫秒，避免浮點誤差
+	sec := math.Round((t.Seconds-float64(hours*3600+minutes*60))*1000) / 1000
+	seconds := strconv.FormatFloat(sec, 'f', -1, 64)
+	if sec < 10 {
+		seconds = "0" + seconds
+	}
+
+	s := fmt.Sprintf("%d:%s", minutes, seconds)
+	if hours > 0 {
+		s = fmt.Sprintf("%d:%02d:%s", hours, minutes, seconds)
+	}
+	if t.FromEnd {
+		s = "-" + s
+	}
+	return s
+}
+
+// Clip 要截取的時間範圍
+type Clip struct {
+	Start Timestamp
+	End   Timestamp // End 表示到視頻結尾
+}
+
+// ParseClip 解析 "開始-結束" 形式的範圍，例如 12:30-15:45、90-120、-5:00-inf
+// 結束為空或 inf 時到視頻結尾
+func ParseClip(value string) (Clip, error) {
+	s := strings.TrimSpace(value)
+	// 開始時間可以是負數，從第二個字符開始找分隔符
+	i := -1
+	if len(s) > 1 {
+		i = strings.Index(s[1:], "-")
+	}
+	if i < 0 {
+		return Clip{}, fmt.Errorf("時間範圍應為 開始-結束，例如 12:30-15:45: %q", value)
+	}
+	start, end := s[:i+1], s[i+2:]
+
+	clip := Clip{End: End}
+	var err error
+	if clip.Start, err = ParseTimestamp(start); err != nil {
+		return Clip{}, err
+	}
+	if e := strings.TrimSpace(end); e != "" && e != "inf" {
+		if clip.End, err = ParseTimestamp(e); err != nil {
+			return Clip{}, err
+		}
+	}
+	if err := clip.Validate(); err != nil {
+		return Clip{}, fmt.Errorf("時間範圍 %q: %v", value, err)
+	}
+	return clip, nil
+}
+
+// Validate 檢查開始時間在結束時間之前，開始和結束分別從頭和從結尾計算時無法在下載前檢查
+func (c Clip) Validate() error {
+	switch {
+	case c.Start == End:
+		return fmt.Errorf("開始時間不能是視頻結尾")
+	case c.End == End:
+		return nil
+	case !c.Start.FromEnd && !c.End.FromEnd && c.Start.Seconds >= c.End.Seconds,
+		c.Start.FromEnd && c.End.FromEnd && c.Start.Seconds <= c.End.Seconds:
+		return fmt.Errorf("開始時間必須早於結束時間")
+	}
+	return nil
+}
+
+// String 返回 ParseClip 接受的形式
+func (c Clip) String() string {
+	end := "inf"
+	if c.End != End {
+		end = c.End.String()
+	}
+	return c.Start.String() + "-" + end
+}
+
+// Section 返回 yt-dlp --download-sections 的參數
+func (c Clip) Section() string {
+	return "*" + c.String()
+}
+
+// ParseClips 解析用 ; 分隔的多個時間範圍
+func ParseClips(value string) ([]Clip, error) {
+	var clips []Clip
+	for _, spec := range strings.Split(value, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		clip, err := ParseClip(spec)
+		if err != nil {
+			return nil, err
+		}
+		clips = append(clips, clip)
+	}
+	return clips, nil
+}
+
+// FormatClips 把時間範圍列表格式化為 ParseClips 接受的字符串
+func FormatClips(clips []Clip) string {
+	specs := make([]string, len(clips))
+	for i, c := range clips {
+		specs[i] = c.String()
+	}
+	return strings.Join(specs, ";")
+}
+
+// ClipTemplate 截取片段時在模板的擴展名前加上 ClipSuffix，避免多個片段的文件名相同
+func (c *Config) ClipTemplate(template string) string {
+	if len(c.Clips) == 0 {
+		return template
+	}
+	if base, ok := strings.CutSuffix(template, ".%(ext)s"); ok {
+		return base + ClipSuffix + ".%(ext)s"
+	}
+	return template + ClipSuffix
+}
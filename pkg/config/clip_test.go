@@ -0,0 +1,102 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Timestamp
+		str      string
+	}{
+		{"90", Timestamp{Seconds: 90}, "1:30"},
+		{"12:30", Timestamp{Seconds: 750}, "12:30"},
+		{"1:02:03", Timestamp{Seconds: 3723}, "1:02:03"},
+		{"00:00:05.5", Timestamp{Seconds: 5.5}, "0:05.5"},
+		{"-5:00", Timestamp{Seconds: 300, FromEnd: true}, "-5:00"},
+		{"-30", Timestamp{Seconds: 30, FromEnd: true}, "-0:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ts, err := ParseTimestamp(tt.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if ts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, ts)
+			}
+			if ts.String() != tt.str {
+				t.Errorf("Expected %q, got %q", tt.str, ts.String())
+			}
+		})
+	}
+
+	for _, input := range []string{"", "-", "abc", "1:2:3:4", "12:60", "1:75:00", "--5", "+5", "1.5:00", "inf"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			if _, err := ParseTimestamp(input); err == nil {
+				t.Errorf("Expected error for %q", input)
+			}
+		})
+	}
+}
+
+func TestParseClips(t *testing.T) {
+	clips, err := ParseClips("12:30-15:45; 3600-inf;-5:00--1:00;10:00-")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []Clip{
+		{Start: Timestamp{Seconds: 750}, End: Timestamp{Seconds: 945}},
+		{Start: Timestamp{Seconds: 3600}, End: End},
+		{Start: Timestamp{Seconds: 300, FromEnd: true}, End: Timestamp{Seconds: 60, FromEnd: true}},
+		{Start: Timestamp{Seconds: 600}, End: End},
+	}
+	if len(clips) != len(expected) {
+		t.Fatalf("Expected %d clips, got %v", len(expected), clips)
+	}
+	for i := range expected {
+		if clips[i] != expected[i] {
+			t.Errorf("Expected clip %d to be %+v, got %+v", i, expected[i], clips[i])
+		}
+	}
+	if got := FormatClips(clips); got != "12:30-15:45;1:00:00-inf;-5:00--1:00;10:00-inf" {
+		t.Errorf("Unexpected formatted clips: %s", got)
+	}
+	if got := clips[2].Section(); got != "*-5:00--1:00" {
+		t.Errorf("Expected yt-dlp section, got %s", got)
+	}
+
+	invalid := map[string]string{
+		"missing end":          "12:30",
+		"end before start":     "15:45-12:30",
+		"empty range":          "90-90",
+		"from end reversed":    "-1:00--5:00",
+		"start at end":         "-0-inf",
+		"bad timestamp":        "12:30-abc",
+		"one of several":       "1-2;5-3",
+		"seconds out of range": "0:61-2:00",
+	}
+	for name, value := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseClips(value); err == nil {
+				t.Errorf("Expected error for %q", value)
+			}
+		})
+	}
+}
+
+func TestClipTemplate(t *testing.T) {
+	cfg := NewConfig()
+	if got := cfg.ClipTemplate("%(title)s.%(ext)s"); got != "%(title)s.%(ext)s" {
+		t.Errorf("Expected template unchanged without clips, got %s", got)
+	}
+	cfg.Clips = []Clip{{Start: Timestamp{Seconds: 750}, End: End}}
+	if got := cfg.ClipTemplate("%(title)s.%(ext)s"); got != "%(title)s"+ClipSuffix+".%(ext)s" {
+		t.Errorf("Expected suffix before extension, got %s", got)
+	}
+	if got := cfg.ClipTemplate("%(id)s"); !strings.HasSuffix(got, ClipSuffix) {
+		t.Errorf("Expected suffix appended, got %s", got)
+	}
+}
@@ -1,16 +1,131 @@
 package config
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"time"
+)
 
 // Config 應用配置
 type Config struct {
 	OutputDir      string
+	Preset         string // 最後應用的預設名稱，見 Presets
 	AudioFormat    string
-	AudioQuality   string
-	Bitrate        string
+	AudioQuality   string // VBR 質量 0-10，0 為最好，Bitrate 不為空時不使用
+	Bitrate        string // 固定比特率，例如 320k，為空時使用 VBR
 	OutputTemplate string
+	Timeout        time.Duration // 單次下載的超時時間，0 表示不限制
+	Playlist       bool          // 是否下載整個播放列表
+	Verbose        bool          // 是否讓 yt-dlp 輸出調試信息
+
+	// 播放列表模式的選項
+	PlaylistItems    string // 條目範圍，例如 "1-10,15"
+	PlaylistReverse  bool   // 倒序下載
+	PlaylistMaxItems int    // 最多下載的條目數，0 表示不限制
+	PlaylistTemplate string // 播放列表條目的輸出模板，相對於輸出目錄
+
+	ArchivePath string // 下載存檔路徑，為空時使用輸出目錄下的 DefaultArchiveName
+	Force       bool   // 忽略下載存檔，重新下載已完成的視頻
+
+	// 標籤
+	Tags     bool      // 下載後寫入標題、藝術家、專輯等標籤
+	TagGenre string    // 寫入的流派，為空時使用 yt-dlp 提供的 genre
+	TagRules []TagRule // 從 yt-dlp 字段解析標籤的規則，按順序應用
+
+	// 封面
+	Cover       bool // 下載後把視頻縮略圖嵌入為封面（mp3、m4a、flac）
+	CoverSquare bool // 居中裁剪為正方形
+	CoverSize   int  // 封面的最大邊長（像素），0 表示不縮放
+	CoverJPEG   bool // 把 WebP 縮略圖轉為 JPEG
+
+	// SponsorBlock
+	SponsorBlock    []string // 移除的 SponsorBlock 片段類別，見 SponsorBlockCategories，為空時不移除
+	SponsorBlockAPI string   // SponsorBlock API 的地址
+
+	// 修剪
+	TrimSilence      bool          // 去掉開頭和結尾的靜音
+	SilenceThreshold float64       // 低於此音量（dB）視為靜音
+	SilenceDuration  time.Duration // 持續超過此時長才視為靜音
+	FadeIn           time.Duration // 淡入時長，0 表示不淡入
+	FadeOut          time.Duration // 淡出時長，0 表示不淡出
+	MaxDuration      time.Duration // 最長保留的時長，0 表示不限制
+
+	// 響度
+	Loudness         string  // 響度處理方式，見 LoudnessModes，為空時不處理
+	LoudnessTarget   float64 // loudnorm 的目標綜合響度（LUFS）
+	LoudnessTruePeak float64 // loudnorm 的最大真峰值（dBTP）
+	LoudnessRange    float64 // loudnorm 的目標響度範圍（LU）
+
+	// 按章節拆分
+	SplitChapters   bool   // 按視頻的章節把音頻拆分為多個文件
+	ChapterPlaylist string // 拆分後附帶的播放列表格式，見 ChapterPlaylists，為空時不生成
+
+	// Clips 只下載的時間範圍，每個範圍輸出一個文件，文件名帶上 ClipSuffix
+	Clips []Clip
+
+	// 外部命令的路徑，為空時依次在環境變量、程序所在目錄下的 tools 目錄和 PATH 中查找
+	YtDlpPath   string
+	FFmpegPath  string // 也會通過 --ffmpeg-location 傳給 yt-dlp
+	FFprobePath string
+
+	// 依賴的最低版本，為空時不檢查
+	MinYtDlpVersion  string
+	MinFFmpegVersion string
+
+	// 下載前的磁盤空間檢查
+	SpaceCheck    string // 可用空間不足時的處理方式，見 SpaceChecks
+	MinFreeSpace  int64  // 下載後輸出目錄所在分區至少保留的字節數
+	SpaceEstimate bool   // 下載單個視頻前先用 yt-dlp 獲取大小估算需要的空間，會多運行一次 yt-dlp
+
+	// Outputs 多格式輸出，不為空時只下載一次源音頻，再分別轉碼為每種格式，
+	// 此時 AudioFormat、AudioQuality 和 Bitrate 不使用
+	Outputs []Output
 }
 
+// LoudnessModes 響度處理方式：loudnorm 兩遍處理重新編碼音頻，replaygain 只寫入 ReplayGain 標籤
+var LoudnessModes = []string{"loudnorm", "replaygain"}
+
+// SponsorBlockCategories SponsorBlock 的片段類別
+var SponsorBlockCategories = []string{"sponsor", "intro", "outro", "selfpromo", "preview", "filler", "interaction", "music_offtopic"}
+
+// DefaultSponsorBlockAPI SponsorBlock 官方 API 的地址
+const DefaultSponsorBlockAPI = "https://sponsor.ajay.app"
+
+// ChapterPlaylists 按章節拆分後可以生成的播放列表格式
+var ChapterPlaylists = []string{"m3u", "cue"}
+
+// SpaceChecks 可用空間不足時的處理方式：refuse 不下載，warn 下載但給出警告，off 不檢查
+var SpaceChecks = []string{SpaceCheckRefuse, SpaceCheckWarn, SpaceCheckOff}
+
+// SpaceCheck 的取值
+const (
+	SpaceCheckRefuse = "refuse"
+	SpaceCheckWarn   = "warn"
+	SpaceCheckOff    = "off"
+)
+
+// DefaultMinFreeSpace 默認在輸出目錄所在分區保留的空間
+const DefaultMinFreeSpace = 200 << 20
+
+// 外部命令的默認名稱
+const (
+	YtDlpCommand   = "yt-dlp"
+	FFmpegCommand  = "ffmpeg"
+	FFprobeCommand = "ffprobe"
+)
+
+// DefaultMinYtDlpVersion 默認要求的最低 yt-dlp 版本，支持 --download-sections 等用到的選項
+// YouTube 經常改版，過舊的 yt-dlp 是下載失敗的最常見原因
+const DefaultMinYtDlpVersion = "2023.03.04"
+
+// DefaultMinFFmpegVersion 默認要求的最低 ffmpeg 版本
+const DefaultMinFFmpegVersion = "4.0"
+
+// DefaultArchiveName 默認下載存檔文件名
+const DefaultArchiveName = "download-archive.txt"
+
+// DefaultPlaylistTemplate 默認按播放列表標題分目錄，文件名帶序號
+var DefaultPlaylistTemplate = filepath.Join("%(playlist_title)s", "%(playlist_index)s - %(title)s.%(ext)s")
+
 // NewConfig 創建默認配置
 func NewConfig() *Config {
 	outputDir := "output"
@@ -20,6 +135,20 @@ func NewConfig() *Config {
 		AudioQuality:   "0",
 		Bitrate:        "320k",
 		OutputTemplate: filepath.Join(outputDir, "%(title)s.%(ext)s"),
+
+		PlaylistTemplate: DefaultPlaylistTemplate,
+		Tags:             true,
+		CoverJPEG:        true,
+		SponsorBlockAPI:  DefaultSponsorBlockAPI,
+		SilenceThreshold: -50,
+		SilenceDuration:  500 * time.Millisecond,
+		LoudnessTarget:   -16,
+		LoudnessTruePeak: -1.5,
+		LoudnessRange:    11,
+		MinYtDlpVersion:  DefaultMinYtDlpVersion,
+		MinFFmpegVersion: DefaultMinFFmpegVersion,
+		SpaceCheck:       SpaceCheckRefuse,
+		MinFreeSpace:     DefaultMinFreeSpace,
 	}
 }
 
@@ -30,8 +159,64 @@ func (c *Config) WithOutputDir(dir string) *Config {
 	return c
 }
 
+// WithOutputTemplate 設置 yt-dlp 輸出模板，相對路徑以輸出目錄為根
+func (c *Config) WithOutputTemplate(template string) *Config {
+	if !filepath.IsAbs(template) {
+		template = filepath.Join(c.OutputDir, template)
+	}
+	c.OutputTemplate = template
+	return c
+}
+
 // WithBitrate 設置比特率
 func (c *Config) WithBitrate(bitrate string) *Config {
 	c.Bitrate = bitrate
 	return c
 }
+
+// WithTimeout 設置單次下載的超時時間
+func (c *Config) WithTimeout(timeout time.Duration) *Config {
+	c.Timeout = timeout
+	return c
+}
+
+// WithPlaylist 設置是否下載整個播放列表
+func (c *Config) WithPlaylist(playlist bool) *Config {
+	c.Playlist = playlist
+	return c
+}
+
+// YtDlp 返回運行 yt-dlp 使用的路徑，未指定時為命令名
+func (c *Config) YtDlp() string {
+	return commandPath(c.YtDlpPath, YtDlpCommand)
+}
+
+// FFmpeg 返回運行 ffmpeg 使用的路徑，未指定時為命令名
+func (c *Config) FFmpeg() string {
+	return commandPath(c.FFmpegPath, FFmpegCommand)
+}
+
+// FFprobe 返回運行 ffprobe 使用的路徑，未指定時為命令名
+func (c *Config) FFprobe() string {
+	return commandPath(c.FFprobePath, FFprobeCommand)
+}
+
+func commandPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path
+}
+
+// Trimming 是否需要修剪靜音、淡入淡出或限制時長
+func (c *Config) Trimming() bool {
+	return c.TrimSilence || c.FadeIn > 0 || c.FadeOut > 0 || c.MaxDuration > 0
+}
+
+// ArchiveFile 返回下載存檔的路徑
+func (c *Config) ArchiveFile() string {
+	if c.ArchivePath != "" {
+		return c.ArchivePath
+	}
+	return filepath.Join(c.OutputDir, DefaultArchiveName)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -57,6 +58,66 @@ func TestWithBitrate(t *testing.T) {
 	}
 }
 
+func TestWithTimeout(t *testing.T) {
+	cfg := NewConfig()
+
+	if cfg.Timeout != 0 {
+		t.Errorf("Expected default Timeout to be 0, got %v", cfg.Timeout)
+	}
+
+	cfg.WithTimeout(5 * time.Minute)
+
+	if cfg.Timeout != 5*time.Minute {
+		t.Errorf("Expected Timeout to be 5m, got %v", cfg.Timeout)
+	}
+}
+
+func TestWithOutputTemplate(t *testing.T) {
+	t.Run("relative template", func(t *testing.T) {
+		cfg := NewConfig().WithOutputDir("music").WithOutputTemplate("%(uploader)s/%(title)s.%(ext)s")
+
+		expected := filepath.Join("music", "%(uploader)s", "%(title)s.%(ext)s")
+		if cfg.OutputTemplate != expected {
+			t.Errorf("Expected OutputTemplate to be '%s', got '%s'", expected, cfg.OutputTemplate)
+		}
+	})
+
+	t.Run("absolute template", func(t *testing.T) {
+		template := filepath.Join(t.TempDir(), "%(id)s.%(ext)s")
+		cfg := NewConfig().WithOutputTemplate(template)
+
+		if cfg.OutputTemplate != template {
+			t.Errorf("Expected OutputTemplate to be '%s', got '%s'", template, cfg.OutputTemplate)
+		}
+	})
+}
+
+func TestWithPlaylist(t *testing.T) {
+	cfg := NewConfig()
+
+	if cfg.Playlist {
+		t.Error("Expected Playlist to be disabled by default")
+	}
+
+	if !cfg.WithPlaylist(true).Playlist {
+		t.Error("Expected Playlist to be enabled")
+	}
+}
+
+func TestArchiveFile(t *testing.T) {
+	cfg := NewConfig().WithOutputDir("music")
+
+	expected := filepath.Join("music", DefaultArchiveName)
+	if cfg.ArchiveFile() != expected {
+		t.Errorf("Expected archive file '%s', got '%s'", expected, cfg.ArchiveFile())
+	}
+
+	cfg.ArchivePath = "/tmp/archive.txt"
+	if cfg.ArchiveFile() != "/tmp/archive.txt" {
+		t.Errorf("Expected configured archive file, got '%s'", cfg.ArchiveFile())
+	}
+}
+
 func TestConfigChaining(t *testing.T) {
 	cfg := NewConfig().
 		WithOutputDir("downloads").
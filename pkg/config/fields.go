@@ -0,0 +1,256 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field 一個可以從配置文件、環境變量和命令行設置的配置項
+type field struct {
+	key  string // 配置文件中的鍵，環境變量為 YT2MP3_ 加大寫的鍵
+	get  func(c *Config) string
+	set  func(c *Config, value string) error
+	bare bool // 寫入配置文件時不加引號（布爾值和數字）
+}
+
+// fields 所有配置項，按此順序應用（output_dir 必須在 output_template 之前，preset 必須在音頻參數之前）
+var fields = []field{
+	{key: "output_dir", get: func(c *Config) string { return c.OutputDir }, set: func(c *Config, v string) error {
+		c.WithOutputDir(v)
+		return nil
+	}},
+	{key: "output_template", get: outputTemplate, set: func(c *Config, v string) error {
+		c.WithOutputTemplate(v)
+		return nil
+	}},
+	{key: "preset", get: func(c *Config) string { return c.Preset }, set: func(c *Config, v string) error {
+		if v == "" {
+			c.Preset = ""
+			return nil
+		}
+		return c.WithPreset(v)
+	}},
+	stringField("audio_format", func(c *Config) *string { return &c.AudioFormat }),
+	stringField("audio_quality", func(c *Config) *string { return &c.AudioQuality }),
+	stringField("bitrate", func(c *Config) *string { return &c.Bitrate }),
+	durationField("timeout", func(c *Config) *time.Duration { return &c.Timeout }),
+	boolField("verbose", func(c *Config) *bool { return &c.Verbose }),
+	boolField("playlist", func(c *Config) *bool { return &c.Playlist }),
+	stringField("playlist_items", func(c *Config) *string { return &c.PlaylistItems }),
+	boolField("playlist_reverse", func(c *Config) *bool { return &c.PlaylistReverse }),
+	intField("playlist_max_items", func(c *Config) *int { return &c.PlaylistMaxItems }),
+	stringField("playlist_template", func(c *Config) *string { return &c.PlaylistTemplate }),
+	stringField("archive_path", func(c *Config) *string { return &c.ArchivePath }),
+	boolField("force", func(c *Config) *bool { return &c.Force }),
+	boolField("tags", func(c *Config) *bool { return &c.Tags }),
+	stringField("tag_genre", func(c *Config) *string { return &c.TagGenre }),
+	{key: "tag_rules", get: func(c *Config) string { return FormatTagRules(c.TagRules) }, set: func(c *Config, v string) error {
+		rules, err := ParseTagRules(v)
+		if err != nil {
+			return err
+		}
+		c.TagRules = rules
+		return nil
+	}},
+	boolField("cover", func(c *Config) *bool { return &c.Cover }),
+	boolField("cover_square", func(c *Config) *bool { return &c.CoverSquare }),
+	intField("cover_size", func(c *Config) *int { return &c.CoverSize }),
+	boolField("cover_jpeg", func(c *Config) *bool { return &c.CoverJPEG }),
+	{key: "sponsorblock", get: func(c *Config) string { return strings.Join(c.SponsorBlock, ",") }, set: func(c *Config, v string) error {
+		c.SponsorBlock = splitList(v)
+		return nil
+	}},
+	stringField("sponsorblock_api", func(c *Config) *string { return &c.SponsorBlockAPI }),
+	boolField("trim_silence", func(c *Config) *bool { return &c.TrimSilence }),
+	floatField("silence_threshold", func(c *Config) *float64 { return &c.SilenceThreshold }),
+	durationField("silence_duration", func(c *Config) *time.Duration { return &c.SilenceDuration }),
+	durationField("fade_in", func(c *Config) *time.Duration { return &c.FadeIn }),
+	durationField("fade_out", func(c *Config) *time.Duration { return &c.FadeOut }),
+	durationField("max_duration", func(c *Config) *time.Duration { return &c.MaxDuration }),
+	stringField("loudness", func(c *Config) *string { return &c.Loudness }),
+	floatField("loudness_target", func(c *Config) *float64 { return &c.LoudnessTarget }),
+	floatField("loudness_true_peak", func(c *Config) *float64 { return &c.LoudnessTruePeak }),
+	floatField("loudness_range", func(c *Config) *float64 { return &c.LoudnessRange }),
+	boolField("split_chapters", func(c *Config) *bool { return &c.SplitChapters }),
+	stringField("chapter_playlist", func(c *Config) *string { return &c.ChapterPlaylist }),
+	{key: "clips", get: func(c *Config) string { return FormatClips(c.Clips) }, set: func(c *Config, v string) error {
+		clips, err := ParseClips(v)
+		if err != nil {
+			return err
+		}
+		c.Clips = clips
+		return nil
+	}},
+	stringField("ytdlp_path", func(c *Config) *string { return &c.YtDlpPath }),
+	stringField("ffmpeg_path", func(c *Config) *string { return &c.FFmpegPath }),
+	stringField("ffprobe_path", func(c *Config) *string { return &c.FFprobePath }),
+	stringField("min_ytdlp_version", func(c *Config) *string { return &c.MinYtDlpVersion }),
+	stringField("min_ffmpeg_version", func(c *Config) *string { return &c.MinFFmpegVersion }),
+	stringField("space_check", func(c *Config) *string { return &c.SpaceCheck }),
+	sizeField("min_free_space", func(c *Config) *int64 { return &c.MinFreeSpace }),
+	boolField("space_estimate", func(c *Config) *bool { return &c.SpaceEstimate }),
+	{key: "outputs", get: func(c *Config) string { return FormatOutputs(c.Outputs) }, set: func(c *Config, v string) error {
+		outputs, err := ParseOutputs(v)
+		if err != nil {
+			return err
+		}
+		c.Outputs = outputs
+		return nil
+	}},
+}
+
+// Keys 返回所有配置項的鍵
+func Keys() []string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// EnvName 返回配置項對應的環境變量名
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// lookupField 按鍵查找配置項
+func lookupField(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Set 按鍵設置配置項
+func (c *Config) Set(key, value string) error {
+	f, ok := lookupField(key)
+	if !ok {
+		return fmt.Errorf("未知的配置項: %s", key)
+	}
+	if err := f.set(c, value); err != nil {
+		return fmt.Errorf("配置項 %s 的值無效: %v", key, err)
+	}
+	return nil
+}
+
+// Get 按鍵讀取配置項
+func (c *Config) Get(key string) (string, bool) {
+	f, ok := lookupField(key)
+	if !ok {
+		return "", false
+	}
+	return f.get(c), true
+}
+
+// outputTemplate 返回相對於輸出目錄的模板，便於寫回配置文件
+func outputTemplate(c *Config) string {
+	if rel, err := filepath.Rel(c.OutputDir, c.OutputTemplate); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return c.OutputTemplate
+}
+
+// splitList 解析逗號分隔的列表，忽略空項
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func stringField(key string, ptr func(c *Config) *string) field {
+	return field{
+		key: key,
+		get: func(c *Config) string { return *ptr(c) },
+		set: func(c *Config, v string) error {
+			*ptr(c) = v
+			return nil
+		},
+	}
+}
+
+func boolField(key string, ptr func(c *Config) *bool) field {
+	return field{
+		key:  key,
+		bare: true,
+		get:  func(c *Config) string { return strconv.FormatBool(*ptr(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("應為 true 或 false: %q", v)
+			}
+			*ptr(c) = b
+			return nil
+		},
+	}
+}
+
+func intField(key string, ptr func(c *Config) *int) field {
+	return field{
+		key:  key,
+		bare: true,
+		get:  func(c *Config) string { return strconv.Itoa(*ptr(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("應為整數: %q", v)
+			}
+			*ptr(c) = n
+			return nil
+		},
+	}
+}
+
+func floatField(key string, ptr func(c *Config) *float64) field {
+	return field{
+		key:  key,
+		bare: true,
+		get:  func(c *Config) string { return strconv.FormatFloat(*ptr(c), 'f', -1, 64) },
+		set: func(c *Config, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("應為數字: %q", v)
+			}
+			*ptr(c) = f
+			return nil
+		},
+	}
+}
+
+func sizeField(key string, ptr func(c *Config) *int64) field {
+	return field{
+		key: key,
+		get: func(c *Config) string { return FormatSize(*ptr(c)) },
+		set: func(c *Config, v string) error {
+			n, err := ParseSize(v)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = n
+			return nil
+		},
+	}
+}
+
+func durationField(key string, ptr func(c *Config) *time.Duration) field {
+	return field{
+		key: key,
+		get: func(c *Config) string { return ptr(c).String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("應為時長，例如 30s、10m: %q", v)
+			}
+			*ptr(c) = d
+			return nil
+		},
+	}
+}
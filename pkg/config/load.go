@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvPrefix 環境變量前綴，例如 YT2MP3_BITRATE
+	EnvPrefix = "YT2MP3_"
+	// AppDir XDG 配置目錄下的子目錄名
+	AppDir = "yt2mp3"
+	// FileName 用戶配置文件名
+	FileName = "config.toml"
+	// ProjectFileName 項目本地配置文件名，在工作目錄中查找
+	ProjectFileName = ".yt2mp3.toml"
+)
+
+// SourceKind 配置值的來源類型
+type SourceKind string
+
+const (
+	SourceDefault SourceKind = "default"
+	SourceFile    SourceKind = "file"
+	SourceEnv     SourceKind = "env"
+	SourceFlag    SourceKind = "flag"
+)
+
+// Source 配置值的來源
+type Source struct {
+	Kind     SourceKind
+	Location string // 文件路徑、環境變量名或命令行參數名
+}
+
+// String 實現 fmt.Stringer 接口
+func (s Source) String() string {
+	if s.Location == "" {
+		return string(s.Kind)
+	}
+	return fmt.Sprintf("%s %s", s.Kind, s.Location)
+}
+
+// LoadOptions 分層加載配置的選項
+type LoadOptions struct {
+	ConfigFile string              // 明確指定的配置文件，代替 XDG 目錄中的用戶配置文件
+	WorkDir    string              // 查找項目配置文件的目錄，默認為當前目錄
+	Getenv     func(string) string // 讀取環境變量，默認為 os.Getenv
+	Flags      map[string]string   // 命令行設置的配置項，鍵為配置項的鍵
+	FlagNames  map[string]string   // 配置項對應的命令行參數名，用於顯示來源
+}
+
+// Loaded 加載結果，記錄每個配置項的來源
+type Loaded struct {
+	Config  *Config
+	Sources map[string]Source
+	Files   []string // 實際讀取的配置文件，按優先級從低到高
+}
+
+// setting 一個配置項在某一層中的值
+type setting struct {
+	value  string
+	source Source
+	rank   int // 優先級，越大越高
+}
+
+// Load 按 默認值 < 用戶配置文件 < 項目配置文件 < 環境變量 < 命令行 的順序合併配置
+func Load(opts LoadOptions) (*Loaded, error) {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+
+	settings := make(map[string]setting)
+	loaded := &Loaded{Sources: make(map[string]Source)}
+	rank := 0
+	put := func(key, value string, source Source) {
+		settings[key] = setting{value, source, rank}
+	}
+
+	// 配置文件
+	var files []string
+	if opts.ConfigFile != "" {
+		files = []string{opts.ConfigFile}
+	} else {
+		files = UserConfigPaths(getenv)
+	}
+	files = append(files, filepath.Join(workDir, ProjectFileName))
+	for _, path := range files {
+		values, err := ReadFile(path)
+		if os.IsNotExist(err) && path != opts.ConfigFile {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		loaded.Files = append(loaded.Files, path)
+		rank++
+		for key, value := range values {
+			put(key, value, Source{SourceFile, path})
+		}
+	}
+
+	// 環境變量
+	rank++
+	for _, f := range fields {
+		name := EnvName(f.key)
+		if value := getenv(name); value != "" {
+			put(f.key, value, Source{SourceEnv, name})
+		}
+	}
+
+	// 命令行
+	rank++
+	for key, value := range opts.Flags {
+		name := "-" + key
+		if flagName, ok := opts.FlagNames[key]; ok {
+			name = "-" + flagName
+		}
+		put(key, value, Source{SourceFlag, name})
+	}
+
+	cfg := NewConfig()
+	preset, hasPreset := settings["preset"]
+	for _, f := range fields {
+		s, ok := settings[f.key]
+		if hasPreset && isPresetKey(f.key) && (!ok || s.rank < preset.rank) {
+			// 較高層的預設覆蓋較低層單獨設置的音頻參數
+			loaded.Sources[f.key] = preset.source
+			continue
+		}
+		if !ok {
+			loaded.Sources[f.key] = Source{Kind: SourceDefault}
+			continue
+		}
+		if err := f.set(cfg, s.value); err != nil {
+			return nil, fmt.Errorf("%s: 配置項 %s 的值無效: %v", s.source, f.key, err)
+		}
+		loaded.Sources[f.key] = s.source
+	}
+	loaded.Config = cfg
+	return loaded, nil
+}
+
+// UserConfigPaths 返回 XDG 配置目錄中的配置文件路徑，按優先級從低到高
+// XDG_CONFIG_DIRS 中靠前的目錄優先級更高，XDG_CONFIG_HOME 最高
+func UserConfigPaths(getenv func(string) string) []string {
+	var paths []string
+
+	dirs := getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	systemDirs := filepath.SplitList(dirs)
+	for i := len(systemDirs) - 1; i >= 0; i-- {
+		if systemDirs[i] != "" {
+			paths = append(paths, filepath.Join(systemDirs[i], AppDir, FileName))
+		}
+	}
+
+	home := getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		if userHome, err := os.UserHomeDir(); err == nil {
+			home = filepath.Join(userHome, ".config")
+		}
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, AppDir, FileName))
+	}
+	return paths
+}
+
+// ReadFile 讀取 TOML 配置文件
+func ReadFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values, err := parseTOML(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+// parseTOML 解析 TOML 的一個子集：頂層的 key = value，
+// 值可以是字符串（"..." 或 '...'）、布爾值、整數或浮點數
+func parseTOML(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("第 %d 行: 不支持表 %s，所有配置項都在頂層", lineNo, line)
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("第 %d 行: 應為 key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if _, known := lookupField(key); !known {
+			return nil, fmt.Errorf("第 %d 行: 未知的配置項 %s", lineNo, key)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %v", lineNo, err)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// parseTOMLValue 解析值並去掉行尾註釋
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := closingQuote(raw)
+		if end < 0 {
+			return "", fmt.Errorf("字符串缺少結束引號: %s", raw)
+		}
+		if err := checkTrailing(raw[end+1:]); err != nil {
+			return "", err
+		}
+		return strconv.Unquote(raw[:end+1])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("字符串缺少結束引號: %s", raw)
+		}
+		if err := checkTrailing(raw[end+2:]); err != nil {
+			return "", err
+		}
+		return raw[1 : end+1], nil
+	}
+
+	value, _, _ := strings.Cut(raw, "#")
+	value = strings.TrimSpace(value)
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		return value, nil
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value, nil
+	}
+	return "", fmt.Errorf("字符串值需要加引號: %s", value)
+}
+
+// closingQuote 返回雙引號字符串結束引號的位置，跳過轉義字符
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// checkTrailing 值後面只允許註釋
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("值後面有多餘的內容: %s", rest)
+	}
+	return nil
+}
+
+// Dump 以 TOML 格式輸出生效的配置，每項後面註明來源
+func (l *Loaded) Dump(w io.Writer) {
+	for _, path := range l.Files {
+		fmt.Fprintf(w, "# 已讀取配置文件: %s\n", path)
+	}
+	for _, f := range fields {
+		value := f.get(l.Config)
+		if !f.bare {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(w, "%s = %s  # %s\n", f.key, value, l.Sources[f.key])
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile 在目錄中寫入配置文件，返回路徑
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+// testEnv 只包含給定變量的環境，XDG 目錄指向臨時目錄
+func testEnv(home, system string, vars map[string]string) func(string) string {
+	return func(name string) string {
+		switch name {
+		case "XDG_CONFIG_HOME":
+			return home
+		case "XDG_CONFIG_DIRS":
+			return system
+		}
+		return vars[name]
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	system := filepath.Join(root, "system")
+	work := filepath.Join(root, "work")
+
+	systemFile := writeFile(t, system, filepath.Join(AppDir, FileName), "bitrate = \"128k\"\naudio_format = \"opus\"\n")
+	userFile := writeFile(t, home, filepath.Join(AppDir, FileName), "# 用戶配置\nbitrate = \"192k\"\noutput_dir = \"music\"\nplaylist_max_items = 5\n")
+	projectFile := writeFile(t, work, ProjectFileName, "output_template = '%(uploader)s/%(title)s.%(ext)s'  # 按上傳者分目錄\n")
+
+	loaded, err := Load(LoadOptions{
+		WorkDir:   work,
+		Getenv:    testEnv(home, system, map[string]string{"YT2MP3_TIMEOUT": "10m", "YT2MP3_BITRATE": "256k"}),
+		Flags:     map[string]string{"bitrate": "320k", "verbose": "true"},
+		FlagNames: map[string]string{"verbose": "v"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	cfg := loaded.Config
+	if cfg.AudioFormat != "opus" || cfg.OutputDir != "music" || cfg.PlaylistMaxItems != 5 {
+		t.Errorf("Unexpected file values: %+v", cfg)
+	}
+	if expected := filepath.Join("music", "%(uploader)s", "%(title)s.%(ext)s"); cfg.OutputTemplate != expected {
+		t.Errorf("Expected OutputTemplate %s, got %s", expected, cfg.OutputTemplate)
+	}
+	if cfg.Timeout != 10*time.Minute {
+		t.Errorf("Expected Timeout 10m, got %s", cfg.Timeout)
+	}
+	if cfg.Bitrate != "320k" || !cfg.Verbose {
+		t.Errorf("Expected flags to win, got bitrate %s verbose %v", cfg.Bitrate, cfg.Verbose)
+	}
+
+	sources := map[string]Source{
+		"audio_format":    {SourceFile, systemFile},
+		"output_dir":      {SourceFile, userFile},
+		"output_template": {SourceFile, projectFile},
+		"timeout":         {SourceEnv, "YT2MP3_TIMEOUT"},
+		"bitrate":         {SourceFlag, "-bitrate"},
+		"verbose":         {SourceFlag, "-v"},
+		"audio_quality":   {Kind: SourceDefault},
+	}
+	for key, expected := range sources {
+		if got := loaded.Sources[key]; got != expected {
+			t.Errorf("Expected source of %s to be %q, got %q", key, expected, got)
+		}
+	}
+
+	if len(loaded.Files) != 3 || loaded.Files[0] != systemFile || loaded.Files[2] != projectFile {
+		t.Errorf("Unexpected files: %v", loaded.Files)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	writeFile(t, home, filepath.Join(AppDir, FileName), "bitrate = \"192k\"\n")
+	custom := writeFile(t, root, "custom.toml", "audio_format = \"flac\"\n")
+	getenv := testEnv(home, filepath.Join(root, "none"), nil)
+
+	t.Run("replaces user file", func(t *testing.T) {
+		loaded, err := Load(LoadOptions{ConfigFile: custom, WorkDir: root, Getenv: getenv})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if loaded.Config.AudioFormat != "flac" || loaded.Config.Bitrate != "320k" {
+			t.Errorf("Expected only the custom file to be read, got: %+v", loaded.Config)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load(LoadOptions{ConfigFile: filepath.Join(root, "missing.toml"), WorkDir: root, Getenv: getenv})
+		if err == nil {
+			t.Error("Expected error for missing config file")
+		}
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    string
+	}{
+		{"unknown key", "bitrat = \"320k\"\n", nil, "未知的配置項 bitrat"},
+		{"table", "[download]\n", nil, "不支持表"},
+		{"unquoted string", "audio_format = mp3\n", nil, "需要加引號"},
+		{"unterminated", "bitrate = \"320k\n", nil, "缺少結束引號"},
+		{"trailing", "bitrate = \"320k\" x\n", nil, "多餘的內容"},
+		{"bad bool", "force = 2\n", nil, "force"},
+		{"bad env", "", map[string]string{"YT2MP3_TIMEOUT": "soon"}, "env YT2MP3_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work := t.TempDir()
+			writeFile(t, work, ProjectFileName, tt.content)
+			_, err := Load(LoadOptions{WorkDir: work, Getenv: testEnv(work, work, tt.env)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestUserConfigPaths(t *testing.T) {
+	paths := UserConfigPaths(testEnv("/home/u/.config", "/etc/a:/etc/b", nil))
+	expected := []string{
+		filepath.Join("/etc/b", AppDir, FileName),
+		filepath.Join("/etc/a", AppDir, FileName),
+		filepath.Join("/home/u/.config", AppDir, FileName),
+	}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+}
+
+func TestDump(t *testing.T) {
+	work := t.TempDir()
+	project := writeFile(t, work, ProjectFileName, "force = true\n")
+	loaded, err := Load(LoadOptions{
+		WorkDir: work,
+		Getenv:  testEnv(work, work, nil),
+		Flags:   map[string]string{"output_dir": "music", "output_template": "%(id)s.%(ext)s"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	loaded.Dump(&buf)
+	out := buf.String()
+
+	for _, line := range []string{
+		"# 已讀取配置文件: " + project,
+		`output_dir = "music"  # flag -output_dir`,
+		`output_template = "%(id)s.%(ext)s"  # flag -output_template`,
+		`bitrate = "320k"  # default`,
+		`timeout = "0s"  # default`,
+		"force = true  # file " + project,
+		"playlist_max_items = 0  # default",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected dump to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	// 輸出的配置可以重新讀取
+	dumped := writeFile(t, work, "dumped.toml", out)
+	reloaded, err := Load(LoadOptions{ConfigFile: dumped, WorkDir: t.TempDir(), Getenv: testEnv(work, work, nil)})
+	if err != nil {
+		t.Fatalf("Expected dumped config to load, got: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.Config, loaded.Config) {
+		t.Errorf("Expected %+v, got %+v", loaded.Config, reloaded.Config)
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	cfg := NewConfig()
+	if err := cfg.Set("playlist", "true"); err != nil || !cfg.Playlist {
+		t.Errorf("Expected playlist to be set, got %v (err: %v)", cfg.Playlist, err)
+	}
+	if err := cfg.Set("nope", "x"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+	if err := cfg.Set("playlist_max_items", "many"); err == nil {
+		t.Error("Expected error for invalid int")
+	}
+	if v, ok := cfg.Get("bitrate"); !ok || v != "320k" {
+		t.Errorf("Expected bitrate 320k, got %q", v)
+	}
+	if _, ok := cfg.Get("nope"); ok {
+		t.Error("Expected unknown key not to be found")
+	}
+	if len(Keys()) != len(fields) {
+		t.Errorf("Expected %d keys, got %d", len(fields), len(Keys()))
+	}
+}
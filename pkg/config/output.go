@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Output 多格式輸出中的一種格式，同一次下載的源音頻分別轉碼為每種格式
+type Output struct {
+	Preset string // 預設名稱，見 Presets
+	// Path 輸出位置：為空時使用輸出目錄和輸出模板；包含 %( 時為輸出模板；
+	// 否則為輸出目錄，文件名沿用輸出模板。相對路徑以 OutputDir 為根
+	Path string
+}
+
+// OutputTemplateFields 多格式輸出的模板中可用的字段，下載器會向 yt-dlp 請求這些字段
+var OutputTemplateFields = []string{
+	"id", "title", "ext", "extractor_key", "uploader", "channel", "upload_date",
+	"playlist_title", "playlist_index",
+}
+
+// ParseOutputs 解析 "preset[=path];preset[=path]" 格式的輸出列表
+func ParseOutputs(value string) ([]Output, error) {
+	var outputs []Output
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name, path, _ := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if _, ok := LookupPreset(name); !ok {
+			return nil, fmt.Errorf("未知的預設 %q，可選: %s", name, strings.Join(PresetNames(), ", "))
+		}
+		outputs = append(outputs, Output{Preset: name, Path: strings.TrimSpace(path)})
+	}
+	return outputs, nil
+}
+
+// FormatOutputs 把輸出列表格式化為 ParseOutputs 接受的字符串
+func FormatOutputs(outputs []Output) string {
+	specs := make([]string, len(outputs))
+	for i, o := range outputs {
+		specs[i] = o.Preset
+		if o.Path != "" {
+			specs[i] += "=" + o.Path
+		}
+	}
+	return strings.Join(specs, ";")
+}
+
+// OutputPath 返回輸出的完整模板，默認模板為輸出模板，播放列表模式為播放列表模板
+func (c *Config) OutputPath(o Output) string {
+	base := outputTemplate(c)
+	if c.Playlist && c.PlaylistTemplate != "" {
+		base = c.PlaylistTemplate
+	}
+
+	path := o.Path
+	switch {
+	case path == "":
+		path = base
+	case !strings.Contains(path, "%("):
+		path = filepath.Join(path, base)
+	}
+	path = c.ClipTemplate(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.OutputDir, path)
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	outputs, err := ParseOutputs(" mp3-320; flac=archive ;opus-96=%(uploader)s/%(title)s.%(ext)s;")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := []Output{
+		{Preset: "mp3-320"},
+		{Preset: "flac", Path: "archive"},
+		{Preset: "opus-96", Path: "%(uploader)s/%(title)s.%(ext)s"},
+	}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("Expected %v, got %v", expected, outputs)
+	}
+	if got := FormatOutputs(outputs); got != "mp3-320;flac=archive;opus-96=%(uploader)s/%(title)s.%(ext)s" {
+		t.Errorf("Unexpected formatted outputs: %s", got)
+	}
+
+	if _, err := ParseOutputs("mp3-320;ogg"); err == nil {
+		t.Error("Expected error for unknown preset")
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	cfg := NewConfig().WithOutputDir("music")
+	abs := filepath.Join(t.TempDir(), "archive")
+
+	tests := []struct {
+		name     string
+		output   Output
+		playlist bool
+		expected string
+	}{
+		{"default", Output{Preset: "flac"}, false, filepath.Join("music", "%(title)s.%(ext)s")},
+		{"directory", Output{Preset: "flac", Path: "lossless"}, false, filepath.Join("music", "lossless", "%(title)s.%(ext)s")},
+		{"absolute directory", Output{Preset: "flac", Path: abs}, false, filepath.Join(abs, "%(title)s.%(ext)s")},
+		{"template", Output{Preset: "flac", Path: "%(id)s.%(ext)s"}, false, filepath.Join("music", "%(id)s.%(ext)s")},
+		{"playlist", Output{Preset: "flac", Path: "lossless"}, true, filepath.Join("music", "lossless", DefaultPlaylistTemplate)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Playlist = tt.playlist
+			if got := cfg.OutputPath(tt.output); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateOutputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		output Output
+		want   string
+	}{
+		{"unknown preset", Output{Preset: "mp4"}, "未知的預設"},
+		{"unsupported field", Output{Preset: "flac", Path: "%(artist)s.%(ext)s"}, "不支持: artist"},
+		{"escapes output dir", Output{Preset: "flac", Path: "../flac"}, "輸出目錄"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.Outputs = []Output{{Preset: "mp3-320"}, tt.output}
+
+			var invalid *ValidationError
+			if err := cfg.Validate(); !errors.As(err, &invalid) {
+				t.Fatalf("Expected ValidationError, got: %v", err)
+			}
+			if len(invalid.Errors) != 1 || invalid.Errors[0].Field != "outputs" || !strings.Contains(invalid.Errors[0].Msg, tt.want) {
+				t.Errorf("Expected outputs error containing %q, got: %v", tt.want, invalid)
+			}
+		})
+	}
+
+	cfg := NewConfig()
+	cfg.Outputs = []Output{{Preset: "flac", Path: filepath.Join(t.TempDir(), "flac")}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected absolute output dir to be valid, got: %v", err)
+	}
+}
+
+func TestPresetFFmpegArgs(t *testing.T) {
+	tests := map[string]string{
+		"mp3-320":  "-c:a libmp3lame -b:a 320k",
+		"mp3-v2":   "-c:a libmp3lame -q:a 2",
+		"opus-160": "-c:a libopus -b:a 160k",
+		"m4a-256":  "-c:a aac -b:a 256k",
+		"ogg-192":  "-c:a libvorbis -b:a 192k",
+		"flac":     "-c:a flac",
+		"wav":      "-c:a pcm_s16le",
+	}
+	for name, expected := range tests {
+		p, _ := LookupPreset(name)
+		if got := strings.Join(p.FFmpegArgs(), " "); got != expected {
+			t.Errorf("Expected %s args %q, got %q", name, expected, got)
+		}
+	}
+
+	vbr := Preset{AudioFormat: "vorbis", AudioQuality: "4"}
+	if got := strings.Join(vbr.FFmpegArgs(), " "); got != "-c:a libvorbis -q:a 6" {
+		t.Errorf("Expected vorbis quality to be inverted like yt-dlp, got %q", got)
+	}
+}
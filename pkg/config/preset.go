@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Preset 命名的輸出格式預設
+//
+// Bitrate 不為空時為固定比特率，yt-dlp 收到 --audio-quality 320K 後傳給 ffmpeg -b:a 320k；
+// Bitrate 為空時使用 VBR，AudioQuality 0-10 由 yt-dlp 轉換為編碼器的 -q:a。
+// 無損格式兩者都不使用。
+type Preset struct {
+	Name         string
+	Description  string
+	AudioFormat  string
+	AudioQuality string
+	Bitrate      string
+}
+
+// Presets 所有內置預設
+var Presets = []Preset{
+	{Name: "mp3-320", Description: "MP3 CBR 320kbps（默認）", AudioFormat: "mp3", AudioQuality: "0", Bitrate: "320k"},
+	{Name: "mp3-256", Description: "MP3 CBR 256kbps", AudioFormat: "mp3", AudioQuality: "0", Bitrate: "256k"},
+	{Name: "mp3-v0", Description: "MP3 VBR V0，約 245kbps", AudioFormat: "mp3", AudioQuality: "0"},
+	{Name: "mp3-v2", Description: "MP3 VBR V2，約 190kbps", AudioFormat: "mp3", AudioQuality: "2"},
+	{Name: "opus-160", Description: "Opus 160kbps（libopus 默認為 VBR）", AudioFormat: "opus", AudioQuality: "0", Bitrate: "160k"},
+	{Name: "opus-96", Description: "Opus 96kbps，適合語音和播客", AudioFormat: "opus", AudioQuality: "0", Bitrate: "96k"},
+	{Name: "m4a-256", Description: "AAC 256kbps，m4a 容器", AudioFormat: "m4a", AudioQuality: "0", Bitrate: "256k"},
+	{Name: "aac-192", Description: "AAC 192kbps，aac 裸流", AudioFormat: "aac", AudioQuality: "0", Bitrate: "192k"},
+	{Name: "ogg-192", Description: "Ogg Vorbis 192kbps", AudioFormat: "vorbis", AudioQuality: "0", Bitrate: "192k"},
+	{Name: "flac", Description: "FLAC 無損", AudioFormat: "flac", AudioQuality: "0"},
+	{Name: "wav", Description: "WAV 無損，未壓縮", AudioFormat: "wav", AudioQuality: "0"},
+	{Name: "alac", Description: "ALAC 無損，m4a 容器", AudioFormat: "alac", AudioQuality: "0"},
+}
+
+// presetKeys 預設會設置的配置項
+var presetKeys = []string{"audio_format", "audio_quality", "bitrate"}
+
+// losslessFormats 無損格式，不使用比特率和質量參數
+var losslessFormats = map[string]bool{"flac": true, "wav": true, "alac": true}
+
+// encoders 各格式使用的 ffmpeg 編碼器，與 yt-dlp 一致
+var encoders = map[string]string{
+	"mp3": "libmp3lame", "aac": "aac", "m4a": "aac", "opus": "libopus",
+	"vorbis": "libvorbis", "flac": "flac", "wav": "pcm_s16le", "alac": "alac",
+}
+
+// LookupPreset 按名稱查找預設
+func LookupPreset(name string) (Preset, bool) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// PresetNames 返回所有預設名稱
+func PresetNames() []string {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// WithPreset 應用預設，設置音頻格式、質量和比特率
+func (c *Config) WithPreset(name string) error {
+	p, ok := LookupPreset(name)
+	if !ok {
+		return fmt.Errorf("未知的預設 %q，可選: %s", name, strings.Join(PresetNames(), ", "))
+	}
+	c.Preset = p.Name
+	c.AudioFormat = p.AudioFormat
+	c.AudioQuality = p.AudioQuality
+	c.Bitrate = p.Bitrate
+	return nil
+}
+
+// Lossless 判斷輸出格式是否為無損格式
+func (c *Config) Lossless() bool {
+	return losslessFormats[c.AudioFormat]
+}
+
+// Extension 返回輸出文件的擴展名，與 yt-dlp 一致
+func (c *Config) Extension() string {
+	return extension(c.AudioFormat)
+}
+
+// Extension 返回預設輸出文件的擴展名
+func (p Preset) Extension() string {
+	return extension(p.AudioFormat)
+}
+
+// Encoder 返回預設使用的 ffmpeg 編碼器，best 保留原始編碼，返回空字符串
+func (p Preset) Encoder() string {
+	return encoders[p.AudioFormat]
+}
+
+// FFmpegArgs 返回把源音頻轉碼為此預設的 ffmpeg 編碼參數
+// VBR 質量 0-10 換算為編碼器 -q:a 的方式與 yt-dlp 相同
+func (p Preset) FFmpegArgs() []string {
+	args := []string{"-c:a", p.Encoder()}
+	switch {
+	case losslessFormats[p.AudioFormat]:
+		return args
+	case p.Bitrate != "":
+		return append(args, "-b:a", p.Bitrate)
+	}
+
+	q, _ := strconv.Atoi(p.AudioQuality)
+	switch p.AudioFormat {
+	case "mp3":
+		args = append(args, "-q:a", strconv.Itoa(q))
+	case "vorbis":
+		args = append(args, "-q:a", strconv.Itoa(10-q))
+	case "aac", "m4a":
+		args = append(args, "-q:a", strconv.FormatFloat(4-3.9*float64(q)/10, 'g', 3, 64))
+	}
+	return args
+}
+
+// extension 返回格式對應的擴展名
+func extension(format string) string {
+	switch format {
+	case "vorbis":
+		return "ogg"
+	case "alac":
+		return "m4a"
+	}
+	return format
+}
+
+// isPresetKey 判斷配置項是否由預設設置
+func isPresetKey(key string) bool {
+	for _, k := range presetKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWithPreset(t *testing.T) {
+	for _, p := range Presets {
+		t.Run(p.Name, func(t *testing.T) {
+			cfg := NewConfig()
+			if err := cfg.WithPreset(p.Name); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if cfg.Preset != p.Name || cfg.AudioFormat != p.AudioFormat || cfg.Bitrate != p.Bitrate {
+				t.Errorf("Expected preset %+v to be applied, got: %+v", p, cfg)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("Expected preset to be valid, got: %v", err)
+			}
+			if cfg.Lossless() && cfg.Bitrate != "" {
+				t.Errorf("Expected lossless preset without bitrate, got %q", cfg.Bitrate)
+			}
+		})
+	}
+
+	if err := NewConfig().WithPreset("mp3-999"); err == nil {
+		t.Error("Expected error for unknown preset")
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := map[string]string{"mp3": "mp3", "vorbis": "ogg", "alac": "m4a", "opus": "opus"}
+	for format, expected := range tests {
+		cfg := NewConfig()
+		cfg.AudioFormat = format
+		if got := cfg.Extension(); got != expected {
+			t.Errorf("Expected extension of %s to be %s, got %s", format, expected, got)
+		}
+	}
+}
+
+func TestLoadPreset(t *testing.T) {
+	work := t.TempDir()
+	home := filepath.Join(work, "home")
+	writeFile(t, home, filepath.Join(AppDir, FileName), "bitrate = \"192k\"\n")
+
+	t.Run("preset overrides lower layers", func(t *testing.T) {
+		loaded, err := Load(LoadOptions{
+			WorkDir: work,
+			Getenv:  testEnv(home, work, nil),
+			Flags:   map[string]string{"preset": "mp3-v0"},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if loaded.Config.Bitrate != "" || loaded.Config.AudioQuality != "0" {
+			t.Errorf("Expected VBR from preset, got bitrate %q", loaded.Config.Bitrate)
+		}
+		if source := loaded.Sources["bitrate"]; source.Kind != SourceFlag {
+			t.Errorf("Expected bitrate source to be the preset flag, got %s", source)
+		}
+	})
+
+	t.Run("higher layers override preset", func(t *testing.T) {
+		loaded, err := Load(LoadOptions{
+			WorkDir: work,
+			Getenv:  testEnv(home, work, map[string]string{"YT2MP3_PRESET": "opus-160"}),
+			Flags:   map[string]string{"bitrate": "128k"},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		cfg := loaded.Config
+		if cfg.AudioFormat != "opus" || cfg.Bitrate != "128k" {
+			t.Errorf("Expected opus at 128k, got %s at %s", cfg.AudioFormat, cfg.Bitrate)
+		}
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		_, err := Load(LoadOptions{WorkDir: work, Getenv: testEnv(home, work, nil), Flags: map[string]string{"preset": "mp5"}})
+		if err == nil {
+			t.Error("Expected error for unknown preset")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizeRe 數字加可選的 K、M、G、T 單位，單位後可以帶 B 或 iB
+var sizeRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]?)(?:I?B)?$`)
+
+// sizeUnits 單位的冪次，按 1024 換算
+const sizeUnits = "KMGT"
+
+// ParseSize 解析 "500M"、"2G"、"1.5GB" 或字節數，單位按 1024 換算
+func ParseSize(value string) (int64, error) {
+	m := sizeRe.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil {
+		return 0, fmt.Errorf("大小格式應為數字加 K、M、G 或 T，例如 500M: %q", value)
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	if m[2] != "" {
+		n *= math.Pow(1024, float64(strings.Index(sizeUnits, m[2])+1))
+	}
+	if n >= math.MaxInt64 {
+		return 0, fmt.Errorf("大小超出範圍: %q", value)
+	}
+	return int64(n), nil
+}
+
+// FormatSize 返回 ParseSize 接受的形式，使用能整除的最大單位，例如 "500M"
+func FormatSize(n int64) string {
+	unit := ""
+	for i := 0; i < len(sizeUnits) && n != 0 && n%1024 == 0; i++ {
+		n /= 1024
+		unit = sizeUnits[i : i+1]
+	}
+	return strconv.FormatInt(n, 10) + unit
+}
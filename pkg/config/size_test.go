@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		str      string
+	}{
+		{"0", 0, "0"},
+		{"1000", 1000, "1000"},
+		{"512K", 512 << 10, "512K"},
+		{"500M", 500 << 20, "500M"},
+		{"500mb", 500 << 20, "500M"},
+		{"2GiB", 2 << 30, "2G"},
+		{"1.5G", 1536 << 20, "1536M"},
+		{"1T", 1 << 40, "1T"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := ParseSize(tt.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if n != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, n)
+			}
+			if FormatSize(n) != tt.str {
+				t.Errorf("Expected %q, got %q", tt.str, FormatSize(n))
+			}
+		})
+	}
+
+	for _, input := range []string{"", "M", "-5M", "5X", "1.5.0G", "100000000T"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			if _, err := ParseSize(input); err == nil {
+				t.Errorf("Expected error for %q", input)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TagNames 可以寫入的標籤，與 ffmpeg -metadata 的鍵一致
+var TagNames = []string{"title", "artist", "album", "album_artist", "track", "date", "genre", "comment"}
+
+// ArtistTitleRule 把 "Artist - Title" 形式的標題拆分為藝術家和標題
+const ArtistTitleRule = `title:^(?P<artist>.+?)\s+[-–]\s+(?P<title>.+)$`
+
+// TagRule 從 yt-dlp 字段解析標籤的規則，類似 yt-dlp 的 --parse-metadata
+// Pattern 中的命名分組對應標籤名，例如 (?P<artist>.+)
+type TagRule struct {
+	Field   string // yt-dlp 字段名，例如 title
+	Pattern string
+}
+
+// Regexp 編譯規則的正則表達式，並檢查命名分組都是 TagNames 中的標籤
+func (r TagRule) Regexp() (*regexp.Regexp, error) {
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("正則表達式無效: %v", err)
+	}
+	named := 0
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if !isTagName(name) {
+			return nil, fmt.Errorf("未知的標籤 %s，可選: %s", name, strings.Join(TagNames, ", "))
+		}
+		named++
+	}
+	if named == 0 {
+		return nil, fmt.Errorf("需要至少一個命名分組，例如 (?P<artist>.+)")
+	}
+	return re, nil
+}
+
+// String 返回 "field:pattern" 形式
+func (r TagRule) String() string {
+	return r.Field + ":" + r.Pattern
+}
+
+// ParseTagRules 解析 "field:pattern;field:pattern" 格式的規則列表
+// 正則表達式中的 ; 寫作 \;，正則表達式中 \; 同樣匹配 ;，所以轉義保留在 Pattern 中
+func ParseTagRules(value string) ([]TagRule, error) {
+	var rules []TagRule
+	for _, spec := range splitUnescaped(value, ';') {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		field, pattern, ok := strings.Cut(spec, ":")
+		if !ok || field == "" || pattern == "" {
+			return nil, fmt.Errorf("標籤規則應為 字段:正則表達式: %q", spec)
+		}
+		rule := TagRule{Field: strings.TrimSpace(field), Pattern: pattern}
+		if _, err := rule.Regexp(); err != nil {
+			return nil, fmt.Errorf("標籤規則 %q: %v", spec, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// FormatTagRules 把規則列表格式化為 ParseTagRules 接受的字符串，正則表達式中的 ; 轉義為 \;
+func FormatTagRules(rules []TagRule) string {
+	specs := make([]string, len(rules))
+	for i, r := range rules {
+		specs[i] = r.Field + ":" + escapeSeparator(r.Pattern)
+	}
+	return strings.Join(specs, ";")
+}
+
+// splitUnescaped 按沒有用 \ 轉義的 sep 分割，轉義序列原樣保留
+func splitUnescaped(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++ // 跳過被轉義的字符
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// escapeSeparator 轉義正則表達式中沒有轉義的 ;，與 splitUnescaped 對應
+func escapeSeparator(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteString(pattern[i : i+2])
+			i++
+		case c == ';':
+			b.WriteString(`\;`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isTagName 判斷是否為可寫入的標籤
+func isTagName(name string) bool {
+	for _, n := range TagNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTagRules(t *testing.T) {
+	rules, err := ParseTagRules(ArtistTitleRule + "; uploader:^(?P<album_artist>.+)$")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Field != "title" || rules[1].Field != "uploader" {
+		t.Errorf("Unexpected rules: %v", rules)
+	}
+	if got := FormatTagRules(rules); got != ArtistTitleRule+";uploader:^(?P<album_artist>.+)$" {
+		t.Errorf("Unexpected formatted rules: %s", got)
+	}
+
+	t.Run("escaped separator", func(t *testing.T) {
+		value := `title:^(?P<artist>[^\;]+)\;\s*(?P<title>.+)$;uploader:^(?P<album>.+)$`
+		rules, err := ParseTagRules(value)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(rules) != 2 || rules[1].Field != "uploader" {
+			t.Fatalf("Unexpected rules: %v", rules)
+		}
+		re, _ := rules[0].Regexp()
+		if m := re.FindStringSubmatch("Band; Song"); m == nil || m[1] != "Band" || m[2] != "Song" {
+			t.Errorf("Expected rule to match \"Band; Song\", got %v", m)
+		}
+		if got := FormatTagRules(rules); got != value {
+			t.Errorf("Expected %s, got %s", value, got)
+		}
+		// 代碼中構造的規則格式化時轉義 ;
+		formatted := FormatTagRules([]TagRule{{Field: "title", Pattern: `(?P<artist>.+);(?P<title>.+)`}})
+		if parsed, err := ParseTagRules(formatted); err != nil || len(parsed) != 1 {
+			t.Errorf("Expected %s to parse as one rule, got %v, %v", formatted, parsed, err)
+		}
+	})
+
+	invalid := map[string]string{
+		"missing pattern": "title",
+		"bad regexp":      "title:(?P<artist>.+",
+		"unknown tag":     "title:(?P<singer>.+)",
+		"no named group":  "title:(.+) - (.+)",
+	}
+	for name, value := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseTagRules(value); err == nil {
+				t.Errorf("Expected error for %q", value)
+			}
+		})
+	}
+}
+
+func TestValidateTagRules(t *testing.T) {
+	cfg := NewConfig()
+	cfg.TagRules = []TagRule{{Field: "title", Pattern: "(?P<singer>.+)"}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "tag_rules") {
+		t.Errorf("Expected tag_rules error, got: %v", err)
+	}
+}
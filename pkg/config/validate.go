@@ -0,0 +1,410 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AudioFormats yt-dlp --audio-format 支持的格式
+var AudioFormats = []string{"best", "aac", "alac", "flac", "m4a", "mp3", "opus", "vorbis", "wav"}
+
+// bitrateRange 各編碼器可用的比特率範圍（kbps）
+type bitrateRange struct {
+	min, max int
+}
+
+// bitrateRanges 有損格式的比特率範圍，無損格式和 best 不限制
+var bitrateRanges = map[string]bitrateRange{
+	"mp3":    {8, 320},
+	"aac":    {8, 512},
+	"m4a":    {8, 512},
+	"opus":   {6, 510},
+	"vorbis": {45, 500},
+}
+
+// templateFields yt-dlp 輸出模板中可用的字段
+var templateFields = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`
+		id title fulltitle ext alt_title description display_id
+		uploader uploader_id uploader_url channel channel_id channel_url channel_follower_count
+		upload_date release_date release_year timestamp release_timestamp modified_date
+		duration duration_string view_count like_count dislike_count repost_count comment_count
+		age_limit live_status is_live was_live availability license location categories tags
+		webpage_url original_url webpage_url_basename webpage_url_domain extractor extractor_key
+		epoch autonumber video_autonumber n_entries
+		playlist playlist_id playlist_title playlist_count playlist_index playlist_autonumber
+		playlist_uploader playlist_uploader_id playlist_channel playlist_channel_id
+		chapter chapter_number chapter_id section_title section_start section_end section_number
+		track track_number track_id artist artists album album_type album_artist album_artists
+		disc_number genre genres composer creator series season season_number episode episode_number
+		format format_id format_note acodec abr asr audio_channels filesize filesize_approx
+		resolution width height fps vcodec vbr tbr protocol language
+	`) {
+		templateFields[name] = true
+	}
+}
+
+var (
+	bitrateRe       = regexp.MustCompile(`^(\d+)k$`)
+	playlistItemsRe = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+	versionRe       = regexp.MustCompile(`^\d+(\.\d+)*$`)
+	// templateFieldRe 匹配 %(field)s 形式的模板字段，字段名後可以有 yt-dlp 的格式化語法
+	templateFieldRe = regexp.MustCompile(`%\(([^)]*)\)`)
+	fieldNameRe     = regexp.MustCompile(`^[A-Za-z_][\w]*`)
+	// extFieldRe 匹配 %(ext)s 字段，包括 %(ext)5s 之類的格式化
+	extFieldRe = regexp.MustCompile(`%\(ext\)[-#0 +]*\d*(?:\.\d+)?s`)
+)
+
+// FieldError 單個配置項的錯誤
+type FieldError struct {
+	Field string // 配置項的鍵，與配置文件一致
+	Value string
+	Msg   string
+}
+
+// Error 實現 error 接口
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s = %q: %s", e.Field, e.Value, e.Msg)
+}
+
+// ValidationError 配置校驗失敗，包含所有出錯的配置項
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error 實現 error 接口，每個配置項一行
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = fe.Error()
+	}
+	return "配置無效:\n  " + strings.Join(lines, "\n  ")
+}
+
+// Validate 校驗配置，返回 *ValidationError 列出所有錯誤
+func (c *Config) Validate() error {
+	v := &validation{}
+
+	if _, ok := LookupPreset(c.Preset); c.Preset != "" && !ok {
+		v.add("preset", c.Preset, "未知的預設，可選: %s", strings.Join(PresetNames(), ", "))
+	}
+	if !isAudioFormat(c.AudioFormat) {
+		v.add("audio_format", c.AudioFormat, "不支持的音頻格式，可選: %s", strings.Join(AudioFormats, ", "))
+	}
+	if q, err := strconv.Atoi(c.AudioQuality); err != nil || q < 0 || q > 10 {
+		v.add("audio_quality", c.AudioQuality, "音頻質量必須是 0-10 的整數")
+	}
+	c.validateBitrate(v)
+
+	if c.Timeout < 0 {
+		v.add("timeout", c.Timeout.String(), "超時時間不能為負數")
+	}
+	if c.PlaylistItems != "" && !playlistItemsRe.MatchString(c.PlaylistItems) {
+		v.add("playlist_items", c.PlaylistItems, "播放列表條目範圍格式錯誤，例如 1-10,15")
+	}
+	if c.PlaylistMaxItems < 0 {
+		v.add("playlist_max_items", strconv.Itoa(c.PlaylistMaxItems), "播放列表條目數不能為負數")
+	}
+
+	for _, rule := range c.TagRules {
+		if _, err := rule.Regexp(); err != nil {
+			v.add("tag_rules", rule.String(), "%v", err)
+		}
+	}
+
+	if c.CoverSize < 0 {
+		v.add("cover_size", strconv.Itoa(c.CoverSize), "封面尺寸不能為負數")
+	}
+
+	c.validateSponsorBlock(v)
+	c.validateTrimming(v)
+	c.validateLoudness(v)
+	for _, clip := range c.Clips {
+		if err := clip.Validate(); err != nil {
+			v.add("clips", clip.String(), "%v", err)
+		}
+	}
+	if c.SplitChapters && len(c.Clips) > 0 {
+		v.add("split_chapters", "true", "不能與 clips 同時使用")
+	}
+	if c.ChapterPlaylist != "" && !isChapterPlaylist(c.ChapterPlaylist) {
+		v.add("chapter_playlist", c.ChapterPlaylist, "不支持的播放列表格式，可選: %s", strings.Join(ChapterPlaylists, ", "))
+	}
+
+	for _, min := range []struct{ key, version string }{
+		{"min_ytdlp_version", c.MinYtDlpVersion},
+		{"min_ffmpeg_version", c.MinFFmpegVersion},
+	} {
+		if min.version != "" && !versionRe.MatchString(min.version) {
+			v.add(min.key, min.version, "版本號格式錯誤，應為點分隔的數字，例如 2024.08.06")
+		}
+	}
+
+	if !isSpaceCheck(c.SpaceCheck) {
+		v.add("space_check", c.SpaceCheck, "不支持的空間檢查方式，可選: %s", strings.Join(SpaceChecks, ", "))
+	}
+	if c.MinFreeSpace < 0 {
+		v.add("min_free_space", FormatSize(c.MinFreeSpace), "保留空間不能為負數")
+	}
+
+	if strings.TrimSpace(c.OutputDir) == "" {
+		v.add("output_dir", c.OutputDir, "輸出目錄不能為空")
+	} else {
+		v.template("output_template", outputTemplate(c), c.OutputDir, c.OutputTemplate)
+		// 與下載時一樣，播放列表模板的相對路徑相對於輸出目錄，絕對路徑原樣使用
+		playlist := c.PlaylistTemplate
+		if !filepath.IsAbs(playlist) {
+			playlist = filepath.Join(c.OutputDir, playlist)
+		}
+		v.template("playlist_template", c.PlaylistTemplate, c.OutputDir, playlist)
+		outputs := make(map[string]string, len(c.Outputs))
+		for _, o := range c.Outputs {
+			v.output(c, o)
+			v.duplicateOutput(c, o, outputs)
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// validateBitrate 檢查比特率語法和所選格式的範圍，VBR 和無損格式不使用比特率
+func (c *Config) validateBitrate(v *validation) {
+	if c.Bitrate == "" || c.Lossless() {
+		return
+	}
+	m := bitrateRe.FindStringSubmatch(c.Bitrate)
+	if m == nil {
+		v.add("bitrate", c.Bitrate, "比特率格式錯誤，應為數字加 k，例如 320k")
+		return
+	}
+	kbps, _ := strconv.Atoi(m[1])
+	r, lossy := bitrateRanges[c.AudioFormat]
+	switch {
+	case kbps == 0:
+		v.add("bitrate", c.Bitrate, "比特率必須大於 0")
+	case lossy && (kbps < r.min || kbps > r.max):
+		v.add("bitrate", c.Bitrate, "%s 的比特率範圍是 %dk-%dk", c.AudioFormat, r.min, r.max)
+	}
+}
+
+// validateSponsorBlock 檢查片段類別和 API 地址
+func (c *Config) validateSponsorBlock(v *validation) {
+	if len(c.SponsorBlock) == 0 {
+		return
+	}
+	for _, category := range c.SponsorBlock {
+		if !isSponsorBlockCategory(category) {
+			v.add("sponsorblock", category, "未知的片段類別，可選: %s", strings.Join(SponsorBlockCategories, ", "))
+		}
+	}
+	if u, err := url.Parse(c.SponsorBlockAPI); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("sponsorblock_api", c.SponsorBlockAPI, "API 地址應為 http:// 或 https:// 開頭的 URL")
+	}
+	// 片段時間相對於完整視頻，移除後章節和截取範圍的時間都會錯位
+	if c.SplitChapters {
+		v.add("split_chapters", "true", "不能與 sponsorblock 同時使用")
+	}
+	if len(c.Clips) > 0 {
+		v.add("clips", FormatClips(c.Clips), "不能與 sponsorblock 同時使用")
+	}
+}
+
+// validateTrimming 檢查靜音閾值和各個時長
+func (c *Config) validateTrimming(v *validation) {
+	if c.TrimSilence {
+		if c.SilenceThreshold < -100 || c.SilenceThreshold > 0 {
+			v.add("silence_threshold", strconv.FormatFloat(c.SilenceThreshold, 'f', -1, 64), "靜音閾值的範圍是 -100 到 0 dB")
+		}
+		if c.SilenceDuration <= 0 {
+			v.add("silence_duration", c.SilenceDuration.String(), "靜音時長必須大於 0")
+		}
+	}
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"fade_in", c.FadeIn},
+		{"fade_out", c.FadeOut},
+		{"max_duration", c.MaxDuration},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			v.add(d.key, d.value.String(), "時長不能為負數")
+		}
+	}
+	// 修剪在拆分之前進行，會使章節的時間錯位
+	if c.SplitChapters && (c.TrimSilence || c.MaxDuration > 0) {
+		v.add("split_chapters", "true", "不能與 trim_silence 或 max_duration 同時使用")
+	}
+}
+
+// validateLoudness 檢查響度處理方式和 ffmpeg loudnorm 接受的參數範圍
+func (c *Config) validateLoudness(v *validation) {
+	if c.Loudness == "" {
+		return
+	}
+	if !isLoudnessMode(c.Loudness) {
+		v.add("loudness", c.Loudness, "不支持的響度處理方式，可選: %s", strings.Join(LoudnessModes, ", "))
+	}
+	ranges := []struct {
+		key      string
+		value    float64
+		min, max float64
+	}{
+		{"loudness_target", c.LoudnessTarget, -70, -5},
+		{"loudness_true_peak", c.LoudnessTruePeak, -9, 0},
+		{"loudness_range", c.LoudnessRange, 1, 50},
+	}
+	for _, r := range ranges {
+		if r.value < r.min || r.value > r.max {
+			v.add(r.key, strconv.FormatFloat(r.value, 'f', -1, 64), "範圍是 %g 到 %g", r.min, r.max)
+		}
+	}
+}
+
+// isLoudnessMode 判斷是否為支持的響度處理方式
+func isLoudnessMode(mode string) bool {
+	for _, m := range LoudnessModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// isSponsorBlockCategory 判斷是否為 SponsorBlock 的片段類別
+func isSponsorBlockCategory(category string) bool {
+	for _, c := range SponsorBlockCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// isChapterPlaylist 判斷是否為支持的章節播放列表格式
+func isChapterPlaylist(format string) bool {
+	for _, f := range ChapterPlaylists {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// isSpaceCheck 判斷是否為支持的空間檢查方式
+func isSpaceCheck(mode string) bool {
+	for _, m := range SpaceChecks {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// isAudioFormat 判斷是否為 yt-dlp 支持的音頻格式
+func isAudioFormat(format string) bool {
+	for _, f := range AudioFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// validation 收集校驗錯誤
+type validation struct {
+	errors []*FieldError
+}
+
+func (v *validation) add(field, value, format string, args ...interface{}) {
+	v.errors = append(v.errors, &FieldError{Field: field, Value: value, Msg: fmt.Sprintf(format, args...)})
+}
+
+// template 檢查模板字段都是 yt-dlp 已知的字段，並且展開後的路徑不會離開輸出目錄
+// value 是用戶寫的模板，path 是與輸出目錄合併後的路徑
+func (v *validation) template(field, value, outputDir, path string) {
+	if value == "" {
+		v.add(field, value, "輸出模板不能為空")
+		return
+	}
+
+	if unknown := unknownFields(value, templateFields); len(unknown) > 0 {
+		v.add(field, value, "未知的模板字段: %s", strings.Join(unknown, ", "))
+	}
+
+	// 字段值中的路徑分隔符會被 yt-dlp 替換，只需檢查模板本身
+	if !insideDir(outputDir, path) {
+		v.add(field, value, "輸出路徑必須在輸出目錄 %s 內", outputDir)
+	}
+}
+
+// output 檢查多格式輸出，模板只能使用 OutputTemplateFields，相對路徑不能離開輸出目錄
+func (v *validation) output(c *Config, o Output) {
+	value := FormatOutputs([]Output{o})
+	if _, ok := LookupPreset(o.Preset); !ok {
+		v.add("outputs", value, "未知的預設，可選: %s", strings.Join(PresetNames(), ", "))
+	}
+
+	known := make(map[string]bool, len(OutputTemplateFields))
+	for _, name := range OutputTemplateFields {
+		known[name] = true
+	}
+	if unknown := unknownFields(o.Path, known); len(unknown) > 0 {
+		v.add("outputs", value, "多格式輸出的模板只支持 %s，不支持: %s",
+			strings.Join(OutputTemplateFields, ", "), strings.Join(unknown, ", "))
+	}
+
+	if !filepath.IsAbs(o.Path) && !insideDir(c.OutputDir, c.OutputPath(o)) {
+		v.add("outputs", value, "輸出路徑必須在輸出目錄 %s 內，其他位置請使用絕對路徑", c.OutputDir)
+	}
+}
+
+// duplicateOutput 檢查多格式輸出展開擴展名後的路徑是否與之前的輸出相同，例如默認路徑下的 mp3-320 和 mp3-256
+// 各格式同時轉碼，路徑相同時會寫同一個文件。seen 記錄已檢查的路徑及其輸出
+func (v *validation) duplicateOutput(c *Config, o Output, seen map[string]string) {
+	preset, ok := LookupPreset(o.Preset)
+	if !ok {
+		return
+	}
+	value := FormatOutputs([]Output{o})
+	path := filepath.Clean(extFieldRe.ReplaceAllString(c.OutputPath(o), preset.Extension()))
+	if other, ok := seen[path]; ok {
+		v.add("outputs", value, "與 %s 輸出到同一個文件 %s，請為其中一個指定不同的目錄或模板", other, path)
+		return
+	}
+	seen[path] = value
+}
+
+// unknownFields 返回模板中不在 known 中的字段，已排序
+func unknownFields(template string, known map[string]bool) []string {
+	var unknown []string
+	for _, m := range templateFieldRe.FindAllStringSubmatch(template, -1) {
+		// 字段可以用逗號列出多個備選，例如 %(track,title)s
+		for _, alt := range strings.Split(m[1], ",") {
+			name := fieldNameRe.FindString(strings.TrimSpace(alt))
+			if name != "" && !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// insideDir 判斷 path 是否在 dir 內
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
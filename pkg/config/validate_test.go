@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	if err := NewConfig().Validate(); err != nil {
+		t.Fatalf("Expected default config to be valid, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		field  string
+		want   string
+	}{
+		{"bad format", func(c *Config) { c.AudioFormat = "mp4a" }, "audio_format", "不支持的音頻格式"},
+		{"quality out of range", func(c *Config) { c.AudioQuality = "11" }, "audio_quality", "0-10"},
+		{"quality not a number", func(c *Config) { c.AudioQuality = "best" }, "audio_quality", "0-10"},
+		{"bitrate without k", func(c *Config) { c.Bitrate = "320" }, "bitrate", "格式錯誤"},
+		{"bitrate zero", func(c *Config) { c.Bitrate = "0k" }, "bitrate", "大於 0"},
+		{"mp3 bitrate too high", func(c *Config) { c.Bitrate = "384k" }, "bitrate", "mp3 的比特率範圍是 8k-320k"},
+		{"vorbis bitrate too low", func(c *Config) { c.AudioFormat = "vorbis"; c.Bitrate = "32k" }, "bitrate", "45k-500k"},
+		{"negative timeout", func(c *Config) { c.Timeout = -time.Second }, "timeout", "負數"},
+		{"bad playlist items", func(c *Config) { c.PlaylistItems = "1..5" }, "playlist_items", "格式錯誤"},
+		{"negative max items", func(c *Config) { c.PlaylistMaxItems = -1 }, "playlist_max_items", "負數"},
+		{"negative cover size", func(c *Config) { c.CoverSize = -1 }, "cover_size", "負數"},
+		{"invalid clip", func(c *Config) { c.Clips = []Clip{{Start: Timestamp{Seconds: 60}, End: Timestamp{Seconds: 30}}} }, "clips", "早於"},
+		{"chapters with clips", func(c *Config) {
+			c.SplitChapters = true
+			c.Clips = []Clip{{End: End}}
+		}, "split_chapters", "clips"},
+		{"unknown loudness mode", func(c *Config) { c.Loudness = "rms" }, "loudness", "loudnorm, replaygain"},
+		{"loudness target out of range", func(c *Config) {
+			c.Loudness = "loudnorm"
+			c.LoudnessTarget = -3
+		}, "loudness_target", "-70 到 -5"},
+		{"unknown sponsorblock category", func(c *Config) { c.SponsorBlock = []string{"sponsor", "ads"} }, "sponsorblock", "selfpromo"},
+		{"sponsorblock api without scheme", func(c *Config) {
+			c.SponsorBlock = []string{"sponsor"}
+			c.SponsorBlockAPI = "sponsor.ajay.app"
+		}, "sponsorblock_api", "http://"},
+		{"sponsorblock with chapters", func(c *Config) {
+			c.SponsorBlock = []string{"sponsor"}
+			c.SplitChapters = true
+		}, "split_chapters", "sponsorblock"},
+		{"silence threshold out of range", func(c *Config) {
+			c.TrimSilence = true
+			c.SilenceThreshold = 6
+		}, "silence_threshold", "-100 到 0"},
+		{"zero silence duration", func(c *Config) {
+			c.TrimSilence = true
+			c.SilenceDuration = 0
+		}, "silence_duration", "大於 0"},
+		{"negative fade", func(c *Config) { c.FadeOut = -time.Second }, "fade_out", "負數"},
+		{"bad min yt-dlp version", func(c *Config) { c.MinYtDlpVersion = "2024-08-06" }, "min_ytdlp_version", "點分隔"},
+		{"bad min ffmpeg version", func(c *Config) { c.MinFFmpegVersion = "n6.1" }, "min_ffmpeg_version", "點分隔"},
+		{"chapters with trimming", func(c *Config) {
+			c.SplitChapters = true
+			c.MaxDuration = time.Minute
+		}, "split_chapters", "max_duration"},
+		{"unknown chapter playlist", func(c *Config) { c.ChapterPlaylist = "pls" }, "chapter_playlist", "m3u, cue"},
+		{"unknown space check", func(c *Config) { c.SpaceCheck = "ask" }, "space_check", "refuse, warn, off"},
+		{"negative min free space", func(c *Config) { c.MinFreeSpace = -1 }, "min_free_space", "負數"},
+		{"empty output dir", func(c *Config) { c.OutputDir = " " }, "output_dir", "不能為空"},
+		{"unknown template field", func(c *Config) { c.WithOutputTemplate("%(titel)s.%(ext)s") }, "output_template", "titel"},
+		{"template escapes output dir", func(c *Config) { c.WithOutputTemplate("../%(title)s.%(ext)s") }, "output_template", "輸出目錄"},
+		{"absolute template outside", func(c *Config) { c.OutputTemplate = "/tmp/%(title)s.%(ext)s" }, "output_template", "輸出目錄"},
+		{"playlist template escapes", func(c *Config) { c.PlaylistTemplate = "../../%(title)s.%(ext)s" }, "playlist_template", "輸出目錄"},
+		{"empty playlist template", func(c *Config) { c.PlaylistTemplate = "" }, "playlist_template", "不能為空"},
+		{"absolute playlist template outside", func(c *Config) { c.PlaylistTemplate = "/tmp/x/%(title)s.%(ext)s" }, "playlist_template", "輸出目錄"},
+		{"outputs with the same path", func(c *Config) {
+			c.Outputs, _ = ParseOutputs("mp3-320;mp3-256")
+		}, "outputs", "同一個文件"},
+		{"outputs with the same extension", func(c *Config) {
+			c.Outputs, _ = ParseOutputs("m4a-256=music;alac=music/%(title)s.%(ext)s")
+		}, "outputs", "m4a-256=music"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.modify(cfg)
+
+			var invalid *ValidationError
+			if err := cfg.Validate(); !errors.As(err, &invalid) {
+				t.Fatalf("Expected ValidationError, got: %v", err)
+			}
+			if len(invalid.Errors) != 1 {
+				t.Fatalf("Expected 1 error, got: %v", invalid)
+			}
+			fe := invalid.Errors[0]
+			if fe.Field != tt.field || !strings.Contains(fe.Msg, tt.want) {
+				t.Errorf("Expected %s error containing %q, got: %v", tt.field, tt.want, fe)
+			}
+		})
+	}
+}
+
+func TestValidateAccepts(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"lossless ignores bitrate range", func(c *Config) { c.AudioFormat = "flac"; c.Bitrate = "1411k" }},
+		{"opus", func(c *Config) { c.AudioFormat = "opus"; c.Bitrate = "160k" }},
+		{"template with formatting", func(c *Config) { c.WithOutputTemplate("%(upload_date>%Y)s/%(title.0:50)s [%(id)s].%(ext)s") }},
+		{"template with alternatives", func(c *Config) { c.WithOutputTemplate("%(track,title)s.%(ext)s") }},
+		{"absolute output dir", func(c *Config) { c.WithOutputDir(filepath.Join(t.TempDir(), "music")) }},
+		{"local sponsorblock api", func(c *Config) {
+			c.SponsorBlock = []string{"sponsor", "music_offtopic"}
+			c.SponsorBlockAPI = "http://127.0.0.1:8080"
+		}},
+		{"chapters with fades", func(c *Config) { c.SplitChapters = true; c.FadeIn = time.Second }},
+		{"nested template", func(c *Config) { c.WithOutputTemplate("a/../b/%(title)s.%(ext)s") }},
+		{"outputs in different directories", func(c *Config) { c.Outputs, _ = ParseOutputs("mp3-320;mp3-256=lossy;flac") }},
+		{"absolute playlist template inside", func(c *Config) {
+			dir := t.TempDir()
+			c.WithOutputDir(dir)
+			c.PlaylistTemplate = filepath.Join(dir, "%(playlist)s", "%(title)s.%(ext)s")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.modify(cfg)
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateAggregates(t *testing.T) {
+	cfg := NewConfig()
+	cfg.AudioFormat = "mp4a"
+	cfg.AudioQuality = "11"
+	cfg.Bitrate = "320"
+
+	err := cfg.Validate()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected ValidationError, got: %v", err)
+	}
+	if len(invalid.Errors) != 3 {
+		t.Errorf("Expected 3 errors, got %d: %v", len(invalid.Errors), err)
+	}
+	for _, field := range []string{"audio_format", "audio_quality", "bitrate"} {
+		if !strings.Contains(err.Error(), field+" = ") {
+			t.Errorf("Expected error to mention %s, got: %v", field, err)
+		}
+	}
+}
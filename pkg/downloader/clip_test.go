@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/archive"
+	"youtube_to_mp3/pkg/config"
+)
+
+func TestBuildArgsClips(t *testing.T) {
+	cfg := config.NewConfig().WithOutputDir("music")
+	cfg.Clips, _ = config.ParseClips("12:30-15:45;-5:00-inf")
+	args := strings.Join(NewYtDlpDownloader(cfg, &MockCommandExecutor{}).buildArgs("https://youtu.be/a"), " ")
+
+	for _, want := range []string{
+		"--download-sections *12:30-15:45 --download-sections *-5:00-inf",
+		"-o " + filepath.Join("music", "%(title)s"+config.ClipSuffix+".%(ext)s"),
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("Expected args to contain %q, got: %s", want, args)
+		}
+	}
+}
+
+func TestDownloadClips(t *testing.T) {
+	tempDir := t.TempDir()
+	first := filepath.Join(tempDir, "Song [00.12.30-00.15.45].mp3")
+	second := filepath.Join(tempDir, "Song [00.55.00-01.00.00].mp3")
+
+	cfg := config.NewConfig().WithOutputDir(tempDir)
+	cfg.Clips, _ = config.ParseClips("12:30-15:45;-5:00-inf")
+	arc, err := archive.Open(cfg.ArchiveFile())
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+
+	mock := &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			for _, path := range []string{first, second} {
+				if err := os.WriteFile(path, []byte("clip"), 0644); err != nil {
+					t.Fatalf("Failed to create output file: %v", err)
+				}
+			}
+			writeInfo(t, args,
+				`{"id": "abc123", "extractor_key": "Youtube", "title": "Song", "section_start": 750, "section_end": 945, "filepath": "`+first+`"}`,
+				`{"id": "abc123", "extractor_key": "Youtube", "title": "Song", "section_start": 3300, "section_end": 3600, "filepath": "`+second+`"}`)
+			return nil
+		},
+	}
+	downloader := NewYtDlpDownloader(cfg, mock)
+	downloader.SetArchive(arc)
+
+	result, err := downloader.Download("https://youtu.be/abc123")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(result.Files) != 2 || result.Files[0] != first || result.Files[1] != second || result.Size != 8 {
+		t.Errorf("Expected one file per clip, got %v (size %d)", result.Files, result.Size)
+	}
+	// 片段不是完整的視頻，不寫入存檔
+	if arc.Has("youtube", "abc123") {
+		t.Error("Expected clips not to be archived")
+	}
+
+	t.Run("invalid clip is rejected before yt-dlp", func(t *testing.T) {
+		mock.lastCommand = ""
+		cfg.Clips = []config.Clip{{Start: config.Timestamp{Seconds: 60}, End: config.Timestamp{Seconds: 30}}}
+		_, err := downloader.Download("https://youtu.be/abc123")
+		if err == nil || !strings.Contains(err.Error(), "時間範圍") {
+			t.Errorf("Expected clip error, got: %v", err)
+		}
+		if mock.lastCommand != "" {
+			t.Errorf("Expected yt-dlp not to run, got %s", mock.lastCommand)
+		}
+	})
+}
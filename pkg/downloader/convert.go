@@ -0,0 +1,275 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// ExtractorLocal 本地文件轉換結果的 VideoInfo.ExtractorKey
+const ExtractorLocal = "Local"
+
+// MediaExtensions 轉換目錄時處理的文件擴展名，直接指定的文件不受限制
+var MediaExtensions = []string{
+	".mp4", ".m4v", ".mkv", ".webm", ".mov", ".avi", ".flv", ".wmv", ".mpg", ".mpeg", ".ts",
+	".mp3", ".m4a", ".aac", ".opus", ".ogg", ".oga", ".flac", ".wav", ".wma", ".alac",
+}
+
+// Converter 用 ffmpeg 把本地的視頻或音頻文件轉換為配置的格式，不需要 yt-dlp
+// 與 YtDlpDownloader 一樣使用 Config 的格式、比特率和輸出模板，可以接入後處理流水線
+type Converter struct {
+	config   *config.Config
+	executor CommandExecutor
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// NewConverter 創建本地文件轉換器
+func NewConverter(cfg *config.Config, executor CommandExecutor) *Converter {
+	if executor == nil {
+		executor = &DefaultCommandExecutor{}
+	}
+	return &Converter{
+		config:   cfg,
+		executor: executor,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+}
+
+// SetOutput 設置 ffmpeg 輸出的轉發目標
+func (c *Converter) SetOutput(stdout, stderr io.Writer) {
+	c.stdout = stdout
+	c.stderr = stderr
+}
+
+// Download 轉換本地文件或目錄，實現 Downloader 接口
+func (c *Converter) Download(path string) (*Result, error) {
+	return c.DownloadContext(context.Background(), path)
+}
+
+// DownloadContext 轉換本地文件，path 為目錄時遞歸轉換其中的媒體文件，每個文件為一個條目
+// 輸出文件已存在且未設置 Config.Force 時跳過
+func (c *Converter) DownloadContext(ctx context.Context, path string) (*Result, error) {
+	if len(c.config.Outputs) == 0 && c.config.AudioFormat == "best" {
+		return nil, fmt.Errorf("本地轉換需要指定音頻格式，不支持 best")
+	}
+	if len(c.config.Clips) > 0 {
+		return nil, fmt.Errorf("本地轉換不支持截取片段")
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取 %s: %v", path, err)
+	}
+	if !stat.IsDir() {
+		result, err := c.convert(ctx, path, "")
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	files, err := findMedia(path, c.config.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s 中沒有可轉換的文件", path)
+	}
+
+	result := &Result{URL: path, Title: filepath.Base(path)}
+	for i, file := range files {
+		rel, _ := filepath.Rel(path, filepath.Dir(file))
+		entry, err := c.convert(ctx, file, rel)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, err
+			}
+			entry = &Result{URL: file, VideoID: fileTitle(file), Title: fileTitle(file), Err: err}
+		}
+		entry.PlaylistIndex = i + 1
+		if entry.Err == nil {
+			result.Files = append(result.Files, entry.Files...)
+			result.Size += entry.Size
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+// GetOutputFiles 列出輸出目錄中的文件，實現 Downloader 接口
+func (c *Converter) GetOutputFiles() ([]string, error) {
+	pattern := filepath.Join(c.config.OutputDir, fmt.Sprintf("*.%s", c.config.Extension()))
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("查找輸出文件失敗: %v", err)
+	}
+	return files, nil
+}
+
+// convert 轉換單個文件，subdir 為文件在輸入目錄中的相對目錄，輸出時保留
+func (c *Converter) convert(ctx context.Context, src, subdir string) (*Result, error) {
+	title := fileTitle(src)
+	info := &VideoInfo{
+		ID:           title,
+		ExtractorKey: ExtractorLocal,
+		Title:        title,
+		Fields:       map[string]interface{}{"id": title, "title": title, "extractor_key": ExtractorLocal},
+	}
+	targets := c.targets(info, subdir)
+	if len(c.config.Outputs) > 0 {
+		info.Outputs = make([]OutputFile, len(targets))
+		for i, t := range targets {
+			info.Outputs[i] = OutputFile{Preset: t.preset.Name, Path: t.path}
+		}
+	} else {
+		info.FilePath = targets[0].path
+	}
+	result := newResult(src, info)
+
+	abs, _ := filepath.Abs(src)
+	existing := 0
+	for _, t := range targets {
+		if dst, _ := filepath.Abs(t.path); dst == abs {
+			return nil, fmt.Errorf("%s: 輸出文件與輸入文件相同，請修改輸出目錄或模板", src)
+		}
+		if _, err := os.Stat(t.path); err == nil {
+			existing++
+		}
+	}
+	if existing == len(targets) && !c.config.Force {
+		result.Skipped = true
+		return result, nil
+	}
+
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	for _, t := range targets {
+		if err := c.encode(ctx, src, t.path, t.preset); err != nil {
+			if ctx.Err() != nil {
+				return nil, &CancelledError{URL: src, Err: ctx.Err()}
+			}
+			return nil, err
+		}
+	}
+	result.SetFiles(result.Files)
+	return result, nil
+}
+
+// target 一種輸出格式及其文件路徑
+type target struct {
+	preset config.Preset
+	path   string
+}
+
+// targets 返回文件的所有輸出，多格式輸出時每種格式一個
+func (c *Converter) targets(info *VideoInfo, subdir string) []target {
+	if len(c.config.Outputs) == 0 {
+		preset := config.Preset{Name: c.config.AudioFormat, AudioFormat: c.config.AudioFormat,
+			AudioQuality: c.config.AudioQuality, Bitrate: c.config.Bitrate}
+		return []target{{preset, c.outputPath(c.config.OutputTemplate, info, preset, subdir)}}
+	}
+	targets := make([]target, len(c.config.Outputs))
+	for i, o := range c.config.Outputs {
+		preset, _ := config.LookupPreset(o.Preset)
+		targets[i] = target{preset, c.outputPath(c.config.OutputPath(o), info, preset, subdir)}
+	}
+	return targets
+}
+
+// outputPath 展開模板，subdir 插入到輸出目錄和模板之間
+func (c *Converter) outputPath(template string, info *VideoInfo, preset config.Preset, subdir string) string {
+	path := expandTemplate(template, info.Fields, preset.Extension())
+	if subdir == "" || subdir == "." {
+		return path
+	}
+	if rel, err := filepath.Rel(c.config.OutputDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join(c.config.OutputDir, subdir, rel)
+	}
+	return filepath.Join(filepath.Dir(path), subdir, filepath.Base(path))
+}
+
+// encode 調用 ffmpeg 提取第一條音頻流並編碼，先寫臨時文件，成功後替換輸出文件
+func (c *Converter) encode(ctx context.Context, src, dst string, preset config.Preset) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("創建輸出目錄失敗: %v", err)
+	}
+	ext := filepath.Ext(dst)
+	tmp := filepath.Join(filepath.Dir(dst), "."+strings.TrimSuffix(filepath.Base(dst), ext)+".converting"+ext)
+
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", src,
+		"-map", "0:a:0", "-vn", "-map_metadata", "0"}
+	args = append(args, preset.FFmpegArgs()...)
+	if strings.EqualFold(ext, ".mp3") {
+		args = append(args, "-id3v2_version", "3")
+	}
+	args = append(args, tmp)
+
+	var stderr bytes.Buffer
+	if err := c.executor.ExecuteContext(ctx, c.config.FFmpeg(), args, c.stdout, io.MultiWriter(c.stderr, &stderr)); err != nil {
+		os.Remove(tmp)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("轉換 %s 失敗: %v %s", filepath.Base(src), err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存 %s 失敗: %v", filepath.Base(dst), err)
+	}
+	return nil
+}
+
+// findMedia 遞歸查找目錄中的媒體文件，跳過隱藏文件、隱藏目錄和位於其中的輸出目錄，按路徑排序
+func findMedia(dir, outputDir string) ([]string, error) {
+	output, _ := filepath.Abs(outputDir)
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); d.IsDir() && abs == output {
+			return filepath.SkipDir
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && isMediaFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("讀取目錄 %s 失敗: %v", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// isMediaFile 根據擴展名判斷是否為可轉換的媒體文件
+func isMediaFile(path string) bool {
+	return contains(MediaExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// fileTitle 返回不帶擴展名的文件名，作為標題
+func fileTitle(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
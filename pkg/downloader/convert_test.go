@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// fakeFFmpeg 把輸出文件寫入最後一個參數，輸入文件名包含 fail 時失敗
+func fakeFFmpeg(calls *[][]string) *MockCommandExecutor {
+	return &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			*calls = append(*calls, args)
+			for i, arg := range args {
+				if arg == "-i" && strings.Contains(args[i+1], "fail") {
+					io.WriteString(stderr, "Invalid data found when processing input\n")
+					return errors.New("exit status 1")
+				}
+			}
+			return os.WriteFile(args[len(args)-1], []byte("encoded"), 0644)
+		},
+	}
+}
+
+// writeFiles 在 dir 中創建文件
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("media"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestConverterFile(t *testing.T) {
+	input := t.TempDir()
+	writeFiles(t, input, "Live Show.mkv")
+	out := t.TempDir()
+	cfg := config.NewConfig().WithOutputDir(out)
+	var calls [][]string
+
+	result, err := NewConverter(cfg, fakeFFmpeg(&calls)).Download(filepath.Join(input, "Live Show.mkv"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := filepath.Join(out, "Live Show.mp3")
+	if len(result.Files) != 1 || result.Files[0] != expected {
+		t.Errorf("Expected %s, got %v", expected, result.Files)
+	}
+	if result.Title != "Live Show" || result.Info.ExtractorKey != ExtractorLocal || result.Size != int64(len("encoded")) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	args := strings.Join(calls[0], " ")
+	if !strings.Contains(args, "-map 0:a:0 -vn -map_metadata 0 -c:a libmp3lame -b:a 320k -id3v2_version 3") {
+		t.Errorf("Unexpected ffmpeg args: %s", args)
+	}
+	if _, err := os.Stat(filepath.Join(out, ".Live Show.converting.mp3")); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary file to be renamed, got: %v", err)
+	}
+
+	t.Run("existing output is skipped", func(t *testing.T) {
+		calls = nil
+		result, err := NewConverter(cfg, fakeFFmpeg(&calls)).Download(filepath.Join(input, "Live Show.mkv"))
+		if err != nil || !result.Skipped || len(calls) != 0 {
+			t.Errorf("Expected skipped result without ffmpeg, got %+v, calls %v (err: %v)", result, calls, err)
+		}
+
+		force := *cfg
+		force.Force = true
+		result, err = NewConverter(&force, fakeFFmpeg(&calls)).Download(filepath.Join(input, "Live Show.mkv"))
+		if err != nil || result.Skipped || len(calls) != 1 {
+			t.Errorf("Expected conversion with -force, got %+v (err: %v)", result, err)
+		}
+	})
+}
+
+func TestConverterDirectory(t *testing.T) {
+	input := t.TempDir()
+	writeFiles(t, input, "a.mp4", "notes.txt", "album/b.webm", "album/fail.mov", ".hidden/c.mp4", "output/old.mp3")
+	out := filepath.Join(input, "output")
+	cfg := config.NewConfig().WithOutputDir(out)
+	cfg.Outputs = []config.Output{{Preset: "mp3-320"}, {Preset: "flac", Path: "lossless"}}
+	var calls [][]string
+
+	result, err := NewConverter(cfg, fakeFFmpeg(&calls)).Download(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(out, "a.mp3"),
+		filepath.Join(out, "lossless", "a.flac"),
+		filepath.Join(out, "album", "b.mp3"),
+		filepath.Join(out, "album", "lossless", "b.flac"),
+	}
+	if strings.Join(result.Files, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected files:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(result.Files, "\n"))
+	}
+	if len(result.Entries) != 3 || result.Entries[1].Outputs[1].Preset != "flac" {
+		t.Fatalf("Expected 3 entries with outputs, got %+v", result.Entries)
+	}
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].VideoID != "fail" || failed[0].PlaylistIndex != 3 ||
+		!strings.Contains(failed[0].Err.Error(), "Invalid data") {
+		t.Errorf("Expected fail.mov to fail, got %+v", failed)
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	input := t.TempDir()
+	writeFiles(t, input, "song.mp3", "notes.txt")
+
+	tests := []struct {
+		name   string
+		path   string
+		modify func(cfg *config.Config)
+		want   string
+	}{
+		{"missing", filepath.Join(input, "missing.mp4"), func(cfg *config.Config) {}, "無法讀取"},
+		{"no media", filepath.Join(input, "empty"), func(cfg *config.Config) { os.Mkdir(filepath.Join(input, "empty"), 0755) }, "沒有可轉換的文件"},
+		{"best format", filepath.Join(input, "song.mp3"), func(cfg *config.Config) { cfg.AudioFormat = "best" }, "不支持 best"},
+		{"overwrite input", filepath.Join(input, "song.mp3"), func(cfg *config.Config) { cfg.WithOutputDir(input) }, "輸出文件與輸入文件相同"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewConfig().WithOutputDir(t.TempDir())
+			tt.modify(cfg)
+			var calls [][]string
+			_, err := NewConverter(cfg, fakeFFmpeg(&calls)).Download(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got: %v", tt.want, err)
+			}
+			if len(calls) != 0 {
+				t.Errorf("Expected no ffmpeg calls, got %v", calls)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		cfg := config.NewConfig().WithOutputDir(t.TempDir())
+		mock := &MockCommandExecutor{executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			return ctx.Err()
+		}}
+		_, err := NewConverter(cfg, mock).DownloadContext(ctx, filepath.Join(input, "song.mp3"))
+		var cancelled *CancelledError
+		if !errors.As(err, &cancelled) {
+			t.Errorf("Expected CancelledError, got: %v", err)
+		}
+	})
+}
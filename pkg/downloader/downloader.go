@@ -1,30 +1,51 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"youtube_to_mp3/pkg/archive"
 	"youtube_to_mp3/pkg/config"
 )
 
 // Downloader 定義下載器接口
 type Downloader interface {
-	Download(url string) error
+	Download(url string) (*Result, error)
+	DownloadContext(ctx context.Context, url string) (*Result, error)
 	GetOutputFiles() ([]string, error)
 }
 
 // CommandExecutor 定義命令執行器接口
 type CommandExecutor interface {
 	Execute(name string, args []string, stdout, stderr io.Writer) error
+	ExecuteContext(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
 }
 
-// YtDlpDownloader YouTube 下載器實現
+// ArchiveRecorder 由調用方決定何時寫入下載存檔的下載器
+// DeferArchive 後下載完成時不再寫入存檔，調用方在後處理成功後調用 RecordArchive
+type ArchiveRecorder interface {
+	DeferArchive()
+	RecordArchive(result *Result) error
+}
+
+// YtDlpDownloader YouTube 下載器實現，不能在多個 goroutine 間共用，並發下載請使用 Manager
 type YtDlpDownloader struct {
-	config   *config.Config
-	executor CommandExecutor
+	config    *config.Config
+	executor  CommandExecutor
+	stdout    io.Writer
+	stderr    io.Writer
+	progress  ProgressHandler
+	archive   *archive.Archive
+	preflight PreflightFunc
+	deferred  bool // 由調用方寫入存檔，見 ArchiveRecorder
 }
 
 // NewYtDlpDownloader 創建新的 YtDlp 下載器
@@ -35,45 +56,303 @@ func NewYtDlpDownloader(cfg *config.Config, executor CommandExecutor) *YtDlpDown
 	return &YtDlpDownloader{
 		config:   cfg,
 		executor: executor,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
 	}
 }
 
+// SetOutput 設置 yt-dlp 輸出的轉發目標，傳入 io.Discard 可關閉終端輸出
+func (d *YtDlpDownloader) SetOutput(stdout, stderr io.Writer) {
+	d.stdout = stdout
+	d.stderr = stderr
+}
+
+// SetProgressHandler 設置進度事件回調
+func (d *YtDlpDownloader) SetProgressHandler(handler ProgressHandler) {
+	d.progress = handler
+}
+
+// SetArchive 設置下載存檔，已存檔的視頻不再調用 yt-dlp（Config.Force 時忽略）
+// 同一個存檔可以在多個下載器之間共用
+func (d *YtDlpDownloader) SetArchive(a *archive.Archive) {
+	d.archive = a
+}
+
+// DeferArchive 實現 ArchiveRecorder 接口，之後下載完成時不再寫入存檔
+func (d *YtDlpDownloader) DeferArchive() {
+	d.deferred = true
+}
+
 // Download 下載並轉換視頻為 MP3
-func (d *YtDlpDownloader) Download(url string) error {
+func (d *YtDlpDownloader) Download(url string) (*Result, error) {
+	return d.DownloadContext(context.Background(), url)
+}
+
+// DownloadContext 下載並轉換視頻為 MP3，ctx 取消或超時時終止 yt-dlp/ffmpeg
+func (d *YtDlpDownloader) DownloadContext(ctx context.Context, url string) (*Result, error) {
+	// 無效的時間範圍在運行 yt-dlp 之前返回
+	for _, clip := range d.config.Clips {
+		if err := clip.Validate(); err != nil {
+			return nil, fmt.Errorf("時間範圍 %s 無效: %v", clip, err)
+		}
+	}
+	if result := d.checkArchive(url); result != nil {
+		return result, nil
+	}
+
+	warnings, err := d.runPreflight(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	result, err := d.download(ctx, url)
+	if result != nil && len(warnings) > 0 {
+		result.Warnings = append(warnings, result.Warnings...)
+	}
+	return result, err
+}
+
+// download 運行 yt-dlp 下載並轉換，讀取輸出的文件信息
+func (d *YtDlpDownloader) download(ctx context.Context, url string) (*Result, error) {
 	// 創建輸出目錄
 	if err := os.MkdirAll(d.config.OutputDir, 0755); err != nil {
-		return fmt.Errorf("創建輸出目錄失敗: %v", err)
+		return nil, fmt.Errorf("創建輸出目錄失敗: %v", err)
+	}
+
+	// yt-dlp 把最終文件信息寫到臨時文件，避免依賴 glob 猜測輸出文件
+	infoFile, err := os.CreateTemp("", "yt2mp3-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("創建臨時文件失敗: %v", err)
+	}
+	infoFile.Close()
+	defer os.Remove(infoFile.Name())
+
+	if d.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.Timeout)
+		defer cancel()
+	}
+
+	// 記錄已存在的文件，取消時只清理本次產生的臨時文件
+	existing := d.listPartialFiles()
+
+	// 多格式輸出時源音頻先下載到輸出目錄下的臨時目錄，轉碼後刪除
+	output := d.outputTemplate()
+	if len(d.config.Outputs) > 0 {
+		sourceDir, err := os.MkdirTemp(d.config.OutputDir, ".yt2mp3-source-")
+		if err != nil {
+			return nil, fmt.Errorf("創建臨時目錄失敗: %v", err)
+		}
+		defer os.RemoveAll(sourceDir)
+		output = d.config.ClipTemplate(sourceTemplate(sourceDir))
 	}
 
 	// 構建 yt-dlp 命令參數
-	args := d.buildArgs(url)
+	args := []string{"--print-to-file", infoTemplate(), infoFile.Name()}
+	if d.config.Playlist && d.useArchive() && !d.config.Force {
+		archiveCopy, err := d.copyArchive()
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(archiveCopy)
+		args = append(args, "--download-archive", archiveCopy)
+	}
+	args = append(args, d.commandArgs(url, output)...)
+
+	// 逐行解析輸出並轉發，同時記錄播放列表中失敗的條目，序號在下載結束後由 lookupPlaylistIndexes 查找
+	// stdout 和 stderr 由不同的 goroutine 寫入，共享狀態需要加鎖
+	var (
+		mu       sync.Mutex
+		failures []*Result
+	)
+	stdout := newLineWriter(func(line string) {
+		if event, ok := ParseProgressLine(line); ok && d.progress != nil {
+			d.progress(event)
+		}
+		fmt.Fprintln(d.stdout, line)
+	})
+	stderr := newLineWriter(func(line string) {
+		if failure := parseItemError(line); failure != nil {
+			mu.Lock()
+			failure.URL = url
+			failures = append(failures, failure)
+			mu.Unlock()
+		}
+		fmt.Fprintln(d.stderr, line)
+	})
 
 	// 執行命令
-	if err := d.executor.Execute("yt-dlp", args, os.Stdout, os.Stderr); err != nil {
-		return fmt.Errorf("下載失敗: %v", err)
+	err = d.executor.ExecuteContext(ctx, d.config.YtDlp(), args, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil && isMaxDownloadsReached(err) {
+		err = nil
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			d.cleanupPartialFiles(existing)
+			return nil, &CancelledError{URL: url, Err: ctxErr}
+		}
+		if !d.config.Playlist {
+			return nil, fmt.Errorf("下載失敗: %v", err)
+		}
+	}
+
+	infos, readErr := readInfoFile(infoFile.Name())
+	if readErr != nil {
+		return nil, fmt.Errorf("讀取下載結果失敗: %v", readErr)
+	}
+
+	if len(d.config.Outputs) > 0 {
+		var transcodeErr error
+		infos, failures, transcodeErr = d.transcodeAll(ctx, url, infos, failures)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, &CancelledError{URL: url, Err: ctxErr}
+		}
+		if transcodeErr != nil && !d.config.Playlist {
+			return nil, transcodeErr
+		}
+	}
+
+	var result *Result
+	switch {
+	case d.config.Playlist:
+		d.lookupPlaylistIndexes(ctx, url, failures)
+		result = newPlaylistResult(url, infos, failures)
+		// 只有所有條目都失敗時才視為整體失敗
+		if err != nil && len(result.Files) == 0 {
+			return result, fmt.Errorf("下載失敗: %v", err)
+		}
+	case len(infos) == 0:
+		return &Result{URL: url}, nil
+	default:
+		// 截取多個片段時每個片段一個 info
+		result = newResult(url, infos[0])
+		for _, info := range infos[1:] {
+			result.addInfo(info)
+		}
+	}
+
+	if !d.deferred {
+		if err := d.RecordArchive(result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// checkArchive 視頻已在存檔中時返回跳過的結果
+// 播放列表由 yt-dlp 的 --download-archive 逐條檢查，見 copyArchive
+func (d *YtDlpDownloader) checkArchive(url string) *Result {
+	if !d.useArchive() || d.config.Force || d.config.Playlist {
+		return nil
+	}
+	id, ok := ExtractVideoID(url)
+	if !ok || !d.archive.Has("youtube", id) {
+		return nil
+	}
+
+	result := &Result{URL: url, VideoID: id, Skipped: true}
+	for _, path := range d.archive.Paths("youtube", id) {
+		result.AddFile(path)
+	}
+	return result
+}
+
+// copyArchive 把存檔寫到臨時文件，作為播放列表模式 yt-dlp 的 --download-archive
+// yt-dlp 用它跳過已下載的條目，並把下載的條目追加到副本中；存檔本身只由 RecordArchive 寫入，
+// 這樣後處理失敗的條目不會被記錄，batch 並發的多個 yt-dlp 也不會同時寫同一個文件
+func (d *YtDlpDownloader) copyArchive() (string, error) {
+	file, err := os.CreateTemp("", "yt2mp3-archive-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("創建臨時文件失敗: %v", err)
+	}
+	defer file.Close()
+
+	if err := d.archive.Export(file); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// RecordArchive 實現 ArchiveRecorder 接口，把結果中完成的視頻及其最終文件寫入存檔，失敗的條目不記錄
+func (d *YtDlpDownloader) RecordArchive(result *Result) error {
+	if !d.useArchive() || result == nil || result.Skipped {
+		return nil
+	}
+	entries := []*Result{result}
+	if d.config.Playlist {
+		entries = result.Entries
 	}
 
+	for _, entry := range entries {
+		info := entry.Info
+		if entry.Skipped || entry.Err != nil || info == nil || info.ID == "" || info.ExtractorKey == "" {
+			continue
+		}
+		if err := d.archive.Add(info.ExtractorKey, info.ID, entry.Files); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// useArchive 是否使用下載存檔，只截取片段時不算下載了完整的視頻
+func (d *YtDlpDownloader) useArchive() bool {
+	return d.archive != nil && len(d.config.Clips) == 0
+}
+
 // buildArgs 構建 yt-dlp 命令參數
 func (d *YtDlpDownloader) buildArgs(url string) []string {
-	return []string{
-		"--extract-audio",
-		"--audio-format", d.config.AudioFormat,
-		"--audio-quality", d.config.AudioQuality,
-		"--postprocessor-args", fmt.Sprintf("ffmpeg:-b:a %s", d.config.Bitrate),
-		"--progress",    // 顯示進度
-		"--newline",     // 每個進度在新行顯示
-		"--no-playlist", // 只下載單個視頻，不下載播放列表
-		"-o", d.config.OutputTemplate,
-		url,
+	return d.commandArgs(url, d.outputTemplate())
+}
+
+// commandArgs 構建輸出到指定模板的 yt-dlp 命令參數
+func (d *YtDlpDownloader) commandArgs(url, output string) []string {
+	audioArgs := d.audioArgs()
+	if len(d.config.Outputs) > 0 {
+		audioArgs = d.sourceArgs()
+	}
+	args := append(audioArgs,
+		"--progress", // 顯示進度
+		"--newline",  // 每個進度在新行顯示
+	)
+	if d.config.Playlist {
+		args = append(args, d.playlistArgs()...)
+	} else {
+		args = append(args, "--no-playlist") // 只下載單個視頻，不下載播放列表
+	}
+	for _, clip := range d.config.Clips {
+		args = append(args, "--download-sections", clip.Section())
+	}
+	if d.config.FFmpegPath != "" {
+		// 讓 yt-dlp 與後處理使用同一個 ffmpeg，yt-dlp 會在同一目錄中查找 ffprobe
+		args = append(args, "--ffmpeg-location", d.config.FFmpegPath)
+	}
+	if d.config.Verbose {
+		args = append(args, "--verbose")
 	}
+	return append(args, "-o", output, url)
 }
 
-// GetOutputFiles 獲取輸出文件列表
+// audioArgs 構建音頻轉換參數
+// 固定比特率傳 --audio-quality 320K，yt-dlp 轉為 ffmpeg -b:a 320k；
+// VBR 傳 0-10 的質量，yt-dlp 轉為編碼器的 -q:a；無損格式不需要質量參數
+func (d *YtDlpDownloader) audioArgs() []string {
+	args := []string{"--extract-audio", "--audio-format", d.config.AudioFormat}
+	switch {
+	case d.config.Lossless():
+	case d.config.Bitrate != "":
+		args = append(args, "--audio-quality", strings.ToUpper(d.config.Bitrate))
+	default:
+		args = append(args, "--audio-quality", d.config.AudioQuality)
+	}
+	return args
+}
+
+// GetOutputFiles 列出輸出目錄中所有該格式的文件（媒體庫列表用，單次下載的文件見 Result.Files）
 func (d *YtDlpDownloader) GetOutputFiles() ([]string, error) {
-	pattern := filepath.Join(d.config.OutputDir, fmt.Sprintf("*.%s", d.config.AudioFormat))
+	pattern := filepath.Join(d.config.OutputDir, fmt.Sprintf("*.%s", d.config.Extension()))
 	files, err := filepath.Glob(pattern)
 	if err != nil {
 		return nil, fmt.Errorf("查找輸出文件失敗: %v", err)
@@ -81,13 +360,77 @@ func (d *YtDlpDownloader) GetOutputFiles() ([]string, error) {
 	return files, nil
 }
 
+// partialSuffixes yt-dlp/ffmpeg 中斷後可能殘留的臨時文件後綴
+var partialSuffixes = []string{".part", ".ytdl", ".webm", ".temp"}
+
+// listPartialFiles 列出輸出目錄及其子目錄中的臨時文件，播放列表模板默認輸出到以播放列表命名的子目錄
+func (d *YtDlpDownloader) listPartialFiles() map[string]bool {
+	files := make(map[string]bool)
+	_ = filepath.WalkDir(d.config.OutputDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// 無法讀取的目錄跳過，不影響其他目錄
+			return nil
+		}
+		if !entry.IsDir() && isPartialFile(entry.Name()) {
+			files[path] = true
+		}
+		return nil
+	})
+	return files
+}
+
+// cleanupPartialFiles 刪除本次下載產生的臨時文件，existing 中的文件保留
+func (d *YtDlpDownloader) cleanupPartialFiles(existing map[string]bool) {
+	for path := range d.listPartialFiles() {
+		if existing[path] {
+			continue
+		}
+		_ = os.Remove(path)
+	}
+}
+
+// isPartialFile 判斷文件名是否為臨時文件（包括 .part-Frag1 之類的分片）
+func isPartialFile(name string) bool {
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) || strings.Contains(name, suffix+"-Frag") {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelledError 下載被取消或超時
+type CancelledError struct {
+	URL string
+	Err error
+}
+
+// Error 實現 error 接口
+func (e *CancelledError) Error() string {
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return fmt.Sprintf("下載超時: %s", e.URL)
+	}
+	return fmt.Sprintf("下載已取消: %s", e.URL)
+}
+
+// Unwrap 返回底層的 context 錯誤
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
 // DefaultCommandExecutor 默認命令執行器
 type DefaultCommandExecutor struct{}
 
 // Execute 執行系統命令
 func (e *DefaultCommandExecutor) Execute(name string, args []string, stdout, stderr io.Writer) error {
-	cmd := exec.Command(name, args...)
+	return e.ExecuteContext(context.Background(), name, args, stdout, stderr)
+}
+
+// ExecuteContext 執行系統命令，ctx 取消時終止整個進程組
+func (e *DefaultCommandExecutor) ExecuteContext(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
+	setProcessGroup(cmd)
 	return cmd.Run()
 }
@@ -1,28 +1,44 @@
 package downloader
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"youtube_to_mp3/pkg/archive"
 	"youtube_to_mp3/pkg/config"
 )
 
 // MockCommandExecutor 模擬命令執行器
 type MockCommandExecutor struct {
-	executeFunc func(name string, args []string, stdout, stderr io.Writer) error
-	lastCommand string
-	lastArgs    []string
+	executeFunc        func(name string, args []string, stdout, stderr io.Writer) error
+	executeContextFunc func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+	mu                 sync.Mutex
+	lastCommand        string
+	lastArgs           []string
 }
 
 // Execute 執行命令（模擬實現）
 func (m *MockCommandExecutor) Execute(name string, args []string, stdout, stderr io.Writer) error {
+	return m.ExecuteContext(context.Background(), name, args, stdout, stderr)
+}
+
+// ExecuteContext 執行命令（模擬實現）
+func (m *MockCommandExecutor) ExecuteContext(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	m.mu.Lock()
 	m.lastCommand = name
 	m.lastArgs = args
+	m.mu.Unlock()
 
+	if m.executeContextFunc != nil {
+		return m.executeContextFunc(ctx, name, args, stdout, stderr)
+	}
 	if m.executeFunc != nil {
 		return m.executeFunc(name, args, stdout, stderr)
 	}
@@ -69,9 +85,7 @@ func TestBuildArgs(t *testing.T) {
 		"--audio-format",
 		"mp3",
 		"--audio-quality",
-		"0",
-		"--postprocessor-args",
-		"ffmpeg:-b:a 320k",
+		"320K",
 		"-o",
 		url,
 	}
@@ -89,6 +103,98 @@ func TestBuildArgs(t *testing.T) {
 	}
 }
 
+func TestBuildArgsPresets(t *testing.T) {
+	tests := []struct {
+		preset   string
+		expected string
+	}{
+		{"mp3-320", "--extract-audio --audio-format mp3 --audio-quality 320K --progress"},
+		{"mp3-v0", "--extract-audio --audio-format mp3 --audio-quality 0 --progress"},
+		{"mp3-v2", "--extract-audio --audio-format mp3 --audio-quality 2 --progress"},
+		{"opus-160", "--extract-audio --audio-format opus --audio-quality 160K --progress"},
+		{"m4a-256", "--extract-audio --audio-format m4a --audio-quality 256K --progress"},
+		{"ogg-192", "--extract-audio --audio-format vorbis --audio-quality 192K --progress"},
+		{"flac", "--extract-audio --audio-format flac --progress"},
+		{"wav", "--extract-audio --audio-format wav --progress"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			cfg := config.NewConfig()
+			if err := cfg.WithPreset(tt.preset); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			args := strings.Join(NewYtDlpDownloader(cfg, nil).buildArgs("https://youtu.be/a"), " ")
+			if !strings.HasPrefix(args, tt.expected) {
+				t.Errorf("Expected args to start with %q, got: %s", tt.expected, args)
+			}
+			if strings.Contains(args, "-b:a") {
+				t.Errorf("Expected no raw ffmpeg bitrate args, got: %s", args)
+			}
+		})
+	}
+
+	t.Run("lossless ignores bitrate", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.AudioFormat = "flac"
+		args := strings.Join(NewYtDlpDownloader(cfg, nil).buildArgs("https://youtu.be/a"), " ")
+		if strings.Contains(args, "--audio-quality") {
+			t.Errorf("Expected no quality args for flac, got: %s", args)
+		}
+	})
+}
+
+func TestBuildArgsPlaylistAndVerbose(t *testing.T) {
+	url := "https://www.youtube.com/playlist?list=PL123"
+
+	args := NewYtDlpDownloader(config.NewConfig(), nil).buildArgs(url)
+	argsStr := strings.Join(args, " ")
+	if !strings.Contains(argsStr, "--no-playlist") || strings.Contains(argsStr, "--verbose") {
+		t.Errorf("Expected --no-playlist without --verbose by default, got: %v", args)
+	}
+
+	cfg := config.NewConfig().WithPlaylist(true)
+	cfg.Verbose = true
+	args = NewYtDlpDownloader(cfg, nil).buildArgs(url)
+	argsStr = strings.Join(args, " ")
+	if !strings.Contains(argsStr, "--yes-playlist") || !strings.Contains(argsStr, "--verbose") {
+		t.Errorf("Expected --yes-playlist and --verbose, got: %v", args)
+	}
+	if strings.Contains(argsStr, "--no-playlist") {
+		t.Errorf("Expected no --no-playlist in playlist mode, got: %v", args)
+	}
+}
+
+func TestToolPaths(t *testing.T) {
+	url := "https://www.youtube.com/watch?v=test123"
+
+	args := NewYtDlpDownloader(config.NewConfig(), nil).buildArgs(url)
+	if strings.Contains(strings.Join(args, " "), "--ffmpeg-location") {
+		t.Errorf("Expected no --ffmpeg-location by default, got: %v", args)
+	}
+
+	cfg := config.NewConfig().WithOutputDir(t.TempDir())
+	cfg.YtDlpPath = "/opt/tools/yt-dlp"
+	cfg.FFmpegPath = "/opt/tools/ffmpeg"
+	var command string
+	mock := &MockCommandExecutor{
+		executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			command = name
+			return nil
+		},
+	}
+	dl := NewYtDlpDownloader(cfg, mock)
+
+	args = dl.buildArgs(url)
+	if !strings.Contains(strings.Join(args, " "), "--ffmpeg-location /opt/tools/ffmpeg") {
+		t.Errorf("Expected --ffmpeg-location, got: %v", args)
+	}
+	dl.Download(url)
+	if command != "/opt/tools/yt-dlp" {
+		t.Errorf("Expected configured yt-dlp path, got %q", command)
+	}
+}
+
 func TestDownload(t *testing.T) {
 	t.Run("successful download", func(t *testing.T) {
 		// 使用臨時目錄
@@ -105,7 +211,7 @@ func TestDownload(t *testing.T) {
 		downloader := NewYtDlpDownloader(cfg, mock)
 		url := "https://www.youtube.com/watch?v=test123"
 
-		err := downloader.Download(url)
+		_, err := downloader.Download(url)
 
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
@@ -135,7 +241,7 @@ func TestDownload(t *testing.T) {
 		downloader := NewYtDlpDownloader(cfg, mock)
 		url := "https://www.youtube.com/watch?v=test123"
 
-		err := downloader.Download(url)
+		_, err := downloader.Download(url)
 
 		if err == nil {
 			t.Error("Expected error when download fails")
@@ -156,12 +262,275 @@ func TestDownload(t *testing.T) {
 		downloader := NewYtDlpDownloader(cfg, mock)
 
 		url := "https://www.youtube.com/watch?v=test123"
-		_ = downloader.Download(url)
+		_, _ = downloader.Download(url)
 
 		// 檢查比特率參數
 		argsStr := strings.Join(mock.lastArgs, " ")
-		if !strings.Contains(argsStr, "256k") {
-			t.Errorf("Expected args to contain custom bitrate '256k', got: %v", mock.lastArgs)
+		if !strings.Contains(argsStr, "--audio-quality 256K") {
+			t.Errorf("Expected args to contain custom bitrate '256K', got: %v", mock.lastArgs)
+		}
+	})
+}
+
+// writeInfo 模擬 yt-dlp 的 --print-to-file，把 info JSON 寫入指定文件
+func writeInfo(t *testing.T, args []string, lines ...string) {
+	t.Helper()
+	for i, arg := range args {
+		if arg != "--print-to-file" {
+			continue
+		}
+		content := strings.Join(lines, "\n") + "\n"
+		if err := os.WriteFile(args[i+2], []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write info file: %v", err)
+		}
+		return
+	}
+	t.Fatal("Expected --print-to-file argument")
+}
+
+func TestDownloadResult(t *testing.T) {
+	tempDir := t.TempDir()
+	// 目錄中已有的文件不應影響結果
+	if err := os.WriteFile(filepath.Join(tempDir, "zzz old.mp3"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	output := filepath.Join(tempDir, "New Song.mp3")
+
+	cfg := config.NewConfig().WithOutputDir(tempDir)
+	mock := &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			if err := os.WriteFile(output, []byte("12345"), 0644); err != nil {
+				t.Fatalf("Failed to create output file: %v", err)
+			}
+			writeInfo(t, args, `{"id": "abc123", "title": "New Song", "duration": 61.5, "filepath": "`+output+`"}`)
+			return nil
+		},
+	}
+
+	downloader := NewYtDlpDownloader(cfg, mock)
+	result, err := downloader.Download("https://www.youtube.com/watch?v=abc123")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.VideoID != "abc123" || result.Title != "New Song" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Duration != 61500*time.Millisecond {
+		t.Errorf("Expected duration 61.5s, got %v", result.Duration)
+	}
+	if len(result.Files) != 1 || result.Files[0] != output {
+		t.Errorf("Expected files [%s], got %v", output, result.Files)
+	}
+	if result.Size != 5 {
+		t.Errorf("Expected size 5, got %d", result.Size)
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	url := "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	output := filepath.Join(tempDir, "Song.mp3")
+
+	cfg := config.NewConfig().WithOutputDir(tempDir)
+	arc, err := archive.Open(cfg.ArchiveFile())
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+
+	calls := 0
+	mock := &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			calls++
+			writeInfo(t, args, `{"id": "dQw4w9WgXcQ", "extractor_key": "Youtube", "title": "Song", "filepath": "`+output+`"}`)
+			return nil
+		},
+	}
+	downloader := NewYtDlpDownloader(cfg, mock)
+	downloader.SetArchive(arc)
+
+	t.Run("first download is recorded", func(t *testing.T) {
+		result, err := downloader.Download(url)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result.Skipped || calls != 1 {
+			t.Errorf("Expected yt-dlp to run once, calls=%d skipped=%v", calls, result.Skipped)
+		}
+		if !arc.Has("youtube", "dQw4w9WgXcQ") {
+			t.Error("Expected video to be archived")
+		}
+	})
+
+	t.Run("second download is skipped", func(t *testing.T) {
+		result, err := downloader.Download("https://youtu.be/dQw4w9WgXcQ")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !result.Skipped || calls != 1 {
+			t.Errorf("Expected archived video to be skipped, calls=%d skipped=%v", calls, result.Skipped)
+		}
+		if len(result.Files) != 1 || result.Files[0] != output {
+			t.Errorf("Expected archived path %s, got %v", output, result.Files)
+		}
+	})
+
+	t.Run("force bypasses archive", func(t *testing.T) {
+		cfg.Force = true
+		defer func() { cfg.Force = false }()
+
+		result, err := downloader.Download(url)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result.Skipped || calls != 2 {
+			t.Errorf("Expected forced download, calls=%d skipped=%v", calls, result.Skipped)
+		}
+	})
+
+	t.Run("playlist uses a copy of the archive", func(t *testing.T) {
+		cfg.Playlist = true
+		defer func() { cfg.Playlist = false }()
+
+		var copied string
+		playlist := NewYtDlpDownloader(cfg, &MockCommandExecutor{
+			executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+				if contains(args, "--flat-playlist") {
+					return nil
+				}
+				for i, arg := range args {
+					if arg != "--download-archive" {
+						continue
+					}
+					content, _ := os.ReadFile(args[i+1])
+					copied = string(content)
+					// yt-dlp 把下載的條目追加到副本中
+					_ = os.WriteFile(args[i+1], append(content, "youtube pl1\nyoutube pl2\n"...), 0644)
+				}
+				writeInfo(t, args, `{"id": "pl1", "extractor_key": "Youtube", "title": "One", "filepath": "`+output+`", "playlist_index": 1}`)
+				_, _ = io.WriteString(stderr, "ERROR: [youtube] pl2: Postprocessing: Conversion failed!\n")
+				return nil
+			},
+		})
+		playlist.SetOutput(io.Discard, io.Discard)
+		playlist.SetArchive(arc)
+
+		if _, err := playlist.Download("https://www.youtube.com/playlist?list=PL1"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if copied != "youtube dQw4w9WgXcQ\n" {
+			t.Errorf("Expected yt-dlp to get the archived entries, got %q", copied)
+		}
+		// 存檔只由下載器寫入，失敗的條目不記錄
+		content, _ := os.ReadFile(cfg.ArchiveFile())
+		if string(content) != "youtube dQw4w9WgXcQ\nyoutube pl1\n" {
+			t.Errorf("Expected only completed entries in the archive, got %q", content)
+		}
+	})
+}
+
+func TestDownloadContext(t *testing.T) {
+	t.Run("cancel removes partial files", func(t *testing.T) {
+		tempDir := t.TempDir()
+		// 下載前已存在的文件不應被刪除
+		oldPart := filepath.Join(tempDir, "old.webm")
+		if err := os.WriteFile(oldPart, []byte("old"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		cfg := config.NewConfig().WithOutputDir(tempDir)
+		ctx, cancel := context.WithCancel(context.Background())
+		mock := &MockCommandExecutor{
+			executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+				for _, name := range []string{"song.webm.part", "song.webm", "song.f251.webm.part-Frag3"} {
+					if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x"), 0644); err != nil {
+						t.Fatalf("Failed to create test file: %v", err)
+					}
+				}
+				cancel()
+				return ctx.Err()
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		_, err := downloader.DownloadContext(ctx, "https://www.youtube.com/watch?v=test123")
+
+		var cancelled *CancelledError
+		if !errors.As(err, &cancelled) {
+			t.Fatalf("Expected CancelledError, got: %v", err)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected error to wrap context.Canceled, got: %v", err)
+		}
+
+		entries, _ := os.ReadDir(tempDir)
+		if len(entries) != 1 || entries[0].Name() != "old.webm" {
+			t.Errorf("Expected only old.webm to remain, got: %v", entries)
+		}
+	})
+
+	t.Run("cancel removes partial files in playlist directories", func(t *testing.T) {
+		tempDir := t.TempDir()
+		playlistDir := filepath.Join(tempDir, "Mix")
+		if err := os.MkdirAll(playlistDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// 之前中斷的下載留下的文件保留，yt-dlp 可以繼續下載
+		oldPart := filepath.Join(playlistDir, "1 - Old.webm.part")
+		if err := os.WriteFile(oldPart, []byte("old"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		cfg := config.NewConfig().WithOutputDir(tempDir).WithPlaylist(true)
+		ctx, cancel := context.WithCancel(context.Background())
+		mock := &MockCommandExecutor{
+			executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+				for _, name := range []string{"2 - Song.webm.part", "2 - Song.webm.ytdl", "1 - Done.mp3"} {
+					if err := os.WriteFile(filepath.Join(playlistDir, name), []byte("x"), 0644); err != nil {
+						t.Fatalf("Failed to create test file: %v", err)
+					}
+				}
+				cancel()
+				return ctx.Err()
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		downloader.SetOutput(io.Discard, io.Discard)
+		_, err := downloader.DownloadContext(ctx, "https://www.youtube.com/playlist?list=PL123")
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected cancellation, got: %v", err)
+		}
+
+		var remaining []string
+		entries, _ := os.ReadDir(playlistDir)
+		for _, entry := range entries {
+			remaining = append(remaining, entry.Name())
+		}
+		if strings.Join(remaining, ",") != "1 - Done.mp3,1 - Old.webm.part" {
+			t.Errorf("Expected partial files in the playlist directory to be removed, got: %v", remaining)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		cfg := config.NewConfig().WithOutputDir(tempDir).WithTimeout(10 * time.Millisecond)
+		mock := &MockCommandExecutor{
+			executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		_, err := downloader.DownloadContext(context.Background(), "https://www.youtube.com/watch?v=test123")
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected deadline exceeded, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "超時") {
+			t.Errorf("Expected error message to contain '超時', got: %v", err)
 		}
 	})
 }
@@ -235,4 +604,19 @@ func TestDefaultCommandExecutor(t *testing.T) {
 			t.Error("Expected error for failing command")
 		}
 	})
+
+	t.Run("cancel kills process group", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		// 子進程 sleep 繼承了 stdout，只有整個進程組被終止時 Run 才會及時返回
+		err := executor.ExecuteContext(ctx, "sh", []string{"-c", "sleep 30 & wait"}, io.Discard, io.Discard)
+		if err == nil {
+			t.Error("Expected error for cancelled command")
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("Expected command to be killed promptly, took %v", elapsed)
+		}
+	})
 }
@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// Job 下載任務
+type Job struct {
+	Index int // 在隊列中的序號，從 0 開始
+	URL   string
+}
+
+// JobResult 下載任務的結果
+type JobResult struct {
+	Job
+	Result *Result
+	Err    error
+}
+
+// DownloaderFactory 為每個任務創建下載器，stdout/stderr 是該任務專屬的輸出
+type DownloaderFactory func(stdout, stderr io.Writer) Downloader
+
+// NewYtDlpFactory 返回創建 YtDlpDownloader 的工廠，所有任務共用配置和執行器
+func NewYtDlpFactory(cfg *config.Config, executor CommandExecutor) DownloaderFactory {
+	return func(stdout, stderr io.Writer) Downloader {
+		d := NewYtDlpDownloader(cfg, executor)
+		d.SetOutput(stdout, stderr)
+		return d
+	}
+}
+
+// Manager 並發下載管理器，固定數量的 worker 從共享隊列中取任務
+type Manager struct {
+	workers  int
+	factory  DownloaderFactory
+	output   io.Writer
+	outputMu sync.Mutex
+	onResult func(JobResult)
+}
+
+// NewManager 創建下載管理器，workers 小於 1 時按 1 處理
+// 各任務的輸出按行加上 "[序號]" 前綴寫入 output，不同任務的行不會交錯
+func NewManager(workers int, factory DownloaderFactory, output io.Writer) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	if output == nil {
+		output = io.Discard
+	}
+	return &Manager{
+		workers: workers,
+		factory: factory,
+		output:  output,
+	}
+}
+
+// OnResult 設置結果回調，回調按任務序號依次調用，與完成順序無關
+func (m *Manager) OnResult(fn func(JobResult)) {
+	m.onResult = fn
+}
+
+// Run 下載所有 URL，返回按序號排列的結果
+// ctx 取消後不再開始新任務，未開始的任務以 CancelledError 結束
+func (m *Manager) Run(ctx context.Context, urls []string) []JobResult {
+	jobs := make(chan Job)
+	done := make(chan JobResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				done <- m.runJob(ctx, job)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i, url := range urls {
+			select {
+			case jobs <- Job{Index: i, URL: url}:
+			case <-ctx.Done():
+				for j := i; j < len(urls); j++ {
+					done <- JobResult{
+						Job: Job{Index: j, URL: urls[j]},
+						Err: &CancelledError{URL: urls[j], Err: ctx.Err()},
+					}
+				}
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return m.collect(done, len(urls))
+}
+
+// runJob 用專屬輸出運行單個任務
+func (m *Manager) runJob(ctx context.Context, job Job) JobResult {
+	if err := ctx.Err(); err != nil {
+		return JobResult{Job: job, Err: &CancelledError{URL: job.URL, Err: err}}
+	}
+
+	stdout := m.prefixWriter(job)
+	stderr := m.prefixWriter(job)
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	result, err := m.factory(stdout, stderr).DownloadContext(ctx, job.URL)
+	return JobResult{Job: job, Result: result, Err: err}
+}
+
+// collect 收集結果並按序號順序回調
+func (m *Manager) collect(done <-chan JobResult, total int) []JobResult {
+	results := make([]JobResult, total)
+	ready := make([]bool, total)
+	next := 0
+
+	for result := range done {
+		results[result.Index] = result
+		ready[result.Index] = true
+		for next < total && ready[next] {
+			if m.onResult != nil {
+				m.onResult(results[next])
+			}
+			next++
+		}
+	}
+	return results
+}
+
+// prefixWriter 返回給每行加上任務序號前綴的 writer，整行寫入共享輸出
+func (m *Manager) prefixWriter(job Job) *lineWriter {
+	prefix := fmt.Sprintf("[%d] ", job.Index+1)
+	return newLineWriter(func(line string) {
+		m.outputMu.Lock()
+		defer m.outputMu.Unlock()
+		fmt.Fprintf(m.output, "%s%s\n", prefix, line)
+	})
+}
@@ -0,0 +1,187 @@
+package downloader_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+// sharedFactory 所有任務共用同一個 mock 下載器
+func sharedFactory(mock *mocks.Downloader) downloader.DownloaderFactory {
+	return func(stdout, stderr io.Writer) downloader.Downloader {
+		return mock
+	}
+}
+
+func TestManagerBoundedParallelism(t *testing.T) {
+	var running, maxRunning int32
+	mock := &mocks.Downloader{
+		DownloadFunc: func(url string) (*downloader.Result, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&maxRunning)
+				if n <= old || atomic.CompareAndSwapInt32(&maxRunning, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return &downloader.Result{URL: url}, nil
+		},
+	}
+
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://youtu.be/%d", i)
+	}
+
+	manager := downloader.NewManager(3, sharedFactory(mock), nil)
+	results := manager.Run(context.Background(), urls)
+
+	if len(results) != len(urls) {
+		t.Fatalf("Expected %d results, got %d", len(urls), len(results))
+	}
+	if got := atomic.LoadInt32(&maxRunning); got > 3 {
+		t.Errorf("Expected at most 3 concurrent downloads, got %d", got)
+	}
+	if len(mock.URLs()) != len(urls) {
+		t.Errorf("Expected %d downloads, got %d", len(urls), len(mock.URLs()))
+	}
+}
+
+func TestManagerOrderedResults(t *testing.T) {
+	mock := &mocks.Downloader{
+		ShouldFailOnURL: "https://youtu.be/1",
+		DownloadFunc: func(url string) (*downloader.Result, error) {
+			// 越靠前的任務越晚完成
+			if strings.HasSuffix(url, "/0") {
+				time.Sleep(30 * time.Millisecond)
+			}
+			return &downloader.Result{URL: url}, nil
+		},
+	}
+	urls := []string{"https://youtu.be/0", "https://youtu.be/1", "https://youtu.be/2"}
+
+	manager := downloader.NewManager(3, sharedFactory(mock), nil)
+	var order []int
+	manager.OnResult(func(r downloader.JobResult) {
+		order = append(order, r.Index)
+	})
+	results := manager.Run(context.Background(), urls)
+
+	for i, r := range results {
+		if r.Index != i || r.URL != urls[i] {
+			t.Errorf("Expected result %d for %s, got %+v", i, urls[i], r)
+		}
+	}
+	if results[1].Err == nil {
+		t.Error("Expected job 1 to fail")
+	}
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("Expected jobs 0 and 2 to succeed: %v, %v", results[0].Err, results[2].Err)
+	}
+	if fmt.Sprint(order) != "[0 1 2]" {
+		t.Errorf("Expected callbacks in order [0 1 2], got %v", order)
+	}
+}
+
+func TestManagerIsolatesOutput(t *testing.T) {
+	factory := func(stdout, stderr io.Writer) downloader.Downloader {
+		return &mocks.Downloader{
+			DownloadFunc: func(url string) (*downloader.Result, error) {
+				for i := 0; i < 50; i++ {
+					// 分兩次寫入同一行，檢查不同任務的行不會交錯
+					_, _ = io.WriteString(stdout, "[download] "+url)
+					_, _ = io.WriteString(stdout, " progress\n")
+				}
+				return &downloader.Result{URL: url}, nil
+			},
+		}
+	}
+
+	var out safeBuffer
+	manager := downloader.NewManager(4, factory, &out)
+	manager.Run(context.Background(), []string{"a", "b", "c", "d"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("Expected 200 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var index int
+		var url string
+		if _, err := fmt.Sscanf(line, "[%d] [download] %s progress", &index, &url); err != nil {
+			t.Fatalf("Malformed line %q: %v", line, err)
+		}
+		if expected := string(rune('a' + index - 1)); url != expected {
+			t.Errorf("Line %q has prefix %d but url %s", line, index, url)
+		}
+	}
+}
+
+func TestManagerCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, 10)
+
+	factory := func(stdout, stderr io.Writer) downloader.Downloader {
+		return &blockingDownloader{started: started}
+	}
+
+	urls := []string{"a", "b", "c", "d", "e"}
+	manager := downloader.NewManager(2, factory, nil)
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	results := manager.Run(ctx, urls)
+
+	if len(results) != len(urls) {
+		t.Fatalf("Expected %d results, got %d", len(urls), len(results))
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("Expected job %d to be cancelled, got: %v", r.Index, r.Err)
+		}
+	}
+}
+
+// blockingDownloader 一直阻塞到 ctx 被取消
+type blockingDownloader struct {
+	mocks.Downloader
+	started chan<- struct{}
+}
+
+func (d *blockingDownloader) DownloadContext(ctx context.Context, url string) (*downloader.Result, error) {
+	d.started <- struct{}{}
+	<-ctx.Done()
+	return nil, &downloader.CancelledError{URL: url, Err: ctx.Err()}
+}
+
+// safeBuffer 並發安全的 bytes.Buffer
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
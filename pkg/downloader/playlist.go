@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDownloadsExitCode yt-dlp 達到 --max-downloads 上限時的退出碼
+const maxDownloadsExitCode = 101
+
+// ERROR: [youtube] dQw4w9WgXcQ: Video unavailable
+var itemErrorRe = regexp.MustCompile(`^ERROR: \[[\w:]+\] ([\w-]+): (.+)$`)
+
+// playlistArgs 構建播放列表模式的 yt-dlp 參數
+func (d *YtDlpDownloader) playlistArgs() []string {
+	args := append([]string{
+		"--yes-playlist",
+		"--ignore-errors", // 單個條目失敗時繼續下載其餘條目
+	}, d.playlistRangeArgs()...)
+	if d.config.PlaylistMaxItems > 0 {
+		args = append(args, "--max-downloads", strconv.Itoa(d.config.PlaylistMaxItems))
+	}
+	return args
+}
+
+// playlistRangeArgs 構建選擇播放列表條目的參數
+func (d *YtDlpDownloader) playlistRangeArgs() []string {
+	var args []string
+	if d.config.PlaylistItems != "" {
+		args = append(args, "--playlist-items", d.config.PlaylistItems)
+	}
+	if d.config.PlaylistReverse {
+		args = append(args, "--playlist-reverse")
+	}
+	return args
+}
+
+// lookupPlaylistIndexes 給 yt-dlp 報錯的條目補上在播放列表中的序號
+// 錯誤行只有視頻 ID，"Downloading item N of M" 是本次下載的計數，使用 -playlist-items 或倒序時
+// 與 playlist_index 不一致，所以用 --flat-playlist 列出同一範圍的條目，按視頻 ID 查找序號。
+// 只在有失敗的條目時運行，--flat-playlist 只讀取播放列表頁面，不解析每個視頻；查找失敗時序號保持為 0
+func (d *YtDlpDownloader) lookupPlaylistIndexes(ctx context.Context, url string, failures []*Result) {
+	var missing []*Result
+	for _, failure := range failures {
+		if failure.PlaylistIndex == 0 && failure.VideoID != "" {
+			missing = append(missing, failure)
+		}
+	}
+	if len(missing) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	args := append([]string{"--flat-playlist", "--yes-playlist", "--ignore-errors", "--no-warnings",
+		"--print", "%(playlist_index)s %(id)s"}, d.playlistRangeArgs()...)
+	var stdout bytes.Buffer
+	// 部分條目無法列出時 yt-dlp 返回錯誤，已輸出的條目仍然可用
+	_ = d.executor.ExecuteContext(ctx, d.config.YtDlp(), append(args, url), &stdout, io.Discard)
+
+	indexes := make(map[string]int)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if index, err := strconv.Atoi(fields[0]); err == nil {
+			indexes[fields[1]] = index
+		}
+	}
+	for _, failure := range missing {
+		failure.PlaylistIndex = indexes[failure.VideoID]
+	}
+}
+
+// outputTemplate 返回 -o 參數，播放列表模式使用播放列表模板，截取片段時文件名帶上時間範圍
+func (d *YtDlpDownloader) outputTemplate() string {
+	template := d.config.OutputTemplate
+	if d.config.Playlist && d.config.PlaylistTemplate != "" {
+		template = d.config.PlaylistTemplate
+		if !filepath.IsAbs(template) {
+			template = filepath.Join(d.config.OutputDir, template)
+		}
+	}
+	return d.config.ClipTemplate(template)
+}
+
+// parseItemError 解析 yt-dlp 的條目錯誤行
+func parseItemError(line string) *Result {
+	m := itemErrorRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	return &Result{VideoID: m[1], Err: errors.New(m[2])}
+}
+
+// newPlaylistResult 匯總播放列表中成功和失敗的條目，按序號排序，找不到序號的失敗條目按出錯順序排在最後
+func newPlaylistResult(url string, infos []*VideoInfo, failures []*Result) *Result {
+	result := &Result{URL: url}
+	for _, info := range infos {
+		entry := newResult(url, info)
+		if result.Title == "" {
+			result.Title = info.PlaylistTitle
+		}
+		result.Files = append(result.Files, entry.Files...)
+		result.Size += entry.Size
+		result.Entries = append(result.Entries, entry)
+	}
+	result.Entries = append(result.Entries, failures...)
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		a, b := result.Entries[i].PlaylistIndex, result.Entries[j].PlaylistIndex
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+	return result
+}
+
+// Failed 返回失敗的播放列表條目
+func (r *Result) Failed() []*Result {
+	var failed []*Result
+	for _, entry := range r.Entries {
+		if entry.Err != nil {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// isMaxDownloadsReached 判斷 yt-dlp 是否因達到 --max-downloads 上限而退出
+func isMaxDownloadsReached(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == maxDownloadsExitCode
+}
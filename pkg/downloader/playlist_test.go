@@ -0,0 +1,179 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+func TestPlaylistArgs(t *testing.T) {
+	cfg := config.NewConfig().WithOutputDir("music").WithPlaylist(true)
+	cfg.PlaylistItems = "1-10,15"
+	cfg.PlaylistReverse = true
+	cfg.PlaylistMaxItems = 5
+
+	url := "https://www.youtube.com/playlist?list=PL123"
+	args := NewYtDlpDownloader(cfg, nil).buildArgs(url)
+	argsStr := strings.Join(args, " ")
+
+	expectedParams := []string{
+		"--yes-playlist",
+		"--ignore-errors",
+		"--playlist-items 1-10,15",
+		"--playlist-reverse",
+		"--max-downloads 5",
+		"-o " + filepath.Join("music", "%(playlist_title)s", "%(playlist_index)s - %(title)s.%(ext)s"),
+	}
+	for _, param := range expectedParams {
+		if !strings.Contains(argsStr, param) {
+			t.Errorf("Expected args to contain '%s', got: %v", param, args)
+		}
+	}
+	if args[len(args)-1] != url {
+		t.Errorf("Expected last arg to be URL '%s', got '%s'", url, args[len(args)-1])
+	}
+}
+
+func TestPlaylistDownload(t *testing.T) {
+	t.Run("partial failure", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir()).WithPlaylist(true)
+		mock := &MockCommandExecutor{
+			executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+				if contains(args, "--flat-playlist") {
+					_, _ = io.WriteString(stdout, "1 a1\n2 dead123\n3 c3\n")
+					return nil
+				}
+				_, _ = io.WriteString(stdout, "[download] Downloading item 1 of 3\n")
+				_, _ = io.WriteString(stdout, "[download] Downloading item 2 of 3\n")
+				_, _ = io.WriteString(stderr, "ERROR: [youtube] dead123: Video unavailable\n")
+				_, _ = io.WriteString(stdout, "[download] Downloading item 3 of 3\n")
+				writeInfo(t, args,
+					`{"id": "a1", "title": "One", "filepath": "out/1 - One.mp3", "playlist_title": "Mix", "playlist_index": 1}`,
+					`{"id": "c3", "title": "Three", "filepath": "out/3 - Three.mp3", "playlist_title": "Mix", "playlist_index": 3}`,
+				)
+				return errors.New("exit status 1")
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		downloader.SetOutput(io.Discard, io.Discard)
+		result, err := downloader.Download("https://www.youtube.com/playlist?list=PL123")
+		if err != nil {
+			t.Fatalf("Expected partial failure to succeed, got: %v", err)
+		}
+
+		if result.Title != "Mix" {
+			t.Errorf("Expected playlist title 'Mix', got '%s'", result.Title)
+		}
+		if len(result.Entries) != 3 {
+			t.Fatalf("Expected 3 entries, got %d", len(result.Entries))
+		}
+		for i, entry := range result.Entries {
+			if entry.PlaylistIndex != i+1 {
+				t.Errorf("Expected entry %d to have index %d, got %d", i, i+1, entry.PlaylistIndex)
+			}
+		}
+		failed := result.Failed()
+		if len(failed) != 1 || failed[0].VideoID != "dead123" || failed[0].PlaylistIndex != 2 {
+			t.Errorf("Expected dead123 at index 2 to fail, got: %+v", failed)
+		}
+		if len(result.Files) != 2 {
+			t.Errorf("Expected 2 files, got %v", result.Files)
+		}
+	})
+
+	t.Run("playlist items with a gap", func(t *testing.T) {
+		// -playlist-items 1-2,15：yt-dlp 的計數為 1 到 3，第 3 個是 playlist_index 15
+		cfg := config.NewConfig().WithOutputDir(t.TempDir()).WithPlaylist(true)
+		cfg.PlaylistItems = "1-2,15"
+		mock := &MockCommandExecutor{
+			executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+				if !strings.Contains(strings.Join(args, " "), "--playlist-items 1-2,15") {
+					t.Errorf("Expected --playlist-items, got: %v", args)
+				}
+				if contains(args, "--flat-playlist") {
+					_, _ = io.WriteString(stdout, "1 a1\n2 b2\n15 dead015\n")
+					return nil
+				}
+				_, _ = io.WriteString(stdout, "[download] Downloading item 1 of 3\n")
+				_, _ = io.WriteString(stdout, "[download] Downloading item 2 of 3\n")
+				_, _ = io.WriteString(stdout, "[download] Downloading item 3 of 3\n")
+				_, _ = io.WriteString(stderr, "ERROR: [youtube] dead015: Video unavailable\n")
+				writeInfo(t, args,
+					`{"id": "b2", "title": "Two", "filepath": "out/02 - Two.mp3", "playlist_title": "Mix", "playlist_index": 2}`,
+					`{"id": "a1", "title": "One", "filepath": "out/01 - One.mp3", "playlist_title": "Mix", "playlist_index": 1}`,
+				)
+				return errors.New("exit status 1")
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		downloader.SetOutput(io.Discard, io.Discard)
+		result, err := downloader.Download("https://www.youtube.com/playlist?list=PL123")
+		if err != nil {
+			t.Fatalf("Expected partial failure to succeed, got: %v", err)
+		}
+
+		var ids []string
+		for _, entry := range result.Entries {
+			ids = append(ids, entry.VideoID)
+		}
+		if strings.Join(ids, ",") != "a1,b2,dead015" {
+			t.Errorf("Expected entries a1,b2,dead015, got %v", ids)
+		}
+		// 計數 3 不是真實的序號
+		if failed := result.Failed(); len(failed) != 1 || failed[0].PlaylistIndex != 15 {
+			t.Errorf("Expected dead015 to fail at index 15, got: %+v", failed)
+		}
+	})
+
+	t.Run("unknown index sorts last", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir()).WithPlaylist(true)
+		mock := &MockCommandExecutor{
+			executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+				if contains(args, "--flat-playlist") {
+					return errors.New("exit status 1")
+				}
+				_, _ = io.WriteString(stderr, "ERROR: [youtube] dead123: Video unavailable\n")
+				writeInfo(t, args,
+					`{"id": "b2", "title": "Two", "filepath": "out/2 - Two.mp3", "playlist_title": "Mix", "playlist_index": 2}`,
+				)
+				return errors.New("exit status 1")
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		downloader.SetOutput(io.Discard, io.Discard)
+		result, err := downloader.Download("https://www.youtube.com/playlist?list=PL123")
+		if err != nil {
+			t.Fatalf("Expected partial failure to succeed, got: %v", err)
+		}
+		if len(result.Entries) != 2 || result.Entries[1].VideoID != "dead123" || result.Entries[1].PlaylistIndex != 0 {
+			t.Errorf("Expected dead123 without an index after b2, got: %+v", result.Entries)
+		}
+	})
+
+	t.Run("all items fail", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir()).WithPlaylist(true)
+		mock := &MockCommandExecutor{
+			executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+				_, _ = io.WriteString(stderr, "ERROR: [youtube] dead123: Video unavailable\n")
+				return errors.New("exit status 1")
+			},
+		}
+
+		downloader := NewYtDlpDownloader(cfg, mock)
+		downloader.SetOutput(io.Discard, io.Discard)
+		result, err := downloader.Download("https://www.youtube.com/playlist?list=PL123")
+		if err == nil {
+			t.Fatal("Expected error when all items fail")
+		}
+		if result == nil || len(result.Failed()) != 1 {
+			t.Errorf("Expected failed entries to be reported, got: %+v", result)
+		}
+	})
+}
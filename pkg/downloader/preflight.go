@@ -0,0 +1,71 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// PreflightFunc 下載前的檢查，info 為預先獲取的視頻信息，沒有設置 Config.SpaceEstimate、播放列表、
+// 不檢查空間或獲取失敗時為 nil
+// 返回錯誤時不運行 yt-dlp 下載，返回的警告加入 Result.Warnings
+type PreflightFunc func(info *VideoInfo) (warnings []string, err error)
+
+// probeFields 預先獲取的字段，用於估算需要的磁盤空間
+var probeFields = []string{"id", "title", "duration", "filesize", "filesize_approx"}
+
+// SetPreflight 設置下載前的檢查，設置了 Config.SpaceEstimate 且 Config.SpaceCheck 不為 off 時
+// 先用 yt-dlp 獲取單個視頻的信息再檢查，否則不額外運行 yt-dlp
+func (d *YtDlpDownloader) SetPreflight(fn PreflightFunc) {
+	d.preflight = fn
+}
+
+// Probe 只解析視頻信息不下載，選擇的格式與下載時相同，返回的 Fields 包含 filesize_approx 等字段
+func (d *YtDlpDownloader) Probe(ctx context.Context, url string) (*VideoInfo, error) {
+	args := d.audioArgs()
+	if len(d.config.Outputs) > 0 {
+		args = d.sourceArgs()
+	}
+	// --print 隱含 --simulate 和 --quiet
+	args = append(args, "--no-playlist", "--no-warnings",
+		"--print", fmt.Sprintf("%%(.{%s})j", strings.Join(probeFields, ",")), url)
+
+	var stdout, stderr bytes.Buffer
+	if err := d.executor.ExecuteContext(ctx, d.config.YtDlp(), args, &stdout, &stderr); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, &CancelledError{URL: url, Err: ctxErr}
+		}
+		return nil, fmt.Errorf("獲取視頻信息失敗: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	return ParseVideoInfo([]byte(lines[len(lines)-1]))
+}
+
+// runPreflight 運行下載前的檢查，獲取視頻信息失敗時只檢查輸出目錄和保留空間，由下載報告實際的錯誤
+func (d *YtDlpDownloader) runPreflight(ctx context.Context, url string) ([]string, error) {
+	if d.preflight == nil {
+		return nil, nil
+	}
+
+	var info *VideoInfo
+	if d.config.SpaceEstimate && d.config.SpaceCheck != config.SpaceCheckOff && !d.config.Playlist {
+		if d.config.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.config.Timeout)
+			defer cancel()
+		}
+		probed, err := d.Probe(ctx, url)
+		var cancelled *CancelledError
+		if errors.As(err, &cancelled) {
+			return nil, err
+		}
+		if err == nil {
+			info = probed
+		}
+	}
+	return d.preflight(info)
+}
@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// probeMock 對 --print 返回視頻信息，對下載寫出 info 文件，並記錄下載的次數
+func probeMock(t *testing.T, output string, downloads *int) *MockCommandExecutor {
+	return &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			if contains(args, "--print") {
+				io.WriteString(stdout, `{"id": "abc123", "title": "Song", "duration": 200, "filesize_approx": 3200000}`+"\n")
+				return nil
+			}
+			*downloads++
+			writeInfo(t, args, `{"id": "abc123", "title": "Song", "filepath": "`+output+`"}`)
+			return nil
+		},
+	}
+}
+
+func TestProbe(t *testing.T) {
+	cfg := config.NewConfig().WithOutputDir(t.TempDir())
+	var downloads int
+	mock := probeMock(t, "", &downloads)
+
+	info, err := NewYtDlpDownloader(cfg, mock).Probe(context.Background(), "https://youtu.be/abc123")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if info.ID != "abc123" || fieldFloat(info.Fields, "filesize_approx") != 3200000 {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	args := strings.Join(mock.lastArgs, " ")
+	// 與下載時一樣選擇音頻格式，但不下載
+	if !strings.Contains(args, "--extract-audio --audio-format mp3") || strings.Contains(args, " -o ") {
+		t.Errorf("Unexpected probe args: %s", args)
+	}
+	if downloads != 0 {
+		t.Errorf("Expected probe not to download, got %d downloads", downloads)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	url := "https://youtu.be/abc123"
+
+	t.Run("refused", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir())
+		cfg.SpaceEstimate = true
+		var downloads int
+		var probed *VideoInfo
+		dl := NewYtDlpDownloader(cfg, probeMock(t, "", &downloads))
+		dl.SetPreflight(func(info *VideoInfo) ([]string, error) {
+			probed = info
+			return nil, errors.New("可用空間不足")
+		})
+
+		_, err := dl.Download(url)
+		if err == nil || err.Error() != "可用空間不足" {
+			t.Errorf("Expected preflight error, got: %v", err)
+		}
+		if downloads != 0 {
+			t.Errorf("Expected no download, got %d", downloads)
+		}
+		if probed == nil || probed.Title != "Song" {
+			t.Errorf("Expected probed info, got %+v", probed)
+		}
+	})
+
+	t.Run("warnings added to result", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := config.NewConfig().WithOutputDir(dir)
+		cfg.SpaceEstimate = true
+		var downloads int
+		dl := NewYtDlpDownloader(cfg, probeMock(t, filepath.Join(dir, "Song.mp3"), &downloads))
+		dl.SetPreflight(func(info *VideoInfo) ([]string, error) {
+			return []string{"可用空間不足"}, nil
+		})
+
+		result, err := dl.Download(url)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if downloads != 1 || len(result.Warnings) != 1 || result.Warnings[0] != "可用空間不足" {
+			t.Errorf("Expected download with warning, got %d downloads, %v", downloads, result.Warnings)
+		}
+	})
+
+	t.Run("no probe by default, when space check is off or for playlists", func(t *testing.T) {
+		for _, modify := range []func(cfg *config.Config){
+			func(cfg *config.Config) { cfg.SpaceEstimate = false },
+			func(cfg *config.Config) { cfg.SpaceCheck = config.SpaceCheckOff },
+			func(cfg *config.Config) { cfg.Playlist = true },
+		} {
+			cfg := config.NewConfig().WithOutputDir(t.TempDir())
+			cfg.SpaceEstimate = true
+			modify(cfg)
+			var downloads int
+			called := false
+			dl := NewYtDlpDownloader(cfg, probeMock(t, "", &downloads))
+			dl.SetPreflight(func(info *VideoInfo) ([]string, error) {
+				called = true
+				if info != nil {
+					t.Errorf("Expected no probed info, got %+v", info)
+				}
+				return nil, nil
+			})
+			dl.Download(url)
+			if !called || downloads != 1 {
+				t.Errorf("Expected preflight and one download, got %v, %d", called, downloads)
+			}
+		}
+	})
+}
+
+// fieldFloat 讀取 info JSON 中的數字字段
+func fieldFloat(fields map[string]interface{}, name string) float64 {
+	n, _ := fields[name].(float64)
+	return n
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package downloader
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup 讓命令在獨立的進程組中運行，取消時連同 ffmpeg 等子進程一起終止
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+}
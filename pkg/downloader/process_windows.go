@@ -0,0 +1,17 @@
+//go:build windows
+
+package downloader
+
+import (
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// setProcessGroup 取消時使用 taskkill /T 終止整個進程樹
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+	cmd.WaitDelay = 5 * time.Second
+}
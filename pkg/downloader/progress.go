@@ -0,0 +1,207 @@
+package downloader
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Phase 處理階段
+type Phase string
+
+const (
+	PhaseDownload Phase = "download" // 下載音視頻流
+	PhaseExtract  Phase = "extract"  // 提取音頻
+	PhaseConvert  Phase = "convert"  // ffmpeg 轉碼/修復
+	PhaseTag      Phase = "tag"      // 寫入標籤、封面
+)
+
+// ProgressEvent 從 yt-dlp 輸出解析出的進度事件
+type ProgressEvent struct {
+	Phase           Phase
+	Percent         float64       // 0-100，未知時為 0
+	DownloadedBytes int64         // 已下載字節數（由百分比和總大小估算）
+	TotalBytes      int64         // 總字節數，未知時為 0
+	Speed           float64       // 字節/秒
+	ETA             time.Duration // 預計剩餘時間
+	FilePath        string        // 當前處理的文件路徑
+	Final           bool          // FilePath 是否為最終輸出文件
+	Item            int           // 播放列表中當前條目的序號，從 1 開始
+	ItemCount       int           // 播放列表條目總數
+	Line            string        // 原始輸出行
+}
+
+// ProgressHandler 進度事件回調
+type ProgressHandler func(ProgressEvent)
+
+// ChannelHandler 返回把事件發送到 ch 的回調，發送會阻塞直到 ch 被讀取
+func ChannelHandler(ch chan<- ProgressEvent) ProgressHandler {
+	return func(event ProgressEvent) {
+		ch <- event
+	}
+}
+
+var (
+	// [download]  45.3% of ~  3.45MiB at  512.00KiB/s ETA 00:04 (frag 3/10)
+	// [download] 100% of    3.45MiB in 00:00:05 at 645.23KiB/s
+	downloadProgressRe = regexp.MustCompile(
+		`^\[download\]\s+([\d.]+)%\s+of\s+~?\s*([\d.]+\s*[KMGT]?i?B)` +
+			`(?:\s+in\s+[\d:]+)?(?:\s+at\s+([\d.]+\s*[KMGT]?i?B)/s)?(?:\s+ETA\s+([\d:]+))?`)
+	downloadDestRe   = regexp.MustCompile(`^\[download\] Destination: (.+)$`)
+	playlistItemRe   = regexp.MustCompile(`^\[download\] Downloading (?:item|video) (\d+) of (\d+)`)
+	alreadyDoneRe    = regexp.MustCompile(`^\[download\] (.+) has already been downloaded`)
+	postprocessorRe  = regexp.MustCompile(`^\[(\w+)\] (.*)$`)
+	destinationRe    = regexp.MustCompile(`Destination: (.+)$`)
+	notConvertingRe  = regexp.MustCompile(`^Not converting audio (.+); file is already in target format`)
+	quotedPathRe     = regexp.MustCompile(`"(.+)"`)
+	sizeRe           = regexp.MustCompile(`^([\d.]+)\s*([KMGT]?)(i?)B$`)
+	postprocessPhase = map[string]Phase{
+		"ExtractAudio":   PhaseExtract,
+		"ffmpeg":         PhaseConvert,
+		"VideoConvertor": PhaseConvert,
+		"VideoRemuxer":   PhaseConvert,
+		"FixupM4a":       PhaseConvert,
+		"FixupM3u8":      PhaseConvert,
+		"FixupStretched": PhaseConvert,
+		"FixupDuration":  PhaseConvert,
+		"FixupTimestamp": PhaseConvert,
+		"Merger":         PhaseConvert,
+		"Metadata":       PhaseTag,
+		"EmbedThumbnail": PhaseTag,
+	}
+)
+
+// ParseProgressLine 解析一行 yt-dlp 輸出，無法識別的行返回 false
+func ParseProgressLine(line string) (ProgressEvent, bool) {
+	line = strings.TrimSpace(line)
+	event := ProgressEvent{Line: line}
+
+	if m := downloadProgressRe.FindStringSubmatch(line); m != nil {
+		event.Phase = PhaseDownload
+		event.Percent, _ = strconv.ParseFloat(m[1], 64)
+		event.TotalBytes = parseSize(m[2])
+		event.DownloadedBytes = int64(float64(event.TotalBytes) * event.Percent / 100)
+		if m[3] != "" {
+			event.Speed = float64(parseSize(m[3]))
+		}
+		if m[4] != "" {
+			event.ETA = parseClock(m[4])
+		}
+		return event, true
+	}
+	if m := playlistItemRe.FindStringSubmatch(line); m != nil {
+		event.Phase = PhaseDownload
+		event.Item, _ = strconv.Atoi(m[1])
+		event.ItemCount, _ = strconv.Atoi(m[2])
+		return event, true
+	}
+	if m := downloadDestRe.FindStringSubmatch(line); m != nil {
+		event.Phase = PhaseDownload
+		event.FilePath = m[1]
+		return event, true
+	}
+	if m := alreadyDoneRe.FindStringSubmatch(line); m != nil {
+		event.Phase = PhaseDownload
+		event.Percent = 100
+		event.FilePath = m[1]
+		return event, true
+	}
+
+	m := postprocessorRe.FindStringSubmatch(line)
+	if m == nil {
+		return event, false
+	}
+	phase, ok := postprocessPhase[m[1]]
+	if !ok {
+		return event, false
+	}
+	event.Phase = phase
+	message := m[2]
+
+	switch {
+	case destinationRe.MatchString(message):
+		event.FilePath = destinationRe.FindStringSubmatch(message)[1]
+		event.Final = phase == PhaseExtract
+	case notConvertingRe.MatchString(message):
+		event.FilePath = notConvertingRe.FindStringSubmatch(message)[1]
+		event.Final = true
+	case quotedPathRe.MatchString(message):
+		event.FilePath = quotedPathRe.FindStringSubmatch(message)[1]
+	}
+	return event, true
+}
+
+// parseSize 解析 "3.45MiB"、"512KB" 之類的大小
+func parseSize(s string) int64 {
+	m := sizeRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	base := 1000.0
+	if m[3] == "i" {
+		base = 1024
+	}
+	exponent := strings.Index("KMGT", m[2]) + 1
+	if m[2] == "" {
+		exponent = 0
+	}
+	for i := 0; i < exponent; i++ {
+		value *= base
+	}
+	return int64(value)
+}
+
+// parseClock 解析 "04"、"01:04"、"1:02:03" 格式的時間
+func parseClock(s string) time.Duration {
+	var total time.Duration
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + time.Duration(n)
+	}
+	return total * time.Second
+}
+
+// lineWriter 按行切分寫入的數據，\r 和 \n 都視為行結束
+type lineWriter struct {
+	onLine func(line string)
+	buf    []byte
+}
+
+// newLineWriter 創建按行回調的 writer
+func newLineWriter(onLine func(line string)) *lineWriter {
+	return &lineWriter{onLine: onLine}
+}
+
+// Write 實現 io.Writer 接口
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if line != "" {
+			w.onLine(line)
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 輸出緩衝中最後不完整的一行
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
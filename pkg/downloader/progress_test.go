@@ -0,0 +1,174 @@
+package downloader
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		ok    bool
+		check func(t *testing.T, e ProgressEvent)
+	}{
+		{
+			name: "download progress",
+			line: "[download]  45.3% of ~  3.45MiB at  512.00KiB/s ETA 01:04 (frag 3/10)",
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.Phase != PhaseDownload {
+					t.Errorf("Expected phase download, got %s", e.Phase)
+				}
+				if e.Percent != 45.3 {
+					t.Errorf("Expected percent 45.3, got %v", e.Percent)
+				}
+				if e.TotalBytes != 3617587 {
+					t.Errorf("Expected total bytes 3617587, got %d", e.TotalBytes)
+				}
+				if e.DownloadedBytes == 0 || e.DownloadedBytes >= e.TotalBytes {
+					t.Errorf("Unexpected downloaded bytes %d", e.DownloadedBytes)
+				}
+				if e.Speed != 512*1024 {
+					t.Errorf("Expected speed 524288, got %v", e.Speed)
+				}
+				if e.ETA != 64*time.Second {
+					t.Errorf("Expected ETA 64s, got %v", e.ETA)
+				}
+			},
+		},
+		{
+			name: "download finished",
+			line: "[download] 100% of    3.45MiB in 00:00:05 at 645.23KiB/s",
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.Percent != 100 || e.DownloadedBytes != e.TotalBytes {
+					t.Errorf("Expected complete download, got %+v", e)
+				}
+			},
+		},
+		{
+			name: "download destination",
+			line: "[download] Destination: output/Song.webm",
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.FilePath != "output/Song.webm" || e.Final {
+					t.Errorf("Unexpected event %+v", e)
+				}
+			},
+		},
+		{
+			name: "extract audio destination",
+			line: "[ExtractAudio] Destination: output/Song.mp3",
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.Phase != PhaseExtract || e.FilePath != "output/Song.mp3" || !e.Final {
+					t.Errorf("Unexpected event %+v", e)
+				}
+			},
+		},
+		{
+			name: "already in target format",
+			line: "[ExtractAudio] Not converting audio output/Song.mp3; file is already in target format mp3",
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.FilePath != "output/Song.mp3" || !e.Final {
+					t.Errorf("Unexpected event %+v", e)
+				}
+			},
+		},
+		{
+			name: "metadata",
+			line: `[Metadata] Adding metadata to "output/Song.mp3"`,
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.Phase != PhaseTag || e.FilePath != "output/Song.mp3" {
+					t.Errorf("Unexpected event %+v", e)
+				}
+			},
+		},
+		{
+			name: "ffmpeg convert",
+			line: `[FixupM4a] Correcting container of "output/Song.m4a"`,
+			ok:   true,
+			check: func(t *testing.T, e ProgressEvent) {
+				if e.Phase != PhaseConvert {
+					t.Errorf("Expected phase convert, got %s", e.Phase)
+				}
+			},
+		},
+		{name: "info line", line: "[youtube] Extracting URL: https://youtu.be/x", ok: false},
+		{name: "plain text", line: "Deleting original file output/Song.webm", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := ParseProgressLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if tt.check != nil {
+				tt.check(t, event)
+			}
+		})
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := newLineWriter(func(line string) { lines = append(lines, line) })
+
+	_, _ = w.Write([]byte("[download]  1.0% of 1MiB\r[download]  2.0"))
+	_, _ = w.Write([]byte("% of 1MiB\nlast"))
+	w.Flush()
+
+	expected := []string{"[download]  1.0% of 1MiB", "[download]  2.0% of 1MiB", "last"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected lines %v, got %v", expected, lines)
+	}
+}
+
+func TestDownloadProgressHandler(t *testing.T) {
+	cfg := config.NewConfig().WithOutputDir(t.TempDir())
+	mock := &MockCommandExecutor{
+		executeFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			_, _ = io.WriteString(stdout, "[youtube] abc: Downloading webpage\n")
+			_, _ = io.WriteString(stdout, "[download]  50.0% of 2.00MiB at 1.00MiB/s ETA 00:01\n")
+			_, _ = io.WriteString(stdout, "[ExtractAudio] Destination: out/Song.mp3\n")
+			return nil
+		},
+	}
+
+	downloader := NewYtDlpDownloader(cfg, mock)
+	var out bytes.Buffer
+	downloader.SetOutput(&out, io.Discard)
+
+	ch := make(chan ProgressEvent, 10)
+	downloader.SetProgressHandler(ChannelHandler(ch))
+
+	if _, err := downloader.Download("https://www.youtube.com/watch?v=abc"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	close(ch)
+
+	var events []ProgressEvent
+	for e := range ch {
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d: %+v", len(events), events)
+	}
+	if events[1].FilePath != "out/Song.mp3" {
+		t.Errorf("Expected final path out/Song.mp3, got %s", events[1].FilePath)
+	}
+
+	// 原始輸出仍然被轉發
+	if !strings.Contains(out.String(), "Downloading webpage") {
+		t.Errorf("Expected raw output to be forwarded, got: %s", out.String())
+	}
+}
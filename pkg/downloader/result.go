@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/sponsorblock"
+)
+
+// VideoInfo yt-dlp info JSON 中我們關心的字段
+type VideoInfo struct {
+	ID            string  `json:"id"`
+	ExtractorKey  string  `json:"extractor_key"`
+	Title         string  `json:"title"`
+	Duration      float64 `json:"duration"`
+	FilePath      string  `json:"filepath"`
+	PlaylistTitle string  `json:"playlist_title"`
+	PlaylistIndex int     `json:"playlist_index"`
+
+	Fields  map[string]interface{} `json:"-"` // yt-dlp 輸出的所有字段，用於展開多格式輸出的模板
+	Outputs []OutputFile           `json:"-"` // 多格式輸出時轉碼後的文件，代替 FilePath
+}
+
+// infoFields 通過 --print-to-file 讓 yt-dlp 輸出的字段
+var infoFields = []string{
+	"id", "extractor_key", "title", "duration", "filepath", "playlist_title", "playlist_index",
+	// 寫入標籤使用的字段
+	"webpage_url", "uploader", "channel", "artist", "album", "album_artist", "track",
+	"genre", "upload_date", "release_year", "n_entries",
+	// 嵌入封面和按章節拆分使用的字段
+	"thumbnail", "chapters",
+	// 截取片段的時間範圍，用於展開 ClipSuffix
+	"section_start", "section_end",
+}
+
+// infoTemplate 返回 after_move 階段輸出 info JSON 的 yt-dlp 模板，包含多格式輸出模板可用的字段
+func infoTemplate() string {
+	fields := append([]string(nil), infoFields...)
+	for _, name := range config.OutputTemplateFields {
+		if !contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fmt.Sprintf("after_move:%%(.{%s})j", strings.Join(fields, ","))
+}
+
+// contains 判斷切片是否包含字符串
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Result 單個視頻的下載結果，播放列表的結果在 Entries 中列出每個條目
+type Result struct {
+	URL           string
+	VideoID       string
+	Title         string
+	Duration      time.Duration
+	Files         []string // 最終輸出文件的路徑
+	Size          int64    // 輸出文件的總字節數
+	Info          *VideoInfo
+	PlaylistIndex int                    // 在播放列表中的序號，單個視頻和找不到序號的失敗條目為 0
+	Err           error                  // 播放列表條目的失敗原因
+	Entries       []*Result              // 播放列表的各個條目
+	Skipped       bool                   // 已在下載存檔中，沒有重新下載
+	Outputs       []OutputFile           // 多格式輸出時每種格式的文件，與 Files 對應
+	Warnings      []string               // 後處理中不影響輸出文件的問題，例如封面下載失敗
+	Loudness      []Loudness             // 響度處理前後的測量值，每個處理過的文件一項
+	Removed       []sponsorblock.Segment // 從音頻中移除的 SponsorBlock 片段，時間相對於原視頻
+}
+
+// LoudnessStats ffmpeg loudnorm 測量的響度
+type LoudnessStats struct {
+	Integrated float64 // 綜合響度（LUFS）
+	TruePeak   float64 // 真峰值（dBTP）
+	Range      float64 // 響度範圍（LU）
+}
+
+// Loudness 一個文件的響度處理結果
+type Loudness struct {
+	Path   string
+	Before LoudnessStats
+	After  LoudnessStats // ReplayGain 模式不改變音頻，與 Before 相同
+	Gain   float64       // ReplayGain 模式寫入的音軌增益（dB）
+}
+
+// newResult 根據 yt-dlp 輸出的 info 創建結果
+func newResult(url string, info *VideoInfo) *Result {
+	result := &Result{
+		URL:           url,
+		VideoID:       info.ID,
+		Title:         info.Title,
+		Duration:      time.Duration(info.Duration * float64(time.Second)),
+		Info:          info,
+		PlaylistIndex: info.PlaylistIndex,
+	}
+	result.addInfo(info)
+	return result
+}
+
+// addInfo 添加 info 的輸出文件
+func (r *Result) addInfo(info *VideoInfo) {
+	if len(info.Outputs) > 0 {
+		for _, output := range info.Outputs {
+			r.AddFile(output.Path)
+		}
+		r.Outputs = append(r.Outputs, info.Outputs...)
+	} else if info.FilePath != "" {
+		r.AddFile(info.FilePath)
+	}
+}
+
+// outputPaths 返回視頻的最終輸出文件
+func (info *VideoInfo) outputPaths() []string {
+	if len(info.Outputs) > 0 {
+		paths := make([]string, len(info.Outputs))
+		for i, output := range info.Outputs {
+			paths[i] = output.Path
+		}
+		return paths
+	}
+	if info.FilePath != "" {
+		return []string{info.FilePath}
+	}
+	return nil
+}
+
+// SetFiles 替換輸出文件並重新計算大小，後處理改寫或拆分文件後調用
+func (r *Result) SetFiles(paths []string) {
+	r.Files = nil
+	r.Size = 0
+	for _, path := range paths {
+		r.AddFile(path)
+	}
+}
+
+// AddFile 添加輸出文件並累加大小
+func (r *Result) AddFile(path string) {
+	r.Files = append(r.Files, path)
+	if stat, err := os.Stat(path); err == nil {
+		r.Size += stat.Size()
+	}
+}
+
+// Warn 記錄一條警告
+func (r *Result) Warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// AllWarnings 返回結果及播放列表各條目的警告
+func (r *Result) AllWarnings() []string {
+	warnings := append([]string(nil), r.Warnings...)
+	for _, entry := range r.Entries {
+		warnings = append(warnings, entry.AllWarnings()...)
+	}
+	return warnings
+}
+
+// ParseVideoInfo 解析 yt-dlp 輸出的 info JSON
+func ParseVideoInfo(data []byte) (*VideoInfo, error) {
+	info := &VideoInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("解析視頻信息失敗: %v", err)
+	}
+	if err := json.Unmarshal(data, &info.Fields); err != nil {
+		return nil, fmt.Errorf("解析視頻信息失敗: %v", err)
+	}
+	return info, nil
+}
+
+// readInfoFile 讀取 --print-to-file 寫出的 info，每行一個 JSON 對象
+func readInfoFile(path string) ([]*VideoInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var infos []*VideoInfo
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		info, err := ParseVideoInfo([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, scanner.Err()
+}
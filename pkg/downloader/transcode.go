@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// OutputFile 多格式輸出中一種格式的文件
+type OutputFile struct {
+	Preset string
+	Path   string
+}
+
+// %(title)s、%(playlist_index)03d、%(section_start>%H.%M.%S)s 等 yt-dlp 模板字段
+var outputFieldRe = regexp.MustCompile(`%\((\w+)(?:>([^)]*))?\)([-#0 +]*\d*(?:\.\d+)?)([sdf])`)
+
+// sourceArgs 多格式輸出時只提取源音頻，不重新編碼
+func (d *YtDlpDownloader) sourceArgs() []string {
+	return []string{"--extract-audio", "--audio-format", "best"}
+}
+
+// sourceTemplate 源音頻的輸出模板，文件名只用於臨時存放
+func sourceTemplate(dir string) string {
+	return filepath.Join(dir, "%(id)s.%(ext)s")
+}
+
+// transcodeAll 轉碼所有下載完成的視頻
+// 播放列表中轉碼失敗的條目移到 failures，單個視頻返回錯誤
+func (d *YtDlpDownloader) transcodeAll(ctx context.Context, url string, infos []*VideoInfo, failures []*Result) ([]*VideoInfo, []*Result, error) {
+	var done []*VideoInfo
+	var errs []error
+	for _, info := range infos {
+		if info.FilePath == "" {
+			continue
+		}
+		outputs, err := d.transcode(ctx, info)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			errs = append(errs, err)
+			failures = append(failures, &Result{URL: url, VideoID: info.ID, Title: info.Title, PlaylistIndex: info.PlaylistIndex, Info: info, Err: err})
+			continue
+		}
+		info.Outputs = outputs
+		done = append(done, info)
+	}
+	return done, failures, errors.Join(errs...)
+}
+
+// transcode 把源音頻並行轉碼為所有配置的格式，按配置順序返回輸出文件
+func (d *YtDlpDownloader) transcode(ctx context.Context, info *VideoInfo) ([]OutputFile, error) {
+	outputs := make([]OutputFile, len(d.config.Outputs))
+	errs := make([]error, len(d.config.Outputs))
+
+	// 多個 ffmpeg 同時輸出，按行加鎖轉發；d.stdout、d.stderr 和進度回調都不要求並發安全，只在持有 mu 時使用
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, o := range d.config.Outputs {
+		preset, _ := config.LookupPreset(o.Preset)
+		path := expandTemplate(d.config.OutputPath(o), info.Fields, preset.Extension())
+		outputs[i] = OutputFile{Preset: preset.Name, Path: path}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stdout := newLineWriter(func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Fprintf(d.stdout, "[%s] %s\n", preset.Name, line)
+			})
+			stderr := newLineWriter(func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Fprintf(d.stderr, "[%s] %s\n", preset.Name, line)
+			})
+			errs[i] = d.encode(ctx, info.FilePath, path, preset, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			if errs[i] == nil && d.progress != nil {
+				mu.Lock()
+				d.progress(ProgressEvent{Phase: PhaseConvert, Percent: 100, FilePath: path, Final: true})
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// encode 調用 ffmpeg 轉碼一種格式，失敗時刪除不完整的輸出
+func (d *YtDlpDownloader) encode(ctx context.Context, src, dst string, preset config.Preset, stdout, stderr *lineWriter) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("創建 %s 輸出目錄失敗: %v", preset.Name, err)
+	}
+
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", src, "-vn", "-map_metadata", "0"}
+	args = append(args, preset.FFmpegArgs()...)
+	args = append(args, dst)
+
+	if err := d.executor.ExecuteContext(ctx, d.config.FFmpeg(), args, stdout, stderr); err != nil {
+		os.Remove(dst)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("轉碼為 %s 失敗: %v", preset.Name, err)
+	}
+	return nil
+}
+
+// expandTemplate 用 yt-dlp 輸出的字段展開模板，缺少的字段與 yt-dlp 一樣替換為 NA
+func expandTemplate(template string, fields map[string]interface{}, ext string) string {
+	return outputFieldRe.ReplaceAllStringFunc(template, func(m string) string {
+		parts := outputFieldRe.FindStringSubmatch(m)
+		name, timeFormat, flags, verb := parts[1], parts[2], parts[3], parts[4]
+
+		var value interface{} = ext
+		if name != "ext" {
+			value = fields[name]
+		}
+		switch v := value.(type) {
+		case nil:
+			return "NA"
+		case float64:
+			if timeFormat != "" {
+				return sanitizeField(fmt.Sprintf("%"+flags+"s", formatTime(v, timeFormat)))
+			}
+			if verb == "d" {
+				return fmt.Sprintf("%"+flags+"d", int64(math.Round(v)))
+			}
+			if verb == "s" && v == math.Trunc(v) {
+				return fmt.Sprintf("%"+flags+"d", int64(v))
+			}
+			return fmt.Sprintf("%"+flags+verb, v)
+		default:
+			return sanitizeField(fmt.Sprintf("%"+flags+"s", v))
+		}
+	})
+}
+
+// formatTime 與 yt-dlp 一樣把數值當作 UTC 時間戳，按 strftime 格式化，支持 %H、%M、%S
+func formatTime(seconds float64, layout string) string {
+	t := time.Unix(int64(seconds), 0).UTC()
+	return strings.NewReplacer("%H", t.Format("15"), "%M", t.Format("04"), "%S", t.Format("05"), "%%", "%").Replace(layout)
+}
+
+// sanitizeField 替換字段值中的路徑分隔符，避免標題等字段產生額外的目錄
+func sanitizeField(value string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "\x00", "").Replace(value)
+}
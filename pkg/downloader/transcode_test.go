@@ -0,0 +1,206 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	fields := map[string]interface{}{
+		"id":             "abc123",
+		"title":          "AC/DC - Song",
+		"playlist_index": float64(7),
+		"playlist_title": nil,
+		"section_start":  float64(750),
+		"section_end":    float64(3723.5),
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"%(title)s.%(ext)s", "AC_DC - Song.flac"},
+		{"%(playlist_index)03d - %(id)s.%(ext)s", "007 - abc123.flac"},
+		{"%(playlist_index)s", "7"},
+		{"%(playlist_title)s/%(uploader)s", "NA/NA"},
+		{filepath.Join("dir", "%(id)s"), filepath.Join("dir", "abc123")},
+		{"%(id)s" + config.ClipSuffix, "abc123 [00.12.30-01.02.03]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if got := expandTemplate(tt.template, fields, "flac"); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// fakeTranscoder 模擬 yt-dlp 下載源音頻和 ffmpeg 轉碼，記錄 ffmpeg 的參數
+type fakeTranscoder struct {
+	t       *testing.T
+	info    string // yt-dlp 輸出的 info JSON，{src} 替換為源文件路徑
+	failExt string // 輸出此擴展名時 ffmpeg 失敗
+
+	mu     sync.Mutex
+	ffmpeg [][]string
+	source string
+}
+
+func (f *fakeTranscoder) execute(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	last := args[len(args)-1]
+	if name == "ffmpeg" {
+		f.mu.Lock()
+		f.ffmpeg = append(f.ffmpeg, args)
+		f.mu.Unlock()
+		if f.failExt != "" && strings.HasSuffix(last, f.failExt) {
+			io.WriteString(stderr, "Unknown encoder\n")
+			return errors.New("exit status 1")
+		}
+		io.WriteString(stdout, "encoded "+filepath.Base(last)+"\n")
+		return os.WriteFile(last, []byte("encoded"), 0644)
+	}
+
+	// yt-dlp: -o 參數指向臨時目錄
+	var output string
+	for i, arg := range args {
+		if arg == "-o" {
+			output = args[i+1]
+		}
+	}
+	f.source = filepath.Join(filepath.Dir(output), "abc123.webm")
+	if err := os.WriteFile(f.source, []byte("source"), 0644); err != nil {
+		f.t.Fatalf("Failed to write source: %v", err)
+	}
+	writeInfo(f.t, args, strings.ReplaceAll(f.info, "{src}", f.source))
+	return nil
+}
+
+func TestDownloadMultipleOutputs(t *testing.T) {
+	info := `{"id": "abc123", "extractor_key": "Youtube", "title": "Song", "uploader": "Band", "filepath": "{src}"}`
+
+	t.Run("transcodes once per format", func(t *testing.T) {
+		tempDir := t.TempDir()
+		archiveDir := filepath.Join(t.TempDir(), "archive")
+		cfg := config.NewConfig().WithOutputDir(tempDir)
+		cfg.Outputs = []config.Output{
+			{Preset: "mp3-320"},
+			{Preset: "flac", Path: archiveDir},
+			{Preset: "opus-96", Path: "%(uploader)s/%(title)s.%(ext)s"},
+		}
+
+		fake := &fakeTranscoder{t: t, info: info}
+		mock := &MockCommandExecutor{executeContextFunc: fake.execute}
+		result, err := NewYtDlpDownloader(cfg, mock).Download("https://youtu.be/abc123")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		expected := []OutputFile{
+			{"mp3-320", filepath.Join(tempDir, "Song.mp3")},
+			{"flac", filepath.Join(archiveDir, "Song.flac")},
+			{"opus-96", filepath.Join(tempDir, "Band", "Song.opus")},
+		}
+		if len(result.Outputs) != len(expected) {
+			t.Fatalf("Expected %d outputs, got %v", len(expected), result.Outputs)
+		}
+		for i, output := range expected {
+			if result.Outputs[i] != output {
+				t.Errorf("Expected output %v, got %v", output, result.Outputs[i])
+			}
+			if _, err := os.Stat(output.Path); err != nil {
+				t.Errorf("Expected %s to exist: %v", output.Path, err)
+			}
+		}
+		if len(result.Files) != 3 || result.Size != 3*int64(len("encoded")) {
+			t.Errorf("Expected 3 files, got %v (size %d)", result.Files, result.Size)
+		}
+
+		// 每種格式一個 ffmpeg，使用預設的編碼參數
+		var ffmpegArgs []string
+		for _, args := range fake.ffmpeg {
+			ffmpegArgs = append(ffmpegArgs, strings.Join(args, " "))
+		}
+		sort.Strings(ffmpegArgs)
+		for i, want := range []string{"-c:a flac " + expected[1].Path, "-c:a libmp3lame -b:a 320k " + expected[0].Path, "-c:a libopus -b:a 96k " + expected[2].Path} {
+			if i >= len(ffmpegArgs) || !strings.HasSuffix(ffmpegArgs[i], want) || !strings.Contains(ffmpegArgs[i], "-i "+fake.source) {
+				t.Errorf("Expected ffmpeg args ending with %q, got %v", want, ffmpegArgs)
+			}
+		}
+
+		// 源音頻所在的臨時目錄已刪除
+		if _, err := os.Stat(filepath.Dir(fake.source)); !os.IsNotExist(err) {
+			t.Errorf("Expected source dir to be removed, got: %v", err)
+		}
+	})
+
+	t.Run("output and progress are not written concurrently", func(t *testing.T) {
+		// bytes.Buffer 和進度回調都不是並發安全的，go test -race 檢查轉碼時是否加鎖
+		cfg := config.NewConfig().WithOutputDir(t.TempDir())
+		cfg.Outputs = []config.Output{{Preset: "mp3-320"}, {Preset: "flac"}, {Preset: "opus-96"}}
+		fake := &fakeTranscoder{t: t, info: info}
+		dl := NewYtDlpDownloader(cfg, &MockCommandExecutor{executeContextFunc: fake.execute})
+		var stdout, stderr bytes.Buffer
+		dl.SetOutput(&stdout, &stderr)
+		var events []ProgressEvent
+		dl.SetProgressHandler(func(event ProgressEvent) {
+			events = append(events, event)
+		})
+
+		if _, err := dl.Download("https://youtu.be/abc123"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		for _, line := range []string{"[mp3-320] encoded Song.mp3", "[flac] encoded Song.flac", "[opus-96] encoded Song.opus"} {
+			if !strings.Contains(stdout.String(), line+"\n") {
+				t.Errorf("Expected %q in output, got %q", line, stdout.String())
+			}
+		}
+		if len(events) != 3 {
+			t.Errorf("Expected 3 convert events, got %v", events)
+		}
+	})
+
+	t.Run("downloads source without re-encoding", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir())
+		cfg.Outputs = []config.Output{{Preset: "flac"}}
+		fake := &fakeTranscoder{t: t, info: info}
+		mock := &MockCommandExecutor{executeContextFunc: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			if name == "yt-dlp" {
+				argsStr := strings.Join(args, " ")
+				if !strings.Contains(argsStr, "--audio-format best") || strings.Contains(argsStr, "--audio-quality") {
+					t.Errorf("Expected source audio args, got: %s", argsStr)
+				}
+			}
+			return fake.execute(ctx, name, args, stdout, stderr)
+		}}
+		if _, err := NewYtDlpDownloader(cfg, mock).Download("https://youtu.be/abc123"); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("failed format", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(t.TempDir())
+		cfg.Outputs = []config.Output{{Preset: "mp3-320"}, {Preset: "flac"}}
+		fake := &fakeTranscoder{t: t, info: info, failExt: ".flac"}
+		dl := NewYtDlpDownloader(cfg, &MockCommandExecutor{executeContextFunc: fake.execute})
+		dl.SetOutput(io.Discard, io.Discard)
+
+		_, err := dl.Download("https://youtu.be/abc123")
+		if err == nil || !strings.Contains(err.Error(), "轉碼為 flac 失敗") {
+			t.Errorf("Expected flac transcode error, got: %v", err)
+		}
+		if _, statErr := os.Stat(filepath.Join(cfg.OutputDir, "Song.flac")); !os.IsNotExist(statErr) {
+			t.Error("Expected incomplete flac output to be removed")
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// youtubeIDRe YouTube 視頻 ID 固定為 11 個字符
+var youtubeIDRe = regexp.MustCompile(`^[\w-]{11}$`)
+
+// ExtractVideoID 從 YouTube URL 中提取視頻 ID，支持 watch、youtu.be、shorts、embed、live 等形式
+func ExtractVideoID(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	var id string
+
+	switch host {
+	case "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		if u.Path == "/watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) == 2 {
+			switch parts[0] {
+			case "shorts", "embed", "live", "v":
+				id = parts[1]
+			}
+		}
+	}
+
+	if !youtubeIDRe.MatchString(id) {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,33 @@
+package downloader
+
+import "testing"
+
+func TestExtractVideoID(t *testing.T) {
+	tests := []struct {
+		url string
+		id  string
+		ok  bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://youtube.com/watch?v=dQw4w9WgXcQ&list=PL123&t=42", "dQw4w9WgXcQ", true},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc", "dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ", "dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/playlist?list=PL123", "", false},
+		{"https://www.youtube.com/watch?v=short", "", false},
+		{"https://vimeo.com/123456", "", false},
+		{"not a url", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			id, ok := ExtractVideoID(tt.url)
+			if id != tt.id || ok != tt.ok {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.id, tt.ok, id, ok)
+			}
+		})
+	}
+}
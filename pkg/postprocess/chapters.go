@@ -0,0 +1,225 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// Chapter 視頻的一個章節，時間以秒為單位
+type Chapter struct {
+	Title string
+	Start float64
+	End   float64
+}
+
+// Duration 返回章節時長
+func (c Chapter) Duration() float64 {
+	return c.End - c.Start
+}
+
+// Chapters 從 info 的 chapters 字段讀取章節，沒有結束時間的最後一章以視頻時長結束
+func Chapters(info *downloader.VideoInfo) []Chapter {
+	list, _ := info.Fields["chapters"].([]interface{})
+	chapters := make([]Chapter, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		start, _ := m["start_time"].(float64)
+		end, _ := m["end_time"].(float64)
+		if end <= start {
+			end = info.Duration
+		}
+		title := fieldString(m["title"])
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		chapters = append(chapters, Chapter{Title: title, Start: start, End: end})
+	}
+	return chapters
+}
+
+// ChapterSplitter 按章節把音頻拆分為多個文件，放在以原文件名命名的目錄中
+// 每個文件按 "序號 - 章節標題" 命名，繼承原文件的標籤和封面，
+// 並把標題、專輯和音軌號改為章節標題、視頻標題和章節序號
+type ChapterSplitter struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+}
+
+// NewChapterSplitter 創建按章節拆分步驟
+func NewChapterSplitter(cfg *config.Config, executor downloader.CommandExecutor) *ChapterSplitter {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	return &ChapterSplitter{config: cfg, executor: executor}
+}
+
+// Name 實現 Step 接口
+func (s *ChapterSplitter) Name() string {
+	return "按章節拆分"
+}
+
+// Process 拆分結果中的每個文件並刪除原文件，視頻沒有章節時保留完整文件並記錄警告
+func (s *ChapterSplitter) Process(ctx context.Context, result *downloader.Result) error {
+	if result.Info == nil {
+		return nil
+	}
+	chapters := Chapters(result.Info)
+	if len(chapters) == 0 {
+		result.Warn("%s: 沒有章節，保留完整文件", result.Title)
+		return nil
+	}
+
+	var files []string
+	var outputs []downloader.OutputFile
+	for i, path := range result.Files {
+		tracks, err := s.split(ctx, path, result.Title, chapters)
+		if err != nil {
+			return err
+		}
+		if err := s.writePlaylist(path, result.Title, chapters, tracks, len(result.Files) > 1); err != nil {
+			return err
+		}
+		// 下載存檔在所有步驟完成後記錄 result.Files，即章節文件，不會指向刪除的原文件
+		os.Remove(path)
+
+		files = append(files, tracks...)
+		if i < len(result.Outputs) {
+			for _, track := range tracks {
+				outputs = append(outputs, downloader.OutputFile{Preset: result.Outputs[i].Preset, Path: track})
+			}
+		}
+	}
+	result.SetFiles(files)
+	if len(result.Outputs) > 0 {
+		result.Outputs = outputs
+	}
+	return nil
+}
+
+// split 用 ffmpeg 複製每個章節的音頻，任一章節失敗時刪除已寫出的文件
+func (s *ChapterSplitter) split(ctx context.Context, path, album string, chapters []Chapter) ([]string, error) {
+	ext := filepath.Ext(path)
+	dir := strings.TrimSuffix(path, ext)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("創建章節目錄失敗: %v", err)
+	}
+
+	width := len(strconv.Itoa(len(chapters)))
+	if width < 2 {
+		width = 2
+	}
+	var tracks []string
+	for i, chapter := range chapters {
+		track := filepath.Join(dir, fmt.Sprintf("%0*d - %s%s", width, i+1, sanitizeName(chapter.Title), ext))
+		if err := s.extract(ctx, path, track, chapter, album, fmt.Sprintf("%d/%d", i+1, len(chapters))); err != nil {
+			for _, t := range tracks {
+				os.Remove(t)
+			}
+			os.Remove(dir)
+			return nil, fmt.Errorf("第 %d 章 (%s): %v", i+1, chapter.Title, err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// extract 不重新編碼地複製一個章節，保留原文件的標籤和封面，不保留章節信息
+func (s *ChapterSplitter) extract(ctx context.Context, src, dst string, chapter Chapter, album, track string) error {
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y",
+		"-ss", formatSeconds(chapter.Start)}
+	if chapter.End > chapter.Start {
+		args = append(args, "-t", formatSeconds(chapter.Duration()))
+	}
+	args = append(args, "-i", src, "-map", "0", "-c", "copy", "-map_metadata", "0", "-map_chapters", "-1",
+		"-metadata", "title="+chapter.Title, "-metadata", "album="+album, "-metadata", "track="+track)
+	if strings.EqualFold(filepath.Ext(dst), ".mp3") {
+		args = append(args, "-id3v2_version", "3")
+	}
+	args = append(args, dst)
+
+	var stderr bytes.Buffer
+	if err := s.executor.ExecuteContext(ctx, s.config.FFmpeg(), args, io.Discard, &stderr); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// writePlaylist 在章節目錄中寫入 m3u 播放列表或 cue 表，文件名與原文件相同
+// 多格式輸出時文件名帶上音頻擴展名，避免不同格式的播放列表互相覆蓋
+func (s *ChapterSplitter) writePlaylist(path, title string, chapters []Chapter, tracks []string, multiple bool) error {
+	format := s.config.ChapterPlaylist
+	if format == "" {
+		return nil
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	name := filepath.Base(base)
+	if multiple {
+		name += ext
+	}
+	playlist := filepath.Join(base, name+"."+format)
+
+	var buf bytes.Buffer
+	switch format {
+	case "m3u":
+		writeM3U(&buf, chapters, tracks)
+	case "cue":
+		writeCue(&buf, title, chapters, tracks)
+	}
+	if err := os.WriteFile(playlist, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("寫入播放列表失敗: %v", err)
+	}
+	return nil
+}
+
+// writeM3U 寫入擴展 m3u，路徑相對於播放列表所在目錄
+func writeM3U(w io.Writer, chapters []Chapter, tracks []string) {
+	fmt.Fprintln(w, "#EXTM3U")
+	for i, chapter := range chapters {
+		fmt.Fprintf(w, "#EXTINF:%d,%s\n", int(chapter.Duration()+0.5), chapter.Title)
+		fmt.Fprintln(w, filepath.Base(tracks[i]))
+	}
+}
+
+// writeCue 寫入每個音軌一個 FILE 的 cue 表
+func writeCue(w io.Writer, title string, chapters []Chapter, tracks []string) {
+	fmt.Fprintf(w, "TITLE %s\n", cueQuote(title))
+	for i, chapter := range chapters {
+		fileType := "WAVE"
+		if strings.EqualFold(filepath.Ext(tracks[i]), ".mp3") {
+			fileType = "MP3"
+		}
+		fmt.Fprintf(w, "FILE %s %s\n", cueQuote(filepath.Base(tracks[i])), fileType)
+		fmt.Fprintf(w, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(w, "    TITLE %s\n", cueQuote(chapter.Title))
+		fmt.Fprintln(w, "    INDEX 01 00:00:00")
+	}
+}
+
+// cueQuote cue 表的字符串不能包含雙引號
+func cueQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
+// formatSeconds 格式化 ffmpeg 的時間參數
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
+
+// sanitizeName 替換文件名中的路徑分隔符
+func sanitizeName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "\x00", "").Replace(name)
+}
@@ -0,0 +1,216 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/archive"
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+func TestChapters(t *testing.T) {
+	chapters := Chapters(loadInfo(t, "chapters.json"))
+	expected := []Chapter{
+		{Title: "Intro", Start: 0, End: 185.5},
+		{Title: "Rain / Night", Start: 185.5, End: 400},
+		{Title: "Chapter 3", Start: 400, End: 600},
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("Expected %d chapters, got %v", len(expected), chapters)
+	}
+	for i := range expected {
+		if chapters[i] != expected[i] {
+			t.Errorf("Expected chapter %d to be %+v, got %+v", i, expected[i], chapters[i])
+		}
+	}
+
+	if chapters := Chapters(loadInfo(t, "video.json")); len(chapters) != 0 {
+		t.Errorf("Expected no chapters, got %v", chapters)
+	}
+}
+
+// splitFixture 創建待拆分的音頻文件，ffmpeg 的 mock 寫出目標文件並記錄參數
+func splitFixture(t *testing.T, name string) (path string, calls *[]string, executor *mocks.CommandExecutor) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	calls = &[]string{}
+	executor = &mocks.CommandExecutor{
+		ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			*calls = append(*calls, strings.Join(args, " "))
+			return os.WriteFile(args[len(args)-1], []byte("chapter"), 0644)
+		},
+	}
+	return path, calls, executor
+}
+
+func TestChapterSplitterProcess(t *testing.T) {
+	path, calls, executor := splitFixture(t, "Lofi Mix 2024.mp3")
+	cfg := config.NewConfig()
+	cfg.ChapterPlaylist = "m3u"
+	result := &downloader.Result{URL: "https://youtu.be/mix", Title: "Lofi Mix 2024", Files: []string{path}, Info: loadInfo(t, "chapters.json")}
+
+	if err := NewChapterSplitter(cfg, executor).Process(context.Background(), result); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	dir := strings.TrimSuffix(path, ".mp3")
+	expectedFiles := []string{
+		filepath.Join(dir, "01 - Intro.mp3"),
+		filepath.Join(dir, "02 - Rain _ Night.mp3"),
+		filepath.Join(dir, "03 - Chapter 3.mp3"),
+	}
+	if strings.Join(result.Files, "\n") != strings.Join(expectedFiles, "\n") {
+		t.Errorf("Expected files %v, got %v", expectedFiles, result.Files)
+	}
+	if result.Size != int64(3*len("chapter")) {
+		t.Errorf("Expected size to be recalculated, got %d", result.Size)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected source file to be removed, got: %v", err)
+	}
+
+	expectedArgs := []string{
+		"-ss 0 -t 185.5 -i " + path,
+		"-map_chapters -1 -metadata title=Rain / Night -metadata album=Lofi Mix 2024 -metadata track=2/3 -id3v2_version 3",
+		"-ss 400 -t 200 -i",
+	}
+	for i, want := range expectedArgs {
+		if !strings.Contains((*calls)[i], want) {
+			t.Errorf("Expected call %d to contain %q, got: %s", i, want, (*calls)[i])
+		}
+	}
+
+	playlist, err := os.ReadFile(filepath.Join(dir, "Lofi Mix 2024.m3u"))
+	if err != nil {
+		t.Fatalf("Expected m3u playlist, got: %v", err)
+	}
+	expectedPlaylist := "#EXTM3U\n#EXTINF:186,Intro\n01 - Intro.mp3\n#EXTINF:215,Rain / Night\n02 - Rain _ Night.mp3\n#EXTINF:200,Chapter 3\n03 - Chapter 3.mp3\n"
+	if string(playlist) != expectedPlaylist {
+		t.Errorf("Expected playlist:\n%s\ngot:\n%s", expectedPlaylist, playlist)
+	}
+}
+
+func TestChapterSplitterCue(t *testing.T) {
+	path, _, executor := splitFixture(t, "Mix.flac")
+	cfg := config.NewConfig()
+	cfg.ChapterPlaylist = "cue"
+	info := loadInfo(t, "chapters.json")
+	result := &downloader.Result{Title: `Lofi "Mix"`, Files: []string{path}, Info: info,
+		Outputs: []downloader.OutputFile{{Preset: "flac", Path: path}}}
+
+	if err := NewChapterSplitter(cfg, executor).Process(context.Background(), result); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	cue, err := os.ReadFile(filepath.Join(strings.TrimSuffix(path, ".flac"), "Mix.cue"))
+	if err != nil {
+		t.Fatalf("Expected cue sheet, got: %v", err)
+	}
+	for _, want := range []string{"TITLE \"Lofi 'Mix'\"\n", "FILE \"02 - Rain _ Night.flac\" WAVE\n  TRACK 02 AUDIO\n    TITLE \"Rain / Night\"\n    INDEX 01 00:00:00\n"} {
+		if !strings.Contains(string(cue), want) {
+			t.Errorf("Expected cue sheet to contain %q, got:\n%s", want, cue)
+		}
+	}
+	if len(result.Outputs) != 3 || result.Outputs[2].Preset != "flac" || result.Outputs[2].Path != result.Files[2] {
+		t.Errorf("Expected outputs to follow chapter files, got %v", result.Outputs)
+	}
+}
+
+func TestChapterSplitterFailures(t *testing.T) {
+	t.Run("no chapters", func(t *testing.T) {
+		path, calls, executor := splitFixture(t, "Song.mp3")
+		result := &downloader.Result{Title: "Song", Files: []string{path}, Info: loadInfo(t, "video.json")}
+		if err := NewChapterSplitter(config.NewConfig(), executor).Process(context.Background(), result); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(*calls) != 0 || len(result.Warnings) != 1 || result.Files[0] != path {
+			t.Errorf("Expected file to be kept with a warning, got %v (warnings: %v)", result.Files, result.Warnings)
+		}
+	})
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		path, _, executor := splitFixture(t, "Mix.mp3")
+		executor.ExecuteFunc = func(name string, args []string, stdout, stderr io.Writer) error {
+			if strings.Contains(strings.Join(args, " "), "track=2/3") {
+				return errors.New("exit status 1")
+			}
+			return os.WriteFile(args[len(args)-1], []byte("chapter"), 0644)
+		}
+		result := &downloader.Result{Title: "Mix", Files: []string{path}, Info: loadInfo(t, "chapters.json")}
+		err := NewChapterSplitter(config.NewConfig(), executor).Process(context.Background(), result)
+		if err == nil || !strings.Contains(err.Error(), "第 2 章") {
+			t.Errorf("Expected chapter error, got: %v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected source file to be kept, got: %v", err)
+		}
+		if _, err := os.Stat(strings.TrimSuffix(path, ".mp3")); !os.IsNotExist(err) {
+			t.Errorf("Expected chapter directory to be removed, got: %v", err)
+		}
+	})
+}
+
+func TestChapterSplitterArchive(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.NewConfig().WithOutputDir(dir)
+	cfg.SplitChapters = true
+	source := filepath.Join(dir, "Lofi Mix 2024.mp3")
+	info := `{"id": "mixABCDEFGH", "extractor_key": "Youtube", "title": "Lofi Mix 2024", "filepath": "` + source + `", ` +
+		`"chapters": [{"start_time": 0, "end_time": 185.5, "title": "Intro"}, {"start_time": 185.5, "end_time": 600, "title": "Outro"}]}`
+	ytdlp := ytDlpMock(t, info)
+	executor := &mocks.CommandExecutor{ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+		if name == config.YtDlpCommand {
+			return ytdlp.ExecuteFunc(name, args, stdout, stderr)
+		}
+		return os.WriteFile(args[len(args)-1], []byte("chapter"), 0644)
+	}}
+	download := func() *downloader.Result {
+		arc, err := archive.Open(cfg.ArchiveFile())
+		if err != nil {
+			t.Fatalf("Failed to open archive: %v", err)
+		}
+		dl := downloader.NewYtDlpDownloader(cfg, executor)
+		dl.SetOutput(io.Discard, io.Discard)
+		dl.SetArchive(arc)
+		result, err := New(dl, NewChapterSplitter(cfg, executor)).Download("https://www.youtube.com/watch?v=mixABCDEFGH")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		return result
+	}
+
+	first := download()
+	expected := []string{
+		filepath.Join(dir, "Lofi Mix 2024", "01 - Intro.mp3"),
+		filepath.Join(dir, "Lofi Mix 2024", "02 - Outro.mp3"),
+	}
+	if strings.Join(first.Files, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected chapter files %v, got %v", expected, first.Files)
+	}
+
+	// 重新運行時跳過，報告的是章節文件而不是已刪除的原文件
+	second := download()
+	if !second.Skipped {
+		t.Fatal("Expected second run to be skipped")
+	}
+	if strings.Join(second.Files, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected archived chapter files %v, got %v", expected, second.Files)
+	}
+	for _, path := range second.Files {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected reported file to exist: %v", err)
+		}
+	}
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Errorf("Expected source file to be removed, got: %v", err)
+	}
+}
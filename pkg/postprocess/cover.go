@@ -0,0 +1,229 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// maxThumbnailSize 縮略圖的最大字節數
+const maxThumbnailSize = 20 << 20
+
+// coverFormats 支持嵌入封面的格式：mp3 寫入 APIC，m4a 寫入 covr，flac 寫入 PICTURE
+var coverFormats = []string{".mp3", ".m4a", ".flac"}
+
+// Fetcher 下載縮略圖
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// HTTPFetcher 通過 HTTP 下載縮略圖，Client 為 nil 時使用 http.DefaultClient
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+// Fetch 實現 Fetcher 接口
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxThumbnailSize {
+		return nil, fmt.Errorf("縮略圖超過 %d MB", maxThumbnailSize>>20)
+	}
+	return data, nil
+}
+
+// CoverArt 下載視頻縮略圖並嵌入為封面
+// 封面是可選的，下載或嵌入失敗只記錄警告，音頻文件保持不變
+type CoverArt struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+	fetcher  Fetcher
+}
+
+// NewCoverArt 創建嵌入封面步驟，fetcher 為 nil 時通過 HTTP 下載
+func NewCoverArt(cfg *config.Config, executor downloader.CommandExecutor, fetcher Fetcher) *CoverArt {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	if fetcher == nil {
+		fetcher = &HTTPFetcher{}
+	}
+	return &CoverArt{config: cfg, executor: executor, fetcher: fetcher}
+}
+
+// Name 實現 Step 接口
+func (c *CoverArt) Name() string {
+	return "嵌入封面"
+}
+
+// Process 下載縮略圖並嵌入到結果中支持封面的文件，只在 ctx 取消時返回錯誤
+func (c *CoverArt) Process(ctx context.Context, result *downloader.Result) error {
+	if result.Info == nil {
+		return nil
+	}
+	var files []string
+	for _, path := range result.Files {
+		if supportsCover(path) {
+			files = append(files, path)
+		} else {
+			result.Warn("%s: 格式不支持嵌入封面", filepath.Base(path))
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	url := fieldString(result.Info.Fields["thumbnail"])
+	if url == "" {
+		result.Warn("%s: 沒有縮略圖，未嵌入封面", result.Title)
+		return nil
+	}
+	data, err := c.fetcher.Fetch(ctx, url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result.Warn("%s: 下載縮略圖失敗，未嵌入封面: %v", result.Title, err)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "yt2mp3-cover-")
+	if err != nil {
+		result.Warn("%s: 創建臨時目錄失敗，未嵌入封面: %v", result.Title, err)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	cover, err := c.prepare(ctx, dir, data)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result.Warn("%s: 處理縮略圖失敗，未嵌入封面: %v", result.Title, err)
+		return nil
+	}
+	for _, path := range files {
+		if err := c.embed(ctx, path, cover); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Warn("嵌入封面失敗: %v", err)
+		}
+	}
+	return nil
+}
+
+// prepare 把縮略圖寫入 dir，需要裁剪、縮放或轉換格式時用 ffmpeg 處理，返回封面文件路徑
+func (c *CoverArt) prepare(ctx context.Context, dir string, data []byte) (string, error) {
+	mime := http.DetectContentType(data)
+	src := filepath.Join(dir, "thumbnail"+imageExtension(mime))
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		return "", err
+	}
+
+	filters := c.filters()
+	convert := c.config.CoverJPEG && mime == "image/webp"
+	if len(filters) == 0 && !convert {
+		return src, nil
+	}
+
+	// 處理後的封面為 JPEG，保留原格式時用無損的 PNG
+	dst := filepath.Join(dir, "cover.png")
+	if c.config.CoverJPEG || mime == "image/jpeg" {
+		dst = filepath.Join(dir, "cover.jpg")
+	}
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", src}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+	args = append(args, "-frames:v", "1")
+	if filepath.Ext(dst) == ".jpg" {
+		args = append(args, "-q:v", "2")
+	}
+	args = append(args, dst)
+
+	var stderr bytes.Buffer
+	if err := c.executor.ExecuteContext(ctx, c.config.FFmpeg(), args, io.Discard, &stderr); err != nil {
+		return "", fmt.Errorf("%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return dst, nil
+}
+
+// filters 返回裁剪和縮放封面的 ffmpeg 濾鏡
+func (c *CoverArt) filters() []string {
+	var filters []string
+	if c.config.CoverSquare {
+		filters = append(filters, "crop='min(iw,ih)':'min(iw,ih)'")
+	}
+	if size := c.config.CoverSize; size > 0 {
+		n := strconv.Itoa(size)
+		filters = append(filters, "scale='min("+n+",iw)':'min("+n+",ih)':force_original_aspect_ratio=decrease")
+	}
+	return filters
+}
+
+// embed 用 ffmpeg 複製音頻流並加入封面，先寫臨時文件再替換原文件
+func (c *CoverArt) embed(ctx context.Context, path, cover string) error {
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path, "-i", cover,
+		"-map", "0:a", "-map", "1:v", "-c", "copy", "-map_metadata", "0",
+		"-disposition:v", "attached_pic",
+		"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)"}
+	if strings.EqualFold(filepath.Ext(path), ".mp3") {
+		args = append(args, "-id3v2_version", "3")
+	}
+	_, err := rewrite(ctx, c.executor, c.config.FFmpeg(), path, "cover", args)
+	return err
+}
+
+// supportsCover 判斷文件格式是否支持嵌入封面
+func supportsCover(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range coverFormats {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// imageExtension 根據 MIME 類型返回圖片擴展名
+func imageExtension(mime string) string {
+	switch mime {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".img"
+	}
+}
@@ -0,0 +1,170 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+var (
+	jpegThumbnail = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	webpThumbnail = []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")
+)
+
+func TestHTTPFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/maxresdefault.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(jpegThumbnail)
+	}))
+	defer server.Close()
+
+	fetcher := &HTTPFetcher{Client: server.Client()}
+	data, err := fetcher.Fetch(context.Background(), server.URL+"/maxresdefault.jpg")
+	if err != nil || string(data) != string(jpegThumbnail) {
+		t.Errorf("Expected thumbnail data, got %q (err: %v)", data, err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/missing.jpg"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 error, got: %v", err)
+	}
+}
+
+func TestCoverArtProcess(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		thumbnail []byte
+		fetchErr  error
+		modify    func(cfg *config.Config)
+		ffmpegErr bool
+		calls     []string // 每次 ffmpeg 調用應包含的參數
+		warning   string
+	}{
+		{
+			name:      "jpeg embedded as is",
+			file:      "Song.mp3",
+			thumbnail: jpegThumbnail,
+			calls:     []string{"-map 1:v -c copy -map_metadata 0 -disposition:v attached_pic"},
+		},
+		{
+			name:      "webp converted to jpeg",
+			file:      "Song.m4a",
+			thumbnail: webpThumbnail,
+			calls:     []string{"thumbnail.webp -frames:v 1 -q:v 2", "-disposition:v attached_pic"},
+		},
+		{
+			name:      "webp kept",
+			file:      "Song.flac",
+			thumbnail: webpThumbnail,
+			modify:    func(cfg *config.Config) { cfg.CoverJPEG = false },
+			calls:     []string{"thumbnail.webp -map 0:a"},
+		},
+		{
+			name:      "square and resized",
+			file:      "Song.mp3",
+			thumbnail: jpegThumbnail,
+			modify: func(cfg *config.Config) {
+				cfg.CoverSquare = true
+				cfg.CoverSize = 500
+			},
+			calls: []string{
+				"-vf crop='min(iw,ih)':'min(iw,ih)',scale='min(500,iw)':'min(500,ih)':force_original_aspect_ratio=decrease",
+				"cover.jpg -map 0:a",
+			},
+		},
+		{
+			name:     "fetch failure",
+			file:     "Song.mp3",
+			fetchErr: errors.New("HTTP 404 Not Found"),
+			warning:  "下載縮略圖失敗，未嵌入封面: HTTP 404 Not Found",
+		},
+		{
+			name:      "unsupported format",
+			file:      "Song.opus",
+			thumbnail: jpegThumbnail,
+			warning:   "Song.opus: 格式不支持嵌入封面",
+		},
+		{
+			name:      "ffmpeg failure",
+			file:      "Song.mp3",
+			thumbnail: jpegThumbnail,
+			ffmpegErr: true,
+			calls:     []string{"-disposition:v attached_pic"},
+			warning:   "嵌入封面失敗: Song.mp3: exit status 1 Invalid data found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			var calls []string
+			executor := &mocks.CommandExecutor{
+				ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+					calls = append(calls, strings.Join(args, " "))
+					if tt.ffmpegErr {
+						io.WriteString(stderr, "Invalid data found\n")
+						return errors.New("exit status 1")
+					}
+					return os.WriteFile(args[len(args)-1], []byte("with cover"), 0644)
+				},
+			}
+			fetcher := &mocks.Fetcher{FetchFunc: func(url string) ([]byte, error) {
+				return tt.thumbnail, tt.fetchErr
+			}}
+			cfg := config.NewConfig()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+
+			result := &downloader.Result{URL: "https://youtu.be/x", Files: []string{path}, Info: loadInfo(t, "video.json")}
+			if err := NewCoverArt(cfg, executor, fetcher).Process(context.Background(), result); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("Expected %d ffmpeg calls, got %d: %v", len(tt.calls), len(calls), calls)
+			}
+			for i, want := range tt.calls {
+				if !strings.Contains(calls[i], want) {
+					t.Errorf("Expected call %d to contain %q, got: %s", i, want, calls[i])
+				}
+			}
+			if len(calls) > 0 && strings.HasSuffix(tt.file, ".mp3") && !strings.Contains(calls[len(calls)-1], "-id3v2_version 3") {
+				t.Errorf("Expected ID3v2.3 for mp3, got: %s", calls[len(calls)-1])
+			}
+
+			expected := "with cover"
+			if tt.warning != "" {
+				expected = "audio"
+				if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], tt.warning) {
+					t.Errorf("Expected warning %q, got %v", tt.warning, result.Warnings)
+				}
+			} else if len(result.Warnings) != 0 {
+				t.Errorf("Expected no warnings, got %v", result.Warnings)
+			}
+			if data, _ := os.ReadFile(path); string(data) != expected {
+				t.Errorf("Expected file content %q, got %q", expected, data)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(dir, ".*")); len(matches) != 0 {
+				t.Errorf("Expected temp file to be removed, got %v", matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// ReplayGainReference ReplayGain 2.0 的參考響度（LUFS）
+const ReplayGainReference = -18.0
+
+// 輸入流信息中的採樣率，例如 "Audio: mp3, 44100 Hz, stereo"
+var sampleRateRe = regexp.MustCompile(`Audio: .*?, (\d+) Hz`)
+
+// loudnormStats loudnorm 濾鏡 print_format=json 輸出的字段，值都是字符串
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	OutputI      string `json:"output_i"`
+	OutputTP     string `json:"output_tp"`
+	OutputLRA    string `json:"output_lra"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// Normalizer 用 ffmpeg 的 loudnorm 濾鏡（EBU R128）處理響度
+// loudnorm 模式先測量再按測量值線性調整並重新編碼，replaygain 模式只測量並寫入 ReplayGain 標籤
+type Normalizer struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+}
+
+// NewNormalizer 創建響度處理步驟
+func NewNormalizer(cfg *config.Config, executor downloader.CommandExecutor) *Normalizer {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	return &Normalizer{config: cfg, executor: executor}
+}
+
+// Name 實現 Step 接口
+func (n *Normalizer) Name() string {
+	return "響度處理"
+}
+
+// Process 處理結果中的每個文件，測量值記錄在 result.Loudness
+func (n *Normalizer) Process(ctx context.Context, result *downloader.Result) error {
+	for i, path := range result.Files {
+		stats, rate, err := n.measure(ctx, path)
+		if err != nil {
+			return err
+		}
+		loudness := downloader.Loudness{Path: path, Before: stats.before(), After: stats.before()}
+		// 靜音的響度為 -inf，無法調整
+		if math.IsInf(loudness.Before.Integrated, -1) {
+			result.Warn("%s: 音頻為靜音，未處理響度", filepath.Base(path))
+			continue
+		}
+
+		if n.config.Loudness == "replaygain" {
+			loudness.Gain = ReplayGainReference - loudness.Before.Integrated
+			err = n.writeReplayGain(ctx, path, loudness)
+		} else {
+			loudness.After, err = n.normalize(ctx, path, encodePreset(n.config, result, i), stats, rate)
+		}
+		if err != nil {
+			return err
+		}
+		result.Loudness = append(result.Loudness, loudness)
+	}
+	return nil
+}
+
+// filter 返回 loudnorm 濾鏡參數，measured 不為空時使用第一遍的測量值
+func (n *Normalizer) filter(measured *loudnormStats) string {
+	f := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s",
+		formatFloat(n.config.LoudnessTarget), formatFloat(n.config.LoudnessTruePeak), formatFloat(n.config.LoudnessRange))
+	if measured != nil {
+		f += fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset)
+	}
+	return f + ":print_format=json"
+}
+
+// measure 第一遍：只分析不輸出，返回測量值和輸入的採樣率
+func (n *Normalizer) measure(ctx context.Context, path string) (*loudnormStats, string, error) {
+	args := []string{"-hide_banner", "-nostdin", "-i", path, "-map", "0:a:0", "-af", n.filter(nil), "-f", "null", "-"}
+	var stderr bytes.Buffer
+	if err := n.executor.ExecuteContext(ctx, n.config.FFmpeg(), args, io.Discard, &stderr); err != nil {
+		return nil, "", fmt.Errorf("%s: 測量響度失敗: %v", filepath.Base(path), err)
+	}
+	stats, err := parseLoudnorm(stderr.String())
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	rate := ""
+	if m := sampleRateRe.FindStringSubmatch(stderr.String()); m != nil {
+		rate = m[1]
+	}
+	return stats, rate, nil
+}
+
+// normalize 第二遍：按測量值調整並用原格式的編碼參數重新編碼，返回處理後的響度
+// loudnorm 內部上採樣到 192kHz，輸出時恢復原採樣率
+func (n *Normalizer) normalize(ctx context.Context, path string, preset config.Preset, measured *loudnormStats, rate string) (downloader.LoudnessStats, error) {
+	args := []string{"-hide_banner", "-nostdin", "-y", "-i", path,
+		"-map", "0:a:0", "-map_metadata", "0", "-af", n.filter(measured)}
+	if rate != "" {
+		args = append(args, "-ar", rate)
+	}
+	args = append(args, preset.FFmpegArgs()...)
+	if strings.EqualFold(filepath.Ext(path), ".mp3") {
+		args = append(args, "-id3v2_version", "3")
+	}
+
+	stderr, err := rewrite(ctx, n.executor, n.config.FFmpeg(), path, "loudnorm", args)
+	if err != nil {
+		return downloader.LoudnessStats{}, err
+	}
+	stats, err := parseLoudnorm(stderr)
+	if err != nil {
+		return downloader.LoudnessStats{}, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return stats.after(), nil
+}
+
+// writeReplayGain 不改變音頻，寫入 ReplayGain 音軌增益和峰值標籤
+func (n *Normalizer) writeReplayGain(ctx context.Context, path string, loudness downloader.Loudness) error {
+	peak := math.Pow(10, loudness.Before.TruePeak/20)
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path,
+		"-map", "0", "-c", "copy", "-map_metadata", "0",
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_GAIN=%.2f dB", loudness.Gain),
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_PEAK=%.6f", peak)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		args = append(args, "-id3v2_version", "3")
+	case ".m4a":
+		// mp4 默認只寫入 iTunes 的標準標籤
+		args = append(args, "-movflags", "use_metadata_tags")
+	}
+	_, err := rewrite(ctx, n.executor, n.config.FFmpeg(), path, "replaygain", args)
+	return err
+}
+
+// parseLoudnorm 解析 ffmpeg 錯誤輸出中 loudnorm 打印的最後一個 JSON 對象
+func parseLoudnorm(output string) (*loudnormStats, error) {
+	end := strings.LastIndex(output, "}")
+	start := strings.LastIndex(output[:end+1], "{")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("ffmpeg 沒有輸出響度測量值")
+	}
+	stats := &loudnormStats{}
+	if err := json.Unmarshal([]byte(output[start:end+1]), stats); err != nil {
+		return nil, fmt.Errorf("解析響度測量值失敗: %v", err)
+	}
+	return stats, nil
+}
+
+// before 返回處理前的響度
+func (s *loudnormStats) before() downloader.LoudnessStats {
+	return downloader.LoudnessStats{Integrated: parseStat(s.InputI), TruePeak: parseStat(s.InputTP), Range: parseStat(s.InputLRA)}
+}
+
+// after 返回處理後的響度
+func (s *loudnormStats) after() downloader.LoudnessStats {
+	return downloader.LoudnessStats{Integrated: parseStat(s.OutputI), TruePeak: parseStat(s.OutputTP), Range: parseStat(s.OutputLRA)}
+}
+
+// parseStat 解析測量值，loudnorm 對靜音輸出 "-inf"
+func parseStat(value string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return math.Inf(-1)
+	}
+	return f
+}
+
+// formatFloat 格式化 ffmpeg 濾鏡參數
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
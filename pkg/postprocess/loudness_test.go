@@ -0,0 +1,192 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+// loudnormOutput 模擬 ffmpeg loudnorm 濾鏡在錯誤輸出中打印的內容
+func loudnormOutput(inputI, outputI string) string {
+	return `Input #0, mp3, from 'Song.mp3':
+  Stream #0:0: Audio: mp3, 44100 Hz, stereo, fltp, 320 kb/s
+[Parsed_loudnorm_0 @ 0x5581]
+{
+	"input_i" : "` + inputI + `",
+	"input_tp" : "-0.52",
+	"input_lra" : "6.30",
+	"input_thresh" : "-19.34",
+	"output_i" : "` + outputI + `",
+	"output_tp" : "-1.50",
+	"output_lra" : "5.90",
+	"output_thresh" : "-26.02",
+	"normalization_type" : "linear",
+	"target_offset" : "0.12"
+}
+`
+}
+
+// fakeLoudnorm 記錄 ffmpeg 調用，第一遍輸出 inputI 的測量值，其餘調用寫出輸出文件
+func fakeLoudnorm(inputI string, calls *[]string) *mocks.CommandExecutor {
+	return &mocks.CommandExecutor{
+		ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			joined := strings.Join(args, " ")
+			*calls = append(*calls, joined)
+			if strings.HasSuffix(joined, "-f null -") {
+				io.WriteString(stderr, loudnormOutput(inputI, "-40.00"))
+				return nil
+			}
+			if strings.Contains(joined, "measured_I") {
+				io.WriteString(stderr, loudnormOutput(inputI, "-16.02"))
+			}
+			return os.WriteFile(args[len(args)-1], []byte("normalized"), 0644)
+		},
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		modify   func(cfg *config.Config)
+		outputs  []downloader.OutputFile
+		calls    []string
+		expected downloader.Loudness
+	}{
+		{
+			name: "loudnorm",
+			file: "Song.mp3",
+			modify: func(cfg *config.Config) {
+				cfg.Loudness = "loudnorm"
+			},
+			calls: []string{
+				"-i {path} -map 0:a:0 -af loudnorm=I=-16:TP=-1.5:LRA=11:print_format=json -f null -",
+				"-af loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-27.47:measured_TP=-0.52:measured_LRA=6.30:measured_thresh=-19.34:offset=0.12:linear=true:print_format=json -ar 44100 -c:a libmp3lame -b:a 320k -id3v2_version 3",
+			},
+			expected: downloader.Loudness{
+				Before: downloader.LoudnessStats{Integrated: -27.47, TruePeak: -0.52, Range: 6.3},
+				After:  downloader.LoudnessStats{Integrated: -16.02, TruePeak: -1.5, Range: 5.9},
+			},
+		},
+		{
+			name: "loudnorm multiple outputs",
+			file: "Song.ogg",
+			modify: func(cfg *config.Config) {
+				cfg.Loudness = "loudnorm"
+				cfg.LoudnessTarget = -23
+			},
+			outputs: []downloader.OutputFile{{Preset: "ogg-192"}},
+			calls: []string{
+				"loudnorm=I=-23:TP=-1.5:LRA=11:print_format=json",
+				"-c:a libvorbis -b:a 192k",
+			},
+			expected: downloader.Loudness{
+				Before: downloader.LoudnessStats{Integrated: -27.47, TruePeak: -0.52, Range: 6.3},
+				After:  downloader.LoudnessStats{Integrated: -16.02, TruePeak: -1.5, Range: 5.9},
+			},
+		},
+		{
+			name: "replaygain",
+			file: "Song.m4a",
+			modify: func(cfg *config.Config) {
+				cfg.Loudness = "replaygain"
+			},
+			calls: []string{
+				"-f null -",
+				"-c copy -map_metadata 0 -metadata REPLAYGAIN_TRACK_GAIN=9.47 dB -metadata REPLAYGAIN_TRACK_PEAK=0.941890 -movflags use_metadata_tags",
+			},
+			expected: downloader.Loudness{
+				Before: downloader.LoudnessStats{Integrated: -27.47, TruePeak: -0.52, Range: 6.3},
+				After:  downloader.LoudnessStats{Integrated: -27.47, TruePeak: -0.52, Range: 6.3},
+				Gain:   9.47,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			cfg := config.NewConfig()
+			tt.modify(cfg)
+			var calls []string
+			result := &downloader.Result{Files: []string{path}, Outputs: tt.outputs}
+
+			if err := NewNormalizer(cfg, fakeLoudnorm("-27.47", &calls)).Process(context.Background(), result); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("Expected %d ffmpeg calls, got %d: %v", len(tt.calls), len(calls), calls)
+			}
+			for i, want := range tt.calls {
+				want = strings.ReplaceAll(want, "{path}", path)
+				if !strings.Contains(calls[i], want) {
+					t.Errorf("Expected call %d to contain %q, got: %s", i, want, calls[i])
+				}
+			}
+
+			if len(result.Loudness) != 1 {
+				t.Fatalf("Expected one loudness record, got %v", result.Loudness)
+			}
+			got := result.Loudness[0]
+			tt.expected.Path = path
+			if got.Path != path || got.Before != tt.expected.Before || got.After != tt.expected.After ||
+				math.Abs(got.Gain-tt.expected.Gain) > 1e-9 {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+			if data, _ := os.ReadFile(path); string(data) != "normalized" {
+				t.Errorf("Expected file to be replaced, got %q", data)
+			}
+		})
+	}
+
+	t.Run("silence is skipped with a warning", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "Silence.mp3")
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		cfg := config.NewConfig()
+		cfg.Loudness = "loudnorm"
+		var calls []string
+		result := &downloader.Result{Files: []string{path}}
+		if err := NewNormalizer(cfg, fakeLoudnorm("-inf", &calls)).Process(context.Background(), result); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(calls) != 1 || len(result.Loudness) != 0 || len(result.Warnings) != 1 {
+			t.Errorf("Expected only measurement and a warning, got calls %v, warnings %v", calls, result.Warnings)
+		}
+	})
+
+	t.Run("missing measurement", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "Song.mp3")
+		cfg := config.NewConfig()
+		cfg.Loudness = "loudnorm"
+		mock := &mocks.CommandExecutor{}
+		err := NewNormalizer(cfg, mock).Process(context.Background(), &downloader.Result{Files: []string{path}})
+		if err == nil || !strings.Contains(err.Error(), "沒有輸出響度測量值") {
+			t.Errorf("Expected measurement error, got: %v", err)
+		}
+	})
+}
+
+func TestParseLoudnorm(t *testing.T) {
+	stats, err := parseLoudnorm(loudnormOutput("-27.47", "-16.02"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := fmt.Sprint(stats.before(), stats.after()); got != "{-27.47 -0.52 6.3} {-16.02 -1.5 5.9}" {
+		t.Errorf("Unexpected stats: %s", got)
+	}
+}
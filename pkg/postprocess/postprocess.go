@@ -0,0 +1,190 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// Step 下載完成後對結果的處理步驟，可以改寫或替換 result.Files
+type Step interface {
+	Name() string
+	Process(ctx context.Context, result *downloader.Result) error
+}
+
+// Pipeline 包裝 Downloader，下載完成後依次執行處理步驟
+// 播放列表逐個條目處理，已跳過和失敗的條目不處理
+// 下載器實現了 downloader.ArchiveRecorder 時，所有步驟成功後才寫入下載存檔，
+// 處理失敗的視頻下次運行時會重新下載
+type Pipeline struct {
+	downloader downloader.Downloader
+	recorder   downloader.ArchiveRecorder
+	steps      []Step
+}
+
+// New 創建後處理流水線，沒有步驟時直接返回下載結果
+func New(d downloader.Downloader, steps ...Step) *Pipeline {
+	p := &Pipeline{downloader: d, steps: steps}
+	if r, ok := d.(downloader.ArchiveRecorder); ok {
+		r.DeferArchive()
+		p.recorder = r
+	}
+	return p
+}
+
+// Steps 根據配置創建後處理步驟
+func Steps(cfg *config.Config, executor downloader.CommandExecutor) []Step {
+	var steps []Step
+	// SponsorBlock 的時間相對於原視頻，最先移除
+	if len(cfg.SponsorBlock) > 0 {
+		steps = append(steps, NewSponsorBlock(cfg, executor))
+	}
+	// 修剪改變音頻的長度，放在測量響度之前
+	if cfg.Trimming() {
+		steps = append(steps, NewTrimmer(cfg, executor))
+	}
+	// 響度處理重新編碼時只保留音頻流，放在嵌入封面之前
+	if cfg.Loudness != "" {
+		steps = append(steps, NewNormalizer(cfg, executor))
+	}
+	if cfg.Tags {
+		steps = append(steps, NewTagger(cfg, executor))
+	}
+	if cfg.Cover {
+		steps = append(steps, NewCoverArt(cfg, executor, nil))
+	}
+	// 拆分放在最後，章節文件繼承已寫入的標籤和封面
+	if cfg.SplitChapters {
+		steps = append(steps, NewChapterSplitter(cfg, executor))
+	}
+	return steps
+}
+
+// Download 下載並執行後處理
+func (p *Pipeline) Download(url string) (*downloader.Result, error) {
+	return p.DownloadContext(context.Background(), url)
+}
+
+// DownloadContext 下載並執行後處理，ctx 取消時停止後續步驟
+func (p *Pipeline) DownloadContext(ctx context.Context, url string) (*downloader.Result, error) {
+	result, err := p.downloader.DownloadContext(ctx, url)
+	if err != nil || result == nil || result.Skipped {
+		return result, err
+	}
+
+	if len(result.Entries) == 0 {
+		if err := p.process(ctx, result); err != nil {
+			return result, err
+		}
+		return result, p.record(result)
+	}
+
+	// 播放列表中單個條目處理失敗只標記該條目
+	var files []string
+	for i, entry := range result.Entries {
+		if entry.Err == nil && !entry.Skipped {
+			if err := p.process(ctx, entry); err != nil {
+				if ctx.Err() != nil {
+					// 未處理完的條目不算完成，下次運行時重新下載
+					for _, rest := range result.Entries[i:] {
+						if rest.Err == nil && !rest.Skipped {
+							rest.Err = err
+						}
+					}
+					p.record(result)
+					return result, err
+				}
+				entry.Err = err
+			}
+		}
+		if entry.Err == nil {
+			files = append(files, entry.Files...)
+		}
+	}
+	result.SetFiles(files)
+	return result, p.record(result)
+}
+
+// record 後處理完成後寫入下載存檔
+func (p *Pipeline) record(result *downloader.Result) error {
+	if p.recorder == nil {
+		return nil
+	}
+	return p.recorder.RecordArchive(result)
+}
+
+// GetOutputFiles 列出輸出目錄中的文件
+func (p *Pipeline) GetOutputFiles() ([]string, error) {
+	return p.downloader.GetOutputFiles()
+}
+
+// process 對單個視頻依次執行所有步驟
+func (p *Pipeline) process(ctx context.Context, result *downloader.Result) error {
+	if len(result.Files) == 0 {
+		return nil
+	}
+	for _, step := range p.steps {
+		if err := ctx.Err(); err != nil {
+			return &downloader.CancelledError{URL: result.URL, Err: err}
+		}
+		if err := step.Process(ctx, result); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return &downloader.CancelledError{URL: result.URL, Err: ctxErr}
+			}
+			return fmt.Errorf("%s失敗: %v", step.Name(), err)
+		}
+	}
+	result.SetFiles(result.Files)
+	return nil
+}
+
+// rewrite 用 ffmpeg 把 path 處理到同目錄的臨時文件，成功後替換原文件，返回 ffmpeg 的錯誤輸出
+// ffmpeg 是 ffmpeg 的路徑，args 是輸出文件之前的參數，suffix 用於區分臨時文件，例如 "tagging"
+func rewrite(ctx context.Context, executor downloader.CommandExecutor, ffmpeg, path, suffix string, args []string) (string, error) {
+	ext := filepath.Ext(path)
+	tmp := filepath.Join(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), ext)+"."+suffix+ext)
+
+	var stderr bytes.Buffer
+	if err := executor.ExecuteContext(ctx, ffmpeg, append(args, tmp), io.Discard, &stderr); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("%s: %v %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("替換 %s 失敗: %v", filepath.Base(path), err)
+	}
+	return stderr.String(), nil
+}
+
+// encodePreset 返回重新編碼第 i 個文件使用的格式和質量，多格式輸出時使用對應的預設
+func encodePreset(cfg *config.Config, result *downloader.Result, i int) config.Preset {
+	if i < len(result.Outputs) {
+		if p, ok := config.LookupPreset(result.Outputs[i].Preset); ok {
+			return p
+		}
+	}
+	p := config.Preset{AudioFormat: cfg.AudioFormat, AudioQuality: cfg.AudioQuality, Bitrate: cfg.Bitrate}
+	if p.AudioFormat == "best" {
+		// yt-dlp 保留了原始編碼，按擴展名判斷
+		p.AudioFormat = formatFromExtension(result.Files[i])
+		p.Bitrate = ""
+	}
+	return p
+}
+
+// formatFromExtension 根據擴展名返回 config.Presets 使用的格式名
+func formatFromExtension(path string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case "ogg":
+		return "vorbis"
+	default:
+		return ext
+	}
+}
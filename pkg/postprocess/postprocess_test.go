@@ -0,0 +1,231 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/archive"
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+// recordStep 記錄處理過的結果，failOn 中的視頻返回錯誤
+type recordStep struct {
+	processed []string
+	failOn    string
+}
+
+func (s *recordStep) Name() string { return "測試步驟" }
+
+func (s *recordStep) Process(ctx context.Context, result *downloader.Result) error {
+	s.processed = append(s.processed, result.VideoID)
+	if result.VideoID == s.failOn {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestPipeline(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(file, []byte("12345"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	t.Run("single video", func(t *testing.T) {
+		step := &recordStep{}
+		mock := &mocks.Downloader{DownloadFunc: func(url string) (*downloader.Result, error) {
+			return &downloader.Result{URL: url, VideoID: "a", Files: []string{file}}, nil
+		}}
+		result, err := New(mock, step).Download("https://youtu.be/a")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(step.processed) != 1 || result.Size != 5 {
+			t.Errorf("Expected one processed result with size 5, got %v (size %d)", step.processed, result.Size)
+		}
+	})
+
+	t.Run("step failure", func(t *testing.T) {
+		step := &recordStep{failOn: "a"}
+		mock := &mocks.Downloader{DownloadFunc: func(url string) (*downloader.Result, error) {
+			return &downloader.Result{URL: url, VideoID: "a", Files: []string{file}}, nil
+		}}
+		_, err := New(mock, step).Download("https://youtu.be/a")
+		if err == nil || err.Error() != "測試步驟失敗: boom" {
+			t.Errorf("Expected step error, got: %v", err)
+		}
+	})
+
+	t.Run("skipped results are not processed", func(t *testing.T) {
+		step := &recordStep{}
+		mock := &mocks.Downloader{DownloadFunc: func(url string) (*downloader.Result, error) {
+			return &downloader.Result{URL: url, VideoID: "a", Files: []string{file}, Skipped: true}, nil
+		}}
+		if _, err := New(mock, step).Download("https://youtu.be/a"); err != nil || len(step.processed) != 0 {
+			t.Errorf("Expected skipped result to be returned as is, got %v (err: %v)", step.processed, err)
+		}
+	})
+
+	t.Run("playlist entries", func(t *testing.T) {
+		step := &recordStep{failOn: "b"}
+		mock := &mocks.Downloader{DownloadFunc: func(url string) (*downloader.Result, error) {
+			return &downloader.Result{URL: url, Entries: []*downloader.Result{
+				{VideoID: "a", Files: []string{file}},
+				{VideoID: "b", Files: []string{file}},
+				{VideoID: "c", Err: errors.New("unavailable")},
+			}}, nil
+		}}
+		result, err := New(mock, step).Download("https://www.youtube.com/playlist?list=PL1")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(step.processed) != 2 {
+			t.Errorf("Expected failed entries to be skipped, got %v", step.processed)
+		}
+		if failed := result.Failed(); len(failed) != 2 || failed[0].VideoID != "b" {
+			t.Errorf("Expected b and c to fail, got %v", failed)
+		}
+		if len(result.Files) != 1 {
+			t.Errorf("Expected only a's file, got %v", result.Files)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		step := &recordStep{}
+		mock := &mocks.Downloader{DownloadFunc: func(url string) (*downloader.Result, error) {
+			cancel()
+			return &downloader.Result{URL: url, VideoID: "a", Files: []string{file}}, nil
+		}}
+		_, err := New(mock, step).DownloadContext(ctx, "https://youtu.be/a")
+		var cancelled *downloader.CancelledError
+		if !errors.As(err, &cancelled) || len(step.processed) != 0 {
+			t.Errorf("Expected CancelledError before processing, got: %v", err)
+		}
+	})
+}
+
+// ytDlpMock 模擬 yt-dlp：創建 info 中的文件並寫出 --print-to-file，播放列表模式下像 yt-dlp 一樣追加到 --download-archive
+func ytDlpMock(t *testing.T, infos ...string) *mocks.CommandExecutor {
+	return &mocks.CommandExecutor{ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+		for i, arg := range args {
+			switch arg {
+			case "--print-to-file":
+				if err := os.WriteFile(args[i+2], []byte(strings.Join(infos, "\n")+"\n"), 0644); err != nil {
+					t.Fatalf("Failed to write info file: %v", err)
+				}
+			case "--download-archive":
+				file, _ := os.OpenFile(args[i+1], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				for _, info := range infos {
+					v, _ := downloader.ParseVideoInfo([]byte(info))
+					io.WriteString(file, "youtube "+v.ID+"\n")
+				}
+				file.Close()
+			}
+		}
+		for _, info := range infos {
+			v, _ := downloader.ParseVideoInfo([]byte(info))
+			os.WriteFile(v.FilePath, []byte("audio"), 0644)
+		}
+		return nil
+	}}
+}
+
+func TestPipelineArchive(t *testing.T) {
+	newPipeline := func(cfg *config.Config, arc *archive.Archive, executor downloader.CommandExecutor, step Step) *Pipeline {
+		dl := downloader.NewYtDlpDownloader(cfg, executor)
+		dl.SetOutput(io.Discard, io.Discard)
+		dl.SetArchive(arc)
+		return New(dl, step)
+	}
+
+	t.Run("single video is archived after steps succeed", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := config.NewConfig().WithOutputDir(dir)
+		arc, _ := archive.Open(cfg.ArchiveFile())
+		info := `{"id": "a", "extractor_key": "Youtube", "title": "A", "filepath": "` + filepath.Join(dir, "A.mp3") + `"}`
+
+		_, err := newPipeline(cfg, arc, ytDlpMock(t, info), &recordStep{failOn: "a"}).Download("https://youtu.be/a")
+		if err == nil {
+			t.Fatal("Expected step error")
+		}
+		if arc.Has("youtube", "a") {
+			t.Fatal("Expected failed video not to be archived")
+		}
+
+		// 重新運行時重新下載並處理
+		step := &recordStep{}
+		result, err := newPipeline(cfg, arc, ytDlpMock(t, info), step).Download("https://youtu.be/a")
+		if err != nil || result.Skipped || len(step.processed) != 1 {
+			t.Fatalf("Expected video to be processed again, got %+v (err: %v)", result, err)
+		}
+		if !arc.Has("youtube", "a") {
+			t.Error("Expected video to be archived after steps succeed")
+		}
+	})
+
+	t.Run("playlist entries that fail are not archived", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := config.NewConfig().WithOutputDir(dir)
+		cfg.Playlist = true
+		arc, _ := archive.Open(cfg.ArchiveFile())
+		infos := []string{
+			`{"id": "a", "extractor_key": "Youtube", "title": "A", "playlist_index": 1, "filepath": "` + filepath.Join(dir, "A.mp3") + `"}`,
+			`{"id": "b", "extractor_key": "Youtube", "title": "B", "playlist_index": 2, "filepath": "` + filepath.Join(dir, "B.mp3") + `"}`,
+		}
+
+		result, err := newPipeline(cfg, arc, ytDlpMock(t, infos...), &recordStep{failOn: "b"}).Download("https://www.youtube.com/playlist?list=PL1")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(result.Failed()) != 1 {
+			t.Fatalf("Expected b to fail, got %v", result.Failed())
+		}
+		reopened, _ := archive.Open(cfg.ArchiveFile())
+		if !reopened.Has("youtube", "a") || reopened.Has("youtube", "b") {
+			t.Errorf("Expected only a in archive")
+		}
+		if paths := reopened.Paths("youtube", "a"); len(paths) != 1 || paths[0] != filepath.Join(dir, "A.mp3") {
+			t.Errorf("Unexpected paths: %v", paths)
+		}
+	})
+}
+
+func TestSteps(t *testing.T) {
+	cfg := config.NewConfig()
+	if steps := Steps(cfg, nil); len(steps) != 1 {
+		t.Errorf("Expected tagging by default, got %d steps", len(steps))
+	}
+	cfg.Cover = true
+	if steps := Steps(cfg, nil); len(steps) != 2 || steps[1].Name() != "嵌入封面" {
+		t.Errorf("Expected cover art after tagging, got %d steps", len(steps))
+	}
+	cfg.SplitChapters = true
+	if steps := Steps(cfg, nil); len(steps) != 3 || steps[2].Name() != "按章節拆分" {
+		t.Errorf("Expected chapter split last, got %d steps", len(steps))
+	}
+	cfg.Loudness = "loudnorm"
+	if steps := Steps(cfg, nil); len(steps) != 4 || steps[0].Name() != "響度處理" {
+		t.Errorf("Expected loudness first, got %d steps", len(steps))
+	}
+	cfg.FadeIn = time.Second
+	if steps := Steps(cfg, nil); len(steps) != 5 || steps[0].Name() != "修剪音頻" || steps[1].Name() != "響度處理" {
+		t.Errorf("Expected trimming before loudness, got %d steps", len(steps))
+	}
+	cfg.SponsorBlock = []string{"sponsor"}
+	if steps := Steps(cfg, nil); len(steps) != 6 || steps[0].Name() != "移除 SponsorBlock 片段" {
+		t.Errorf("Expected SponsorBlock first, got %d steps", len(steps))
+	}
+	cfg.Tags, cfg.Cover, cfg.SplitChapters, cfg.Loudness, cfg.FadeIn, cfg.SponsorBlock = false, false, false, "", 0, nil
+	if steps := Steps(cfg, nil); len(steps) != 0 {
+		t.Errorf("Expected no steps, got %d", len(steps))
+	}
+}
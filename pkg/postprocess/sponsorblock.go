@@ -0,0 +1,132 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/sponsorblock"
+)
+
+// SponsorBlock 按 SponsorBlock 數據移除贊助、片頭、自我推廣等片段，按原格式重新編碼
+// 查詢失敗時只記錄警告，音頻文件保持不變
+type SponsorBlock struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+	client   *sponsorblock.Client
+}
+
+// NewSponsorBlock 創建移除片段步驟，通過 cfg.SponsorBlockAPI 查詢片段
+func NewSponsorBlock(cfg *config.Config, executor downloader.CommandExecutor) *SponsorBlock {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	return &SponsorBlock{config: cfg, executor: executor, client: sponsorblock.NewClient(cfg.SponsorBlockAPI, nil)}
+}
+
+// Name 實現 Step 接口
+func (s *SponsorBlock) Name() string {
+	return "移除 SponsorBlock 片段"
+}
+
+// Process 查詢視頻的片段並從每個文件中移除，移除的片段記錄在 result.Removed
+func (s *SponsorBlock) Process(ctx context.Context, result *downloader.Result) error {
+	id, ok := youtubeID(result)
+	if !ok {
+		result.Warn("%s: 不是 YouTube 視頻，未移除 SponsorBlock 片段", result.Title)
+		return nil
+	}
+	segments, err := s.client.Segments(ctx, id, s.config.SponsorBlock)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result.Warn("%s: 查詢 SponsorBlock 失敗，未移除片段: %v", result.Title, err)
+		return nil
+	}
+
+	duration := result.Duration.Seconds()
+	segments = mergeSegments(segments, duration)
+	if len(segments) == 0 {
+		return nil
+	}
+	removed := 0.0
+	for _, seg := range segments {
+		removed += seg.End - seg.Start
+	}
+	if duration > 0 && duration-removed < 1 {
+		result.Warn("%s: SponsorBlock 片段覆蓋了整個視頻，未移除", result.Title)
+		return nil
+	}
+
+	filter := segmentFilter(segments)
+	for i, path := range result.Files {
+		args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path,
+			"-map", "0:a:0", "-map_metadata", "0", "-af", filter}
+		args = append(args, encodePreset(s.config, result, i).FFmpegArgs()...)
+		if strings.EqualFold(filepath.Ext(path), ".mp3") {
+			args = append(args, "-id3v2_version", "3")
+		}
+		if _, err := rewrite(ctx, s.executor, s.config.FFmpeg(), path, "sponsorblock", args); err != nil {
+			return err
+		}
+	}
+	result.Removed = append(result.Removed, segments...)
+	return nil
+}
+
+// youtubeID 返回結果的 YouTube 視頻 ID，優先從 URL 中提取，播放列表條目使用 webpage_url
+func youtubeID(result *downloader.Result) (string, bool) {
+	if id, ok := downloader.ExtractVideoID(result.URL); ok {
+		return id, true
+	}
+	if result.Info != nil {
+		if id, ok := downloader.ExtractVideoID(fieldString(result.Info.Fields["webpage_url"])); ok {
+			return id, true
+		}
+		if result.Info.ExtractorKey == "Youtube" && result.VideoID != "" {
+			return result.VideoID, true
+		}
+	}
+	return "", false
+}
+
+// mergeSegments 合併重疊的片段並截取到 duration 以內，segments 按開始時間排序
+// 合併後的片段類別用逗號連接，duration 為 0 時不截取
+func mergeSegments(segments []sponsorblock.Segment, duration float64) []sponsorblock.Segment {
+	var merged []sponsorblock.Segment
+	for _, seg := range segments {
+		if duration > 0 {
+			if seg.Start >= duration {
+				continue
+			}
+			if seg.End > duration {
+				seg.End = duration
+			}
+		}
+		if n := len(merged); n > 0 && seg.Start <= merged[n-1].End {
+			last := &merged[n-1]
+			if seg.End > last.End {
+				last.End = seg.End
+			}
+			if !strings.Contains(","+last.Category+",", ","+seg.Category+",") {
+				last.Category += "," + seg.Category
+			}
+			continue
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+// segmentFilter 返回丟棄片段內採樣並重新計算時間戳的 ffmpeg 濾鏡
+func segmentFilter(segments []sponsorblock.Segment) string {
+	between := make([]string, len(segments))
+	for i, seg := range segments {
+		between[i] = fmt.Sprintf("between(t,%s,%s)", formatSeconds(round(seg.Start)), formatSeconds(round(seg.End)))
+	}
+	return "aselect='not(" + strings.Join(between, "+") + ")',asetpts=N/SR/TB"
+}
@@ -0,0 +1,168 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/sponsorblock"
+	"youtube_to_mp3/test/mocks"
+)
+
+// sponsorBlockServer 模擬 SponsorBlock API，dQw4w9WgXcQ 有兩個重疊的片段和一個自我推廣片段
+func sponsorBlockServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("videoID") {
+		case "dQw4w9WgXcQ":
+			fmt.Fprint(w, `[
+				{"category": "sponsor", "actionType": "skip", "segment": [10, 40]},
+				{"category": "intro", "actionType": "skip", "segment": [0, 12.5]},
+				{"category": "selfpromo", "actionType": "skip", "segment": [200, 230]}
+			]`)
+		case "allofvideo1":
+			fmt.Fprint(w, `[{"category": "music_offtopic", "actionType": "skip", "segment": [0, 212]}]`)
+		case "servererror":
+			http.Error(w, "database down", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSponsorBlock(t *testing.T) {
+	server := sponsorBlockServer(t)
+
+	tests := []struct {
+		name     string
+		url      string
+		info     *downloader.VideoInfo
+		filter   string
+		removed  string
+		warnings int
+	}{
+		{
+			name:    "segments removed",
+			url:     "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			filter:  "aselect='not(between(t,0,40)+between(t,200,212))',asetpts=N/SR/TB",
+			removed: "[{intro,sponsor 0 40} {selfpromo 200 212}]",
+		},
+		{
+			name: "playlist entry uses webpage url",
+			url:  "https://www.youtube.com/playlist?list=PL123",
+			info: &downloader.VideoInfo{Fields: map[string]interface{}{
+				"webpage_url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			}},
+			filter:  "aselect='not(between(t,0,40)+between(t,200,212))',asetpts=N/SR/TB",
+			removed: "[{intro,sponsor 0 40} {selfpromo 200 212}]",
+		},
+		{
+			name:    "youtube extractor uses video id",
+			url:     "ytsearch:never gonna give you up",
+			info:    &downloader.VideoInfo{ExtractorKey: "Youtube"},
+			filter:  "aselect='not(between(t,0,40)+between(t,200,212))',asetpts=N/SR/TB",
+			removed: "[{intro,sponsor 0 40} {selfpromo 200 212}]",
+		},
+		{
+			name: "no segments",
+			url:  "https://youtu.be/aqz-KE-bpKQ",
+		},
+		{
+			name:     "not youtube",
+			url:      "https://vimeo.com/123456",
+			warnings: 1,
+		},
+		{
+			name:     "api failure",
+			url:      "https://youtu.be/servererror",
+			warnings: 1,
+		},
+		{
+			name:     "segment covers the whole video",
+			url:      "https://youtu.be/allofvideo1",
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Song.mp3")
+			if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			var calls []string
+			mock := &mocks.CommandExecutor{
+				ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+					calls = append(calls, strings.Join(args, " "))
+					return os.WriteFile(args[len(args)-1], []byte("removed"), 0644)
+				},
+			}
+			cfg := config.NewConfig()
+			cfg.SponsorBlock = []string{"sponsor", "intro", "selfpromo"}
+			cfg.SponsorBlockAPI = server.URL
+			result := &downloader.Result{URL: tt.url, VideoID: "dQw4w9WgXcQ", Duration: 212 * time.Second, Files: []string{path}, Info: tt.info}
+
+			if err := NewSponsorBlock(cfg, mock).Process(context.Background(), result); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if tt.filter == "" {
+				if len(calls) != 0 {
+					t.Errorf("Expected file to be left unchanged, got calls: %v", calls)
+				}
+			} else if len(calls) != 1 || !strings.Contains(calls[0], "-map 0:a:0 -map_metadata 0 -af "+tt.filter) {
+				t.Errorf("Expected call to contain %q, got: %v", tt.filter, calls)
+			}
+			if got := fmt.Sprint(result.Removed); (tt.removed != "" || len(result.Removed) > 0) && got != tt.removed {
+				t.Errorf("Expected removed %s, got %s", tt.removed, got)
+			}
+			if len(result.Warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestMergeSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []sponsorblock.Segment
+		duration float64
+		expected string
+	}{
+		{
+			name:     "separate",
+			segments: []sponsorblock.Segment{{Category: "sponsor", Start: 10, End: 20}, {Category: "outro", Start: 90, End: 100}},
+			expected: "[{sponsor 10 20} {outro 90 100}]",
+		},
+		{
+			name:     "touching segments are merged",
+			segments: []sponsorblock.Segment{{Category: "sponsor", Start: 10, End: 20}, {Category: "sponsor", Start: 20, End: 30}, {Category: "selfpromo", Start: 25, End: 28}},
+			expected: "[{sponsor,selfpromo 10 30}]",
+		},
+		{
+			name:     "clamped to duration",
+			segments: []sponsorblock.Segment{{Category: "sponsor", Start: 10, End: 20}, {Category: "outro", Start: 55, End: 70}, {Category: "preview", Start: 80, End: 90}},
+			duration: 60,
+			expected: "[{sponsor 10 20} {outro 55 60}]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(mergeSegments(tt.segments, tt.duration)); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package postprocess
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// Tags 要寫入的標籤，鍵為 config.TagNames 中的標籤名
+type Tags map[string]string
+
+// Tagger 根據 yt-dlp 的 info JSON 寫入標籤
+// mp3 寫入 ID3v2.3，opus/ogg/flac 寫入 Vorbis comment，m4a 寫入 iTunes 標籤
+type Tagger struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+}
+
+// NewTagger 創建標籤寫入步驟
+func NewTagger(cfg *config.Config, executor downloader.CommandExecutor) *Tagger {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	return &Tagger{config: cfg, executor: executor}
+}
+
+// Name 實現 Step 接口
+func (t *Tagger) Name() string {
+	return "寫入標籤"
+}
+
+// Process 為結果中的每個文件寫入標籤，沒有 info 時不處理
+func (t *Tagger) Process(ctx context.Context, result *downloader.Result) error {
+	if result.Info == nil {
+		return nil
+	}
+	url := result.URL
+	if result.Info.ExtractorKey == downloader.ExtractorLocal {
+		// 本地文件的路徑不寫入註釋
+		url = ""
+	}
+	tags := t.Tags(result.Info, url)
+	for _, path := range result.Files {
+		if err := t.write(ctx, path, tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tags 從 info 生成標籤，再依次應用配置的解析規則
+func (t *Tagger) Tags(info *downloader.VideoInfo, url string) Tags {
+	field := func(name string) string {
+		return fieldString(info.Fields[name])
+	}
+
+	tags := Tags{
+		"title":        info.Title,
+		"artist":       firstNonEmpty(field("artist"), artistFromChannel(field("uploader")), artistFromChannel(field("channel"))),
+		"album":        firstNonEmpty(field("album"), info.PlaylistTitle),
+		"album_artist": field("album_artist"),
+		"track":        "",
+		"date":         firstNonEmpty(field("release_year"), year(field("upload_date"))),
+		"genre":        firstNonEmpty(t.config.TagGenre, field("genre")),
+		"comment":      firstNonEmpty(field("webpage_url"), url),
+	}
+	if info.PlaylistIndex > 0 {
+		tags["track"] = strconv.Itoa(info.PlaylistIndex)
+		if total := field("n_entries"); total != "" {
+			tags["track"] += "/" + total
+		}
+	} else if track := field("track"); track != "" && tags["album"] != "" {
+		// YouTube Music 的 track 是歌名
+		tags["title"] = track
+	}
+
+	for _, rule := range t.config.TagRules {
+		re, err := rule.Regexp()
+		if err != nil {
+			continue
+		}
+		// 標籤名讀取當前的標籤值，前面規則的結果對後面的規則可見
+		source, isTag := tags[rule.Field]
+		if !isTag {
+			source = field(rule.Field)
+		}
+		m := re.FindStringSubmatch(source)
+		if m == nil {
+			continue
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" && m[i] != "" {
+				tags[name] = strings.TrimSpace(m[i])
+			}
+		}
+	}
+	return tags
+}
+
+// write 用 ffmpeg 複製音頻流並寫入標籤，先寫臨時文件再替換原文件
+func (t *Tagger) write(ctx context.Context, path string, tags Tags) error {
+	args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path,
+		"-map", "0", "-c", "copy", "-map_metadata", "0"}
+	for _, name := range config.TagNames {
+		if value := tags[name]; value != "" {
+			args = append(args, "-metadata", name+"="+value)
+		}
+	}
+	if strings.EqualFold(filepath.Ext(path), ".mp3") {
+		args = append(args, "-id3v2_version", "3")
+	}
+	_, err := rewrite(ctx, t.executor, t.config.FFmpeg(), path, "tagging", args)
+	return err
+}
+
+// fieldString 把 info JSON 中的值轉為字符串，整數不帶小數點
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// artistFromChannel YouTube 自動生成的音樂頻道名為 "Artist - Topic"
+func artistFromChannel(name string) string {
+	return strings.TrimSuffix(name, " - Topic")
+}
+
+// year 從 YYYYMMDD 格式的日期中取年份
+func year(date string) string {
+	if len(date) < 4 {
+		return ""
+	}
+	return date[:4]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
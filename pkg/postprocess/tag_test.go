@@ -0,0 +1,179 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+// loadInfo 讀取 testdata 中保存的 yt-dlp info JSON
+func loadInfo(t *testing.T, name string) *downloader.VideoInfo {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	info, err := downloader.ParseVideoInfo(data)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+	return info
+}
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		modify   func(cfg *config.Config)
+		expected Tags
+	}{
+		{
+			name:    "uploaded video",
+			fixture: "video.json",
+			expected: Tags{
+				"title":   "Rick Astley - Never Gonna Give You Up (Official Music Video)",
+				"artist":  "Rick Astley",
+				"date":    "2009",
+				"comment": "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			},
+		},
+		{
+			name:    "artist - title rule",
+			fixture: "video.json",
+			modify: func(cfg *config.Config) {
+				cfg.TagRules, _ = config.ParseTagRules(config.ArtistTitleRule + `;title:^(?P<title>.+?) \(Official Music Video\)$`)
+				cfg.TagGenre = "Pop"
+			},
+			expected: Tags{
+				"title":   "Never Gonna Give You Up",
+				"artist":  "Rick Astley",
+				"date":    "2009",
+				"genre":   "Pop",
+				"comment": "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+			},
+		},
+		{
+			name:    "youtube music",
+			fixture: "music.json",
+			expected: Tags{
+				"title":        "Never Gonna Give You Up",
+				"artist":       "Rick Astley",
+				"album":        "Whenever You Need Somebody",
+				"album_artist": "Rick Astley",
+				"date":         "1987",
+				"comment":      "https://www.youtube.com/watch?v=lYBUbBu4W08",
+			},
+		},
+		{
+			name:    "playlist item",
+			fixture: "playlist_item.json",
+			expected: Tags{
+				"title":   "Episode 3: Testing",
+				"artist":  "Podcaster",
+				"album":   "My Podcast",
+				"track":   "3/12",
+				"date":    "2024",
+				"genre":   "Education",
+				"comment": "https://www.youtube.com/watch?v=abcdefghijk",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			tags := NewTagger(cfg, nil).Tags(loadInfo(t, tt.fixture), "https://youtu.be/x")
+			for _, name := range config.TagNames {
+				if tags[name] != tt.expected[name] {
+					t.Errorf("Expected %s to be %q, got %q", name, tt.expected[name], tags[name])
+				}
+			}
+		})
+	}
+}
+
+func TestTaggerProcess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Song.mp3")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	mock := &mocks.CommandExecutor{
+		ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			return os.WriteFile(args[len(args)-1], []byte("tagged"), 0644)
+		},
+	}
+	result := &downloader.Result{URL: "https://youtu.be/x", Files: []string{path}, Info: loadInfo(t, "video.json")}
+	if err := NewTagger(config.NewConfig(), mock).Process(context.Background(), result); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	args := strings.Join(mock.LastArgs, " ")
+	for _, want := range []string{"-i " + path, "-c copy", "-metadata artist=Rick Astley", "-metadata date=2009", "-id3v2_version 3"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("Expected args to contain %q, got: %s", want, args)
+		}
+	}
+	if strings.Contains(args, "-metadata album=") {
+		t.Errorf("Expected empty tags to be omitted, got: %s", args)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "tagged" {
+		t.Errorf("Expected file to be replaced, got %q", data)
+	}
+
+	if mock.LastCommand != "ffmpeg" {
+		t.Errorf("Expected ffmpeg from PATH, got %q", mock.LastCommand)
+	}
+
+	t.Run("configured ffmpeg path", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.FFmpegPath = "/opt/tools/ffmpeg"
+		if err := NewTagger(cfg, mock).Process(context.Background(), result); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if mock.LastCommand != "/opt/tools/ffmpeg" {
+			t.Errorf("Expected configured ffmpeg path, got %q", mock.LastCommand)
+		}
+	})
+
+	t.Run("local file path is not written", func(t *testing.T) {
+		local := &downloader.Result{URL: "/home/user/Videos/Song.mkv", Files: []string{path},
+			Info: &downloader.VideoInfo{Title: "Song", ExtractorKey: downloader.ExtractorLocal}}
+		if err := NewTagger(config.NewConfig(), mock).Process(context.Background(), local); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		args := strings.Join(mock.LastArgs, " ")
+		if !strings.Contains(args, "-metadata title=Song") || strings.Contains(args, "comment=") {
+			t.Errorf("Expected title without comment, got: %s", args)
+		}
+	})
+
+	t.Run("ffmpeg failure keeps original", func(t *testing.T) {
+		mock.ExecuteFunc = func(name string, args []string, stdout, stderr io.Writer) error {
+			io.WriteString(stderr, "Invalid data found\n")
+			return errors.New("exit status 1")
+		}
+		err := NewTagger(config.NewConfig(), mock).Process(context.Background(), result)
+		if err == nil || !strings.Contains(err.Error(), "Invalid data found") {
+			t.Errorf("Expected ffmpeg error, got: %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "tagged" {
+			t.Errorf("Expected file to be kept, got %q", data)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(dir, ".*")); len(matches) != 0 {
+			t.Errorf("Expected temp file to be removed, got %v", matches)
+		}
+	})
+}
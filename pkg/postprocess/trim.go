@@ -0,0 +1,172 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// silenceTolerance 判斷靜音是否位於開頭或結尾時允許的誤差（秒）
+const silenceTolerance = 0.05
+
+var (
+	// 輸入信息中的時長，例如 "Duration: 00:03:21.52"
+	durationRe = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+	// silencedetect 濾鏡輸出的靜音區間
+	silenceRe = regexp.MustCompile(`silence_(start|end): (-?\d+(?:\.\d+)?)`)
+)
+
+// silence 一段靜音，End 為負數表示持續到文件結尾
+type silence struct {
+	Start, End float64
+}
+
+// Trimmer 用 ffmpeg 去掉開頭和結尾的靜音、限制時長並加入淡入淡出，按原格式重新編碼
+// 先用 silencedetect 測量靜音區間和時長，再一次性截取並淡入淡出
+type Trimmer struct {
+	config   *config.Config
+	executor downloader.CommandExecutor
+}
+
+// NewTrimmer 創建修剪步驟
+func NewTrimmer(cfg *config.Config, executor downloader.CommandExecutor) *Trimmer {
+	if executor == nil {
+		executor = &downloader.DefaultCommandExecutor{}
+	}
+	return &Trimmer{config: cfg, executor: executor}
+}
+
+// Name 實現 Step 接口
+func (t *Trimmer) Name() string {
+	return "修剪音頻"
+}
+
+// Process 修剪結果中的每個文件，不需要修改的文件保持不變
+func (t *Trimmer) Process(ctx context.Context, result *downloader.Result) error {
+	for i, path := range result.Files {
+		duration, silences, err := t.analyze(ctx, path)
+		if err != nil {
+			return err
+		}
+		start, end := t.bounds(duration, silences)
+		if end <= start {
+			result.Warn("%s: 音頻為靜音，未修剪", filepath.Base(path))
+			continue
+		}
+		filter := t.filter(start, end, duration)
+		if filter == "" {
+			continue
+		}
+
+		args := []string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y", "-i", path,
+			"-map", "0:a:0", "-map_metadata", "0", "-af", filter}
+		args = append(args, encodePreset(t.config, result, i).FFmpegArgs()...)
+		if strings.EqualFold(filepath.Ext(path), ".mp3") {
+			args = append(args, "-id3v2_version", "3")
+		}
+		if _, err := rewrite(ctx, t.executor, t.config.FFmpeg(), path, "trim", args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// analyze 解碼一遍音頻，返回時長，去掉靜音時同時返回檢測到的靜音區間
+func (t *Trimmer) analyze(ctx context.Context, path string) (float64, []silence, error) {
+	args := []string{"-hide_banner", "-nostdin", "-i", path, "-map", "0:a:0"}
+	if t.config.TrimSilence {
+		args = append(args, "-af", fmt.Sprintf("silencedetect=noise=%sdB:d=%s",
+			formatFloat(t.config.SilenceThreshold), formatSeconds(t.config.SilenceDuration.Seconds())))
+	}
+	args = append(args, "-f", "null", "-")
+
+	var stderr bytes.Buffer
+	if err := t.executor.ExecuteContext(ctx, t.config.FFmpeg(), args, io.Discard, &stderr); err != nil {
+		return 0, nil, fmt.Errorf("%s: 分析音頻失敗: %v", filepath.Base(path), err)
+	}
+	duration, ok := parseDuration(stderr.String())
+	if !ok {
+		return 0, nil, fmt.Errorf("%s: ffmpeg 沒有輸出音頻時長", filepath.Base(path))
+	}
+	return duration, parseSilences(stderr.String()), nil
+}
+
+// bounds 返回要保留的起止時間（秒）
+func (t *Trimmer) bounds(duration float64, silences []silence) (start, end float64) {
+	end = duration
+	if len(silences) > 0 && silences[0].Start <= silenceTolerance {
+		if silences[0].End < 0 {
+			// 整個文件都是靜音
+			return 0, 0
+		}
+		start = silences[0].End
+	}
+	if n := len(silences); n > 0 {
+		last := silences[n-1]
+		if last.Start > start && (last.End < 0 || last.End >= duration-silenceTolerance) {
+			end = last.Start
+		}
+	}
+	if limit := t.config.MaxDuration.Seconds(); limit > 0 && end-start > limit {
+		end = start + limit
+	}
+	return start, end
+}
+
+// filter 返回截取 start 到 end 並淡入淡出的 ffmpeg 濾鏡鏈，不需要修改時返回空字符串
+func (t *Trimmer) filter(start, end, duration float64) string {
+	var filters []string
+	if start > 0 || end < duration {
+		filters = append(filters, fmt.Sprintf("atrim=start=%s:end=%s", formatSeconds(round(start)), formatSeconds(round(end))),
+			"asetpts=PTS-STARTPTS")
+	}
+	length := end - start
+	if fade := t.config.FadeIn.Seconds(); fade > 0 {
+		filters = append(filters, "afade=t=in:st=0:d="+formatSeconds(math.Min(fade, round(length))))
+	}
+	if fade := t.config.FadeOut.Seconds(); fade > 0 {
+		fade = math.Min(fade, round(length))
+		filters = append(filters, fmt.Sprintf("afade=t=out:st=%s:d=%s", formatSeconds(round(length-fade)), formatSeconds(fade)))
+	}
+	return strings.Join(filters, ",")
+}
+
+// parseDuration 解析 ffmpeg 輸入信息中的時長
+func parseDuration(output string) (float64, bool) {
+	m := durationRe.FindStringSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	return float64(hours*3600+minutes*60) + seconds, true
+}
+
+// parseSilences 解析 silencedetect 輸出的靜音區間，持續到結尾的靜音可能沒有 silence_end
+func parseSilences(output string) []silence {
+	var silences []silence
+	for _, m := range silenceRe.FindAllStringSubmatch(output, -1) {
+		value, _ := strconv.ParseFloat(m[2], 64)
+		if m[1] == "start" {
+			silences = append(silences, silence{Start: math.Max(value, 0), End: -1})
+		} else if len(silences) > 0 {
+			silences[len(silences)-1].End = value
+		}
+	}
+	return silences
+}
+
+// round 保留到毫秒，避免浮點誤差出現在濾鏡參數中
+func round(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
@@ -0,0 +1,161 @@
+package postprocess
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/test/mocks"
+)
+
+// fakeSilencedetect 分析時輸出時長和靜音區間，其餘調用寫出輸出文件
+func fakeSilencedetect(output string, calls *[]string) *mocks.CommandExecutor {
+	return &mocks.CommandExecutor{
+		ExecuteFunc: func(name string, args []string, stdout, stderr io.Writer) error {
+			joined := strings.Join(args, " ")
+			*calls = append(*calls, joined)
+			if strings.HasSuffix(joined, "-f null -") {
+				io.WriteString(stderr, "Input #0, mp3, from 'Song.mp3':\n  Duration: 00:00:07.00, start: 0.000000, bitrate: 320 kb/s\n"+output)
+				return nil
+			}
+			return os.WriteFile(args[len(args)-1], []byte("trimmed"), 0644)
+		},
+	}
+}
+
+// 開頭 2 秒和結尾 2 秒是靜音
+const silenceBothEnds = `[silencedetect @ 0x1] silence_start: -0.0000
+[silencedetect @ 0x1] silence_end: 2.012 | silence_duration: 2.012
+[silencedetect @ 0x1] silence_start: 4.987
+[silencedetect @ 0x1] silence_end: 7 | silence_duration: 2.013
+`
+
+func TestTrimmer(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(cfg *config.Config)
+		output   string
+		analyze  string
+		filter   string
+		warnings int
+	}{
+		{
+			name:    "trim silence",
+			modify:  func(cfg *config.Config) { cfg.TrimSilence = true },
+			output:  silenceBothEnds,
+			analyze: "-map 0:a:0 -af silencedetect=noise=-50dB:d=0.5 -f null -",
+			filter:  "atrim=start=2.012:end=4.987,asetpts=PTS-STARTPTS",
+		},
+		{
+			name: "custom threshold and trailing silence without end",
+			modify: func(cfg *config.Config) {
+				cfg.TrimSilence = true
+				cfg.SilenceThreshold = -35.5
+				cfg.SilenceDuration = 2 * time.Second
+			},
+			output:  "[silencedetect @ 0x1] silence_start: 5.5\n",
+			analyze: "silencedetect=noise=-35.5dB:d=2 -f null -",
+			filter:  "atrim=start=0:end=5.5,asetpts=PTS-STARTPTS",
+		},
+		{
+			name:   "silence in the middle is kept",
+			modify: func(cfg *config.Config) { cfg.TrimSilence = true },
+			output: "[silencedetect @ 0x1] silence_start: 3\n[silencedetect @ 0x1] silence_end: 4 | silence_duration: 1\n",
+		},
+		{
+			name:    "fades",
+			modify:  func(cfg *config.Config) { cfg.FadeIn = 1500 * time.Millisecond; cfg.FadeOut = 3 * time.Second },
+			analyze: "-map 0:a:0 -f null -",
+			filter:  "afade=t=in:st=0:d=1.5,afade=t=out:st=4:d=3",
+		},
+		{
+			name:   "max duration",
+			modify: func(cfg *config.Config) { cfg.MaxDuration = 5 * time.Second },
+			filter: "atrim=start=0:end=5,asetpts=PTS-STARTPTS",
+		},
+		{
+			name:   "shorter than max duration",
+			modify: func(cfg *config.Config) { cfg.MaxDuration = time.Minute },
+		},
+		{
+			name: "all",
+			modify: func(cfg *config.Config) {
+				cfg.TrimSilence = true
+				cfg.MaxDuration = 2 * time.Second
+				cfg.FadeIn = time.Second
+				cfg.FadeOut = 5 * time.Second
+			},
+			output: silenceBothEnds,
+			filter: "atrim=start=2.012:end=4.012,asetpts=PTS-STARTPTS,afade=t=in:st=0:d=1,afade=t=out:st=0:d=2",
+		},
+		{
+			name:     "silence is skipped with a warning",
+			modify:   func(cfg *config.Config) { cfg.TrimSilence = true },
+			output:   "[silencedetect @ 0x1] silence_start: 0\n",
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Song.mp3")
+			if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			cfg := config.NewConfig()
+			tt.modify(cfg)
+			var calls []string
+			result := &downloader.Result{Files: []string{path}}
+
+			if err := NewTrimmer(cfg, fakeSilencedetect(tt.output, &calls)).Process(context.Background(), result); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if !strings.Contains(calls[0], tt.analyze) {
+				t.Errorf("Expected analysis to contain %q, got: %s", tt.analyze, calls[0])
+			}
+			if tt.filter == "" {
+				if len(calls) != 1 {
+					t.Errorf("Expected file to be left unchanged, got calls: %v", calls)
+				}
+			} else {
+				want := "-map 0:a:0 -map_metadata 0 -af " + tt.filter + " -c:a libmp3lame -b:a 320k -id3v2_version 3"
+				if len(calls) != 2 || !strings.Contains(calls[1], want) {
+					t.Errorf("Expected call to contain %q, got: %v", want, calls)
+				}
+			}
+			if len(result.Warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, result.Warnings)
+			}
+		})
+	}
+
+	t.Run("preset of each output", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "Song.flac")
+		cfg := config.NewConfig()
+		cfg.MaxDuration = time.Second
+		var calls []string
+		result := &downloader.Result{Files: []string{path}, Outputs: []downloader.OutputFile{{Preset: "flac"}}}
+		if err := NewTrimmer(cfg, fakeSilencedetect("", &calls)).Process(context.Background(), result); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(calls) != 2 || !strings.HasSuffix(strings.TrimSuffix(calls[1], " "+filepath.Join(filepath.Dir(path), ".Song.trim.flac")), "-c:a flac") {
+			t.Errorf("Expected flac encoding, got: %v", calls)
+		}
+	})
+
+	t.Run("missing duration", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.FadeIn = time.Second
+		err := NewTrimmer(cfg, &mocks.CommandExecutor{}).Process(context.Background(), &downloader.Result{Files: []string{"Song.mp3"}})
+		if err == nil || !strings.Contains(err.Error(), "沒有輸出音頻時長") {
+			t.Errorf("Expected duration error, got: %v", err)
+		}
+	})
+}
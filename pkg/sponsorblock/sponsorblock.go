@@ -0,0 +1,87 @@
+package sponsorblock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// maxResponseSize API 響應的最大字節數
+const maxResponseSize = 4 << 20
+
+// Segment 一個需要跳過的片段，時間以秒為單位
+type Segment struct {
+	Category string
+	Start    float64
+	End      float64
+}
+
+// apiSegment /api/skipSegments 返回的片段
+type apiSegment struct {
+	Category   string    `json:"category"`
+	ActionType string    `json:"actionType"`
+	Segment    []float64 `json:"segment"`
+}
+
+// Client SponsorBlock API 客戶端
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient 創建 API 客戶端，baseURL 例如 "https://sponsor.ajay.app"，httpClient 為 nil 時使用 http.DefaultClient
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), client: httpClient}
+}
+
+// Segments 查詢視頻中指定類別的跳過片段，按開始時間排序，沒有提交過片段的視頻返回空列表
+func (c *Client) Segments(ctx context.Context, videoID string, categories []string) ([]Segment, error) {
+	encoded, err := json.Marshal(categories)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("videoID", videoID)
+	query.Set("categories", string(encoded))
+	query.Set("actionType", "skip")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/skipSegments?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// API 對沒有片段的視頻返回 404
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	var raw []apiSegment
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("解析 SponsorBlock 響應失敗: %v", err)
+	}
+	var segments []Segment
+	for _, s := range raw {
+		if len(s.Segment) != 2 || s.Segment[1] <= s.Segment[0] || (s.ActionType != "" && s.ActionType != "skip") {
+			continue
+		}
+		segments = append(segments, Segment{Category: s.Category, Start: s.Segment[0], End: s.Segment[1]})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	return segments, nil
+}
@@ -0,0 +1,74 @@
+package sponsorblock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/skipSegments" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		switch r.URL.Query().Get("videoID") {
+		case "dQw4w9WgXcQ":
+			fmt.Fprint(w, `[
+				{"category": "selfpromo", "actionType": "skip", "segment": [200.5, 210], "UUID": "b"},
+				{"category": "sponsor", "actionType": "skip", "segment": [15.2, 62.8], "UUID": "a"},
+				{"category": "sponsor", "actionType": "mute", "segment": [80, 90], "UUID": "c"},
+				{"category": "intro", "actionType": "skip", "segment": [5, 5], "UUID": "d"}
+			]`)
+		case "invalidjson":
+			fmt.Fprint(w, `{"error"`)
+		case "servererror":
+			http.Error(w, "database down", http.StatusInternalServerError)
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", server.Client())
+
+	segments, err := client.Segments(context.Background(), "dQw4w9WgXcQ", []string{"sponsor", "selfpromo"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := fmt.Sprint(segments); got != "[{sponsor 15.2 62.8} {selfpromo 200.5 210}]" {
+		t.Errorf("Unexpected segments: %s", got)
+	}
+	for _, want := range []string{"videoID=dQw4w9WgXcQ", "actionType=skip", `categories=%5B%22sponsor%22%2C%22selfpromo%22%5D`} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %q, got: %s", want, query)
+		}
+	}
+
+	t.Run("no segments", func(t *testing.T) {
+		segments, err := client.Segments(context.Background(), "aqz-KE-bpKQ", []string{"sponsor"})
+		if err != nil || len(segments) != 0 {
+			t.Errorf("Expected no segments, got %v (err: %v)", segments, err)
+		}
+	})
+
+	errorTests := []struct {
+		id   string
+		want string
+	}{
+		{"servererror", "500"},
+		{"invalidjson", "解析 SponsorBlock 響應失敗"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.id, func(t *testing.T) {
+			if _, err := client.Segments(context.Background(), tt.id, []string{"sponsor"}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// Requirement 配置需要 ffmpeg 支持的編碼器或濾鏡
+type Requirement struct {
+	Kind   string // "編碼器" 或 "濾鏡"
+	Name   string // ffmpeg 中的名稱，例如 libmp3lame、loudnorm
+	Reason string // 需要它的配置項，例如 audio_format = mp3
+}
+
+// Requirement.Kind 的取值
+const (
+	KindEncoder = "編碼器"
+	KindFilter  = "濾鏡"
+)
+
+// String 返回 "編碼器 libopus（audio_format = opus 需要）" 形式的描述
+func (r Requirement) String() string {
+	return fmt.Sprintf("%s %s（%s 需要）", r.Kind, r.Name, r.Reason)
+}
+
+// Filters 後處理步驟使用的 ffmpeg 濾鏡
+var Filters = []string{"aselect", "asetpts", "silencedetect", "atrim", "afade", "loudnorm", "crop", "scale"}
+
+// Requirements 返回配置的音頻格式和後處理步驟需要的 ffmpeg 編碼器和濾鏡，按名稱去重
+func Requirements(cfg *config.Config) []Requirement {
+	var reqs []Requirement
+	seen := make(map[string]bool)
+	add := func(kind, name, reason string) {
+		if name == "" || seen[kind+name] {
+			return
+		}
+		seen[kind+name] = true
+		reqs = append(reqs, Requirement{Kind: kind, Name: name, Reason: reason})
+	}
+
+	// 多格式輸出時只轉碼為各個預設的格式，不使用 audio_format
+	if len(cfg.Outputs) > 0 {
+		for _, o := range cfg.Outputs {
+			if p, ok := config.LookupPreset(o.Preset); ok {
+				add(KindEncoder, p.Encoder(), "outputs = "+o.Preset)
+			}
+		}
+	} else {
+		add(KindEncoder, config.Preset{AudioFormat: cfg.AudioFormat}.Encoder(), "audio_format = "+cfg.AudioFormat)
+	}
+
+	if len(cfg.SponsorBlock) > 0 {
+		add(KindFilter, "aselect", "sponsorblock")
+		add(KindFilter, "asetpts", "sponsorblock")
+	}
+	if cfg.TrimSilence {
+		add(KindFilter, "silencedetect", "trim_silence")
+	}
+	if cfg.TrimSilence || cfg.MaxDuration > 0 {
+		reason := "trim_silence"
+		if !cfg.TrimSilence {
+			reason = "max_duration"
+		}
+		add(KindFilter, "atrim", reason)
+		add(KindFilter, "asetpts", reason)
+	}
+	if cfg.FadeIn > 0 || cfg.FadeOut > 0 {
+		add(KindFilter, "afade", "fade_in/fade_out")
+	}
+	if cfg.Loudness != "" {
+		// replaygain 模式也用 loudnorm 測量響度
+		add(KindFilter, "loudnorm", "loudness = "+cfg.Loudness)
+	}
+	if cfg.Cover {
+		if cfg.CoverSquare {
+			add(KindFilter, "crop", "cover_square")
+		}
+		if cfg.CoverSize > 0 {
+			add(KindFilter, "scale", "cover_size")
+		}
+		if cfg.CoverJPEG {
+			add(KindEncoder, "mjpeg", "cover_jpeg")
+		}
+	}
+	return reqs
+}
+
+// Capabilities ffmpeg 支持的編碼器和濾鏡
+type Capabilities struct {
+	Encoders map[string]bool
+	Filters  map[string]bool
+}
+
+// Has 判斷是否支持要求的編碼器或濾鏡
+func (c *Capabilities) Has(r Requirement) bool {
+	if r.Kind == KindEncoder {
+		return c.Encoders[r.Name]
+	}
+	return c.Filters[r.Name]
+}
+
+// Capabilities 運行 ffmpeg -encoders 和 -filters 查詢支持的編碼器和濾鏡
+func (v *SystemValidator) Capabilities() (*Capabilities, error) {
+	encoders, err := v.checker.Output(config.FFmpegCommand, "-hide_banner", "-encoders")
+	if err != nil {
+		return nil, fmt.Errorf("無法查詢 ffmpeg 支持的%s: %v", KindEncoder, err)
+	}
+	filters, err := v.checker.Output(config.FFmpegCommand, "-hide_banner", "-filters")
+	if err != nil {
+		return nil, fmt.Errorf("無法查詢 ffmpeg 支持的%s: %v", KindFilter, err)
+	}
+	return &Capabilities{Encoders: ParseEncoders(encoders), Filters: ParseFilters(filters)}, nil
+}
+
+// ValidateCapabilities 查詢 ffmpeg 支持的編碼器和濾鏡，確認配置需要的都可用，
+// 缺少時返回的錯誤列出每個缺少的編碼器或濾鏡及需要它的配置項
+func (v *SystemValidator) ValidateCapabilities(cfg *config.Config) error {
+	reqs := Requirements(cfg)
+	if len(reqs) == 0 {
+		return nil
+	}
+	caps, err := v.Capabilities()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, r := range reqs {
+		if !caps.Has(r) {
+			missing = append(missing, r.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("ffmpeg 缺少以下功能，請安裝完整版本的 ffmpeg 或修改配置:\n  %s", strings.Join(missing, "\n  "))
+	}
+	return nil
+}
+
+// ParseEncoders 解析 ffmpeg -encoders 的輸出，返回編碼器名稱的集合
+// 說明部分以 " ------" 結束，之後每行為 "A....D libmp3lame  說明"
+func ParseEncoders(output string) map[string]bool {
+	encoders := make(map[string]bool)
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// ParseFilters 解析 ffmpeg -filters 的輸出，返回濾鏡名稱的集合
+// 每行為 " TSC loudnorm  A->A  說明"，第三列為輸入輸出類型
+func ParseFilters(output string) map[string]bool {
+	filters := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			filters[fields[1]] = true
+		}
+	}
+	return filters
+}
@@ -0,0 +1,165 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+const encodersOutput = `Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ .F.... = Frame-level multithreading
+ ..S... = Slice-level multithreading
+ ...X.. = Codec is experimental
+ ....B. = Supports draw_horiz_band
+ .....D = Supports direct rendering method 1
+ ------
+ V....D mjpeg                MJPEG (Motion JPEG)
+ A....D aac                  AAC (Advanced Audio Coding)
+ A....D flac                 FLAC (Free Lossless Audio Codec)
+ A....D libmp3lame           libmp3lame MP3 (MPEG audio layer 3) (codec mp3)
+`
+
+const filtersOutput = `Filters:
+  T.. = Timeline support
+  .S. = Slice threading
+  ..C = Command support
+  A = Audio input/output
+  V = Video input/output
+  N = Dynamic number and/or type of input/output
+  | = Source or sink filter
+ ... afade             A->A       Fade in/out input audio.
+ T.. aselect           A->N       Select audio frames to pass in output.
+ ... asetpts           A->A       Set PTS for the output audio frame.
+ ... atrim             A->A       Pick one continuous section from the input, drop the rest.
+ ... silencedetect     A->A       Detect silence.
+ ..C scale             V->V       Scale the input video size and/or convert the image format.
+ ... anullsrc          |->A       Null audio source, return empty audio frames.
+`
+
+func TestParseEncoders(t *testing.T) {
+	encoders := ParseEncoders(encodersOutput)
+	for _, name := range []string{"mjpeg", "aac", "flac", "libmp3lame"} {
+		if !encoders[name] {
+			t.Errorf("Expected encoder %s", name)
+		}
+	}
+	// 說明部分的標記不是編碼器
+	for _, name := range []string{"=", "Video", "libopus"} {
+		if encoders[name] {
+			t.Errorf("Expected no encoder %s", name)
+		}
+	}
+	if len(encoders) != 4 {
+		t.Errorf("Expected 4 encoders, got %v", encoders)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	filters := ParseFilters(filtersOutput)
+	want := map[string]bool{"afade": true, "aselect": true, "asetpts": true, "atrim": true,
+		"silencedetect": true, "scale": true, "anullsrc": true}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("Expected %v, got %v", want, filters)
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	names := func(reqs []Requirement) []string {
+		var out []string
+		for _, r := range reqs {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *config.Config)
+		want   []string
+	}{
+		{"default mp3", func(c *config.Config) {}, []string{"libmp3lame"}},
+		{"best keeps source codec", func(c *config.Config) { c.AudioFormat = "best" }, nil},
+		{"outputs replace audio format", func(c *config.Config) {
+			c.Outputs = []config.Output{{Preset: "opus-160"}, {Preset: "flac"}, {Preset: "opus-160", Path: "copy"}}
+		}, []string{"libopus", "flac"}},
+		{"post-processing filters", func(c *config.Config) {
+			c.SponsorBlock = []string{"sponsor"}
+			c.TrimSilence = true
+			c.FadeOut = time.Second
+			c.Loudness = "replaygain"
+		}, []string{"libmp3lame", "aselect", "asetpts", "silencedetect", "atrim", "afade", "loudnorm"}},
+		{"max duration without trimming", func(c *config.Config) { c.MaxDuration = time.Minute }, []string{"libmp3lame", "atrim", "asetpts"}},
+		{"cover", func(c *config.Config) {
+			c.Cover = true
+			c.CoverSquare = true
+			c.CoverSize = 500
+		}, []string{"libmp3lame", "crop", "scale", "mjpeg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			tt.modify(cfg)
+			if got := names(Requirements(cfg)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	newValidator := func() *SystemValidator {
+		mock := NewMockCommandChecker()
+		mock.SetOutput("ffmpeg -hide_banner -encoders", encodersOutput)
+		mock.SetOutput("ffmpeg -hide_banner -filters", filtersOutput)
+		return NewSystemValidator(mock)
+	}
+
+	t.Run("supported", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.TrimSilence = true
+		cfg.FadeIn = time.Second
+		if err := newValidator().ValidateCapabilities(cfg); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("missing encoder and filter", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.AudioFormat = "opus"
+		cfg.Bitrate = "160k"
+		cfg.Loudness = "loudnorm"
+		err := newValidator().ValidateCapabilities(cfg)
+		if err == nil {
+			t.Fatal("Expected error for missing capabilities")
+		}
+		for _, want := range []string{"編碼器 libopus（audio_format = opus 需要）", "濾鏡 loudnorm（loudness = loudnorm 需要）"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %q, got: %v", want, err)
+			}
+		}
+	})
+
+	t.Run("query fails", func(t *testing.T) {
+		validator := NewSystemValidator(NewMockCommandChecker())
+		err := validator.ValidateCapabilities(config.NewConfig())
+		if err == nil || !strings.Contains(err.Error(), "無法查詢 ffmpeg") {
+			t.Errorf("Expected query error, got: %v", err)
+		}
+	})
+
+	t.Run("nothing required", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.AudioFormat = "best"
+		validator := NewSystemValidator(NewMockCommandChecker())
+		if err := validator.ValidateCapabilities(cfg); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+}
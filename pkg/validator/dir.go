@@ -0,0 +1,82 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DirStatus 目錄的可寫性和所在分區的可用空間
+type DirStatus struct {
+	Path     string // 絕對路徑
+	Exists   bool
+	Writable bool
+	Free     uint64 // 可用空間（字節），無法獲取時為 0
+	Err      error  // 不可寫或無法獲取可用空間的原因
+}
+
+// CheckDir 檢查目錄是否可寫並獲取可用空間
+// 目錄不存在時檢查最近的已存在的上級目錄，下載時會自動創建輸出目錄
+func CheckDir(dir string) DirStatus {
+	status := DirStatus{Path: dir}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.Path = abs
+
+	existing := abs
+	for {
+		fi, err := os.Stat(existing)
+		if err == nil {
+			if !fi.IsDir() {
+				status.Err = fmt.Errorf("%s 不是目錄", existing)
+				return status
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		// 上級是文件時 stat 返回 ENOTDIR，繼續向上找到這個文件
+		if (!os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR)) || parent == existing {
+			status.Err = err
+			return status
+		}
+		existing = parent
+	}
+	status.Exists = existing == abs
+
+	// 實際創建文件，只看權限位無法反映只讀掛載和 ACL
+	file, err := os.CreateTemp(existing, ".yt2mp3-write-test-*")
+	if err != nil {
+		status.Err = fmt.Errorf("無法寫入 %s: %v", existing, err)
+		return status
+	}
+	file.Close()
+	os.Remove(file.Name())
+	status.Writable = true
+
+	free, err := freeSpace(existing)
+	if err != nil {
+		status.Err = fmt.Errorf("無法獲取 %s 的可用空間: %v", existing, err)
+		return status
+	}
+	status.Free = free
+	return status
+}
+
+// FormatBytes 把字節數格式化為 KB、MB、GB 等
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,78 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("existing directory", func(t *testing.T) {
+		status := CheckDir(dir)
+		if status.Err != nil {
+			t.Fatalf("Expected no error, got: %v", status.Err)
+		}
+		if !status.Exists || !status.Writable || status.Free == 0 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("Expected write test file to be removed, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("missing directory checks parent", func(t *testing.T) {
+		status := CheckDir(filepath.Join(dir, "a", "b"))
+		if status.Err != nil {
+			t.Fatalf("Expected no error, got: %v", status.Err)
+		}
+		if status.Exists || !status.Writable || status.Path != filepath.Join(dir, "a", "b") {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		file := filepath.Join(dir, "file.txt")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		status := CheckDir(filepath.Join(file, "sub"))
+		if status.Err == nil || !strings.Contains(status.Err.Error(), "不是目錄") || status.Writable {
+			t.Errorf("Expected not a directory error, got %+v", status)
+		}
+	})
+
+	t.Run("read-only directory", func(t *testing.T) {
+		if os.Getuid() == 0 {
+			t.Skip("root can write to read-only directories")
+		}
+		readOnly := filepath.Join(dir, "readonly")
+		if err := os.Mkdir(readOnly, 0555); err != nil {
+			t.Fatal(err)
+		}
+		status := CheckDir(readOnly)
+		if status.Writable || status.Err == nil {
+			t.Errorf("Expected directory to be read-only, got %+v", status)
+		}
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KB"},
+		{10 * 1024 * 1024, "10.0 MB"},
+		{85220003840, "79.4 GB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.want {
+			t.Errorf("FormatBytes(%d): expected %q, got %q", tt.n, tt.want, got)
+		}
+	}
+}
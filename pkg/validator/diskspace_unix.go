@@ -0,0 +1,14 @@
+//go:build !windows
+
+package validator
+
+import "syscall"
+
+// freeSpace 返回 path 所在分區中非特權用戶可用的空間（字節）
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
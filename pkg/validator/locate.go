@@ -0,0 +1,142 @@
+package validator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// ToolEnv 指定外部命令路徑的環境變量，FFMPEG_PATH 和 FFPROBE_PATH 與其他工具的習慣一致
+var ToolEnv = map[string]string{
+	config.YtDlpCommand:   "YTDLP_PATH",
+	config.FFmpegCommand:  "FFMPEG_PATH",
+	config.FFprobeCommand: "FFPROBE_PATH",
+}
+
+// ToolsDirName 程序所在目錄下存放自帶 yt-dlp、ffmpeg 的子目錄名
+const ToolsDirName = "tools"
+
+// 命令的來源，見 CommandInfo.Source
+const (
+	SourceConfig = "config"
+	SourceEnv    = "env"
+	SourceTools  = "tools"
+	SourcePath   = "PATH"
+)
+
+// InvalidPathError 配置或環境變量指定的命令路徑無效
+type InvalidPathError struct {
+	Name   string // 命令名
+	Source string // 指定路徑的配置項或環境變量
+	Path   string
+	Err    error
+}
+
+// Error 實現 error 接口
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("%s 指定的 %s 路徑無效: %v", e.Source, e.Name, e.Err)
+}
+
+// Unwrap 返回查找失敗的原因
+func (e *InvalidPathError) Unwrap() error {
+	return e.Err
+}
+
+// Locator 查找外部命令，順序為: 配置指定的路徑、ToolEnv 中的環境變量、
+// 程序所在目錄下的 tools 目錄、PATH。配置或環境變量指定的路徑無效時直接報錯，不繼續查找
+type Locator struct {
+	Paths    map[string]string   // 命令名到配置中指定的路徑，路徑可以是文件或所在目錄
+	Getenv   func(string) string // 讀取環境變量，默認為 os.Getenv
+	ToolsDir string              // 自帶工具的目錄，默認為程序所在目錄下的 tools
+}
+
+// NewLocator 創建使用配置中的 yt-dlp、ffmpeg、ffprobe 路徑的查找器
+func NewLocator(cfg *config.Config) *Locator {
+	return &Locator{Paths: map[string]string{
+		config.YtDlpCommand:   cfg.YtDlpPath,
+		config.FFmpegCommand:  cfg.FFmpegPath,
+		config.FFprobeCommand: cfg.FFprobePath,
+	}}
+}
+
+// configKeys 指定命令路徑的配置項
+var configKeys = map[string]string{
+	config.YtDlpCommand:   "ytdlp_path",
+	config.FFmpegCommand:  "ffmpeg_path",
+	config.FFprobeCommand: "ffprobe_path",
+}
+
+// Find 返回命令的完整路徑和來源，來源為 SourceConfig、"env 變量名"、SourceTools 或 SourcePath
+func (l *Locator) Find(name string) (path, source string, err error) {
+	if p := l.Paths[name]; p != "" {
+		path, err := lookIn(p, name)
+		if err != nil {
+			return "", SourceConfig, &InvalidPathError{Name: name, Source: "配置項 " + configKeys[name], Path: p, Err: err}
+		}
+		return path, SourceConfig, nil
+	}
+
+	getenv := l.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if env := ToolEnv[name]; env != "" {
+		if p := getenv(env); p != "" {
+			path, err := lookIn(p, name)
+			if err != nil {
+				return "", SourceEnv + " " + env, &InvalidPathError{Name: name, Source: "環境變量 " + env, Path: p, Err: err}
+			}
+			return path, SourceEnv + " " + env, nil
+		}
+	}
+
+	if dir := l.toolsDir(); dir != "" {
+		if path, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return absPath(path), SourceTools, nil
+		}
+	}
+
+	path, err = exec.LookPath(name)
+	if err != nil {
+		return "", "", err
+	}
+	return absPath(path), SourcePath, nil
+}
+
+// toolsDir 返回自帶工具的目錄，無法確定程序路徑時返回空字符串
+func (l *Locator) toolsDir() string {
+	if l.ToolsDir != "" {
+		return l.ToolsDir
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Join(filepath.Dir(exe), ToolsDirName)
+}
+
+// lookIn 查找指定的可執行文件，path 為目錄時在其中查找 name
+func lookIn(path, name string) (string, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, name)
+	}
+	found, err := exec.LookPath(path)
+	if err != nil {
+		return "", err
+	}
+	return absPath(found), nil
+}
+
+// absPath 返回絕對路徑，失敗時原樣返回
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
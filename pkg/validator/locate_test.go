@@ -0,0 +1,151 @@
+package validator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// writeTool 在 dir 中創建名為 name 的可執行文件
+func writeTool(t *testing.T, dir, name string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLocatorFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("可執行文件需要 .exe 擴展名")
+	}
+
+	root := t.TempDir()
+	configDir := filepath.Join(root, "config")
+	envDir := filepath.Join(root, "env")
+	toolsDir := filepath.Join(root, "tools")
+	pathDir := filepath.Join(root, "path")
+	configTool := writeTool(t, configDir, "ffmpeg")
+	envTool := writeTool(t, envDir, "ffmpeg")
+	toolsTool := writeTool(t, toolsDir, "ffmpeg")
+	pathTool := writeTool(t, pathDir, "ffmpeg")
+	t.Setenv("PATH", pathDir)
+
+	tests := []struct {
+		name           string
+		paths          map[string]string
+		env            map[string]string
+		toolsDir       string
+		expectedPath   string
+		expectedSource string
+	}{
+		{
+			name:           "config file",
+			paths:          map[string]string{"ffmpeg": configTool},
+			env:            map[string]string{"FFMPEG_PATH": envTool},
+			toolsDir:       toolsDir,
+			expectedPath:   configTool,
+			expectedSource: SourceConfig,
+		},
+		{
+			name:           "config directory",
+			paths:          map[string]string{"ffmpeg": configDir},
+			toolsDir:       toolsDir,
+			expectedPath:   configTool,
+			expectedSource: SourceConfig,
+		},
+		{
+			name:           "env",
+			env:            map[string]string{"FFMPEG_PATH": envTool},
+			toolsDir:       toolsDir,
+			expectedPath:   envTool,
+			expectedSource: "env FFMPEG_PATH",
+		},
+		{
+			name:           "tools directory",
+			toolsDir:       toolsDir,
+			expectedPath:   toolsTool,
+			expectedSource: SourceTools,
+		},
+		{
+			name:           "PATH",
+			toolsDir:       filepath.Join(root, "missing"),
+			expectedPath:   pathTool,
+			expectedSource: SourcePath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Locator{
+				Paths:    tt.paths,
+				Getenv:   func(key string) string { return tt.env[key] },
+				ToolsDir: tt.toolsDir,
+			}
+			path, source, err := l.Find("ffmpeg")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if path != tt.expectedPath {
+				t.Errorf("Expected path %q, got %q", tt.expectedPath, path)
+			}
+			if source != tt.expectedSource {
+				t.Errorf("Expected source %q, got %q", tt.expectedSource, source)
+			}
+		})
+	}
+}
+
+func TestLocatorFindInvalidPath(t *testing.T) {
+	root := t.TempDir()
+	writeTool(t, filepath.Join(root, "tools"), "ffmpeg")
+	missing := filepath.Join(root, "missing", "ffmpeg")
+
+	tests := []struct {
+		name           string
+		paths          map[string]string
+		env            map[string]string
+		expectedSource string
+	}{
+		{"config", map[string]string{"ffmpeg": missing}, nil, "配置項 ffmpeg_path"},
+		{"env", nil, map[string]string{"FFMPEG_PATH": missing}, "環境變量 FFMPEG_PATH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Locator{
+				Paths:    tt.paths,
+				Getenv:   func(key string) string { return tt.env[key] },
+				ToolsDir: filepath.Join(root, "tools"),
+			}
+			// 指定的路徑無效時不回退到 tools 目錄
+			_, _, err := l.Find("ffmpeg")
+			var invalid *InvalidPathError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Expected InvalidPathError, got: %v", err)
+			}
+			if invalid.Source != tt.expectedSource || invalid.Path != missing {
+				t.Errorf("Unexpected error: %+v", invalid)
+			}
+		})
+	}
+}
+
+func TestNewLocator(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.YtDlpPath = "/opt/yt-dlp"
+	cfg.FFmpegPath = "/opt/ffmpeg/bin"
+
+	l := NewLocator(cfg)
+	if l.Paths["yt-dlp"] != "/opt/yt-dlp" || l.Paths["ffmpeg"] != "/opt/ffmpeg/bin" || l.Paths["ffprobe"] != "" {
+		t.Errorf("Unexpected paths: %v", l.Paths)
+	}
+}
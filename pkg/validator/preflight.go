@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+// sourceBitrate YouTube 音頻流的典型比特率（kbps），轉碼後的大小按目標比特率與它的比例估算
+const sourceBitrate = 128
+
+// losslessBitrates 無損格式的近似比特率（kbps），flac 和 alac 按 CD 音質壓縮到六成左右估算
+var losslessBitrates = map[string]float64{"wav": 1411, "flac": 850, "alac": 850}
+
+// vbrBitrate VBR 輸出的近似比特率（kbps），按最高質量估算
+const vbrBitrate = 256
+
+// ConversionFactor 返回轉碼為預設的格式後文件大小與下載的音頻大小之比
+// best 只提取音頻不轉碼，大小不變
+func ConversionFactor(p config.Preset) float64 {
+	if p.AudioFormat == "best" {
+		return 1
+	}
+	if kbps, ok := losslessBitrates[p.AudioFormat]; ok {
+		return kbps / sourceBitrate
+	}
+	if kbps, err := strconv.Atoi(strings.TrimSuffix(p.Bitrate, "k")); err == nil && kbps > 0 {
+		return float64(kbps) / sourceBitrate
+	}
+	return float64(vbrBitrate) / sourceBitrate
+}
+
+// EstimateSize 根據 yt-dlp 的視頻信息估算下載和轉碼需要的空間，信息中沒有大小時返回 0
+// 轉碼完成前下載的音頻仍在磁盤上，所以需要的空間為 filesize_approx ×（1 + 各輸出格式的轉換係數之和）
+func EstimateSize(cfg *config.Config, fields map[string]interface{}) int64 {
+	source := sizeField(fields, "filesize")
+	if source == 0 {
+		source = sizeField(fields, "filesize_approx")
+	}
+	if source == 0 {
+		return 0
+	}
+
+	factor := 1.0
+	if len(cfg.Outputs) > 0 {
+		for _, o := range cfg.Outputs {
+			if p, ok := config.LookupPreset(o.Preset); ok {
+				factor += ConversionFactor(p)
+			}
+		}
+	} else {
+		factor += ConversionFactor(config.Preset{AudioFormat: cfg.AudioFormat, Bitrate: cfg.Bitrate})
+	}
+	return int64(source * factor)
+}
+
+// sizeField 讀取 info JSON 中的字節數，沒有或不是數字時返回 0
+func sizeField(fields map[string]interface{}, name string) float64 {
+	if n, ok := fields[name].(float64); ok && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Preflight 下載前檢查輸出目錄是否可以創建和寫入，以及可用空間在下載預計需要的 required 字節之後
+// 是否還剩 cfg.MinFreeSpace。目錄不可寫時返回錯誤；空間不足時按 cfg.SpaceCheck 返回錯誤或警告
+// required 為 0 表示無法估算，只檢查保留空間
+func Preflight(cfg *config.Config, required int64) (warnings []string, err error) {
+	status := CheckDir(cfg.OutputDir)
+	if !status.Writable {
+		return nil, fmt.Errorf("輸出目錄 %s 不可用: %v", status.Path, status.Err)
+	}
+	if cfg.SpaceCheck == config.SpaceCheckOff {
+		return nil, nil
+	}
+	if status.Err != nil {
+		// 無法獲取可用空間時不阻止下載
+		return []string{status.Err.Error()}, nil
+	}
+
+	need := uint64(required) + uint64(cfg.MinFreeSpace)
+	if status.Free >= need {
+		return nil, nil
+	}
+	msg := fmt.Sprintf("輸出目錄 %s 的可用空間不足: 剩餘 %s", status.Path, FormatBytes(status.Free))
+	if required > 0 {
+		msg += fmt.Sprintf("，預計需要 %s", FormatBytes(uint64(required)))
+	}
+	msg += fmt.Sprintf("，並保留 %s (min_free_space)", FormatBytes(uint64(cfg.MinFreeSpace)))
+
+	if cfg.SpaceCheck == config.SpaceCheckWarn {
+		return []string{msg}, nil
+	}
+	return nil, fmt.Errorf("%s，請清理空間，或設置 space_check = warn 繼續下載", msg)
+}
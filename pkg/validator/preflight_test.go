@@ -0,0 +1,139 @@
+package validator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(cfg *config.Config)
+		fields   map[string]interface{}
+		expected int64
+	}{
+		{
+			name:     "mp3 320k",
+			fields:   map[string]interface{}{"filesize_approx": float64(4 << 20)},
+			expected: 4<<20 + 10<<20,
+		},
+		{
+			name:     "exact filesize preferred",
+			fields:   map[string]interface{}{"filesize": float64(2 << 20), "filesize_approx": float64(4 << 20)},
+			expected: 2<<20 + 5<<20,
+		},
+		{
+			name:     "vbr",
+			modify:   func(cfg *config.Config) { cfg.Bitrate = "" },
+			fields:   map[string]interface{}{"filesize_approx": float64(1 << 20)},
+			expected: 3 << 20,
+		},
+		{
+			name:     "best is not converted",
+			modify:   func(cfg *config.Config) { cfg.AudioFormat = "best" },
+			fields:   map[string]interface{}{"filesize_approx": float64(1 << 20)},
+			expected: 2 << 20,
+		},
+		{
+			name: "multiple outputs",
+			modify: func(cfg *config.Config) {
+				cfg.Outputs, _ = config.ParseOutputs("mp3-320;opus-96")
+			},
+			fields:   map[string]interface{}{"filesize_approx": float64(1 << 20)},
+			expected: 1<<20 + 2.5*(1<<20) + 0.75*(1<<20),
+		},
+		{
+			name:     "unknown size",
+			fields:   map[string]interface{}{"filesize_approx": nil},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			if got := EstimateSize(cfg, tt.fields); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConversionFactor(t *testing.T) {
+	if f := ConversionFactor(config.Preset{AudioFormat: "wav"}); f < 10 {
+		t.Errorf("Expected wav to be much larger than the source, got %v", f)
+	}
+	if f := ConversionFactor(config.Preset{AudioFormat: "opus", Bitrate: "64k"}); f != 0.5 {
+		t.Errorf("Expected 0.5, got %v", f)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("enough space", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(filepath.Join(dir, "music"))
+		cfg.MinFreeSpace = 0
+		warnings, err := Preflight(cfg, 1024)
+		if err != nil || len(warnings) != 0 {
+			t.Errorf("Expected no problems, got %v, %v", warnings, err)
+		}
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(dir)
+		cfg.MinFreeSpace = math.MaxInt64 / 2
+		_, err := Preflight(cfg, 10<<20)
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		for _, want := range []string{"可用空間不足", "預計需要 10.0 MB", "min_free_space", "space_check = warn"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected %q in error, got: %v", want, err)
+			}
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(dir)
+		cfg.MinFreeSpace = math.MaxInt64 / 2
+		cfg.SpaceCheck = config.SpaceCheckWarn
+		warnings, err := Preflight(cfg, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(warnings) != 1 || strings.Contains(warnings[0], "預計需要") {
+			t.Errorf("Expected one warning without estimate, got: %v", warnings)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		cfg := config.NewConfig().WithOutputDir(dir)
+		cfg.MinFreeSpace = math.MaxInt64 / 2
+		cfg.SpaceCheck = config.SpaceCheckOff
+		if warnings, err := Preflight(cfg, 0); err != nil || len(warnings) != 0 {
+			t.Errorf("Expected no problems, got %v, %v", warnings, err)
+		}
+	})
+
+	t.Run("output dir is a file", func(t *testing.T) {
+		file := filepath.Join(dir, "file.txt")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := config.NewConfig().WithOutputDir(filepath.Join(file, "music"))
+		cfg.SpaceCheck = config.SpaceCheckOff
+		_, err := Preflight(cfg, 0)
+		if err == nil || !strings.Contains(err.Error(), "不可用") {
+			t.Errorf("Expected unusable output dir error, got: %v", err)
+		}
+	})
+}
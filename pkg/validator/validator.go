@@ -1,18 +1,79 @@
 package validator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
 )
 
 // CommandChecker 定義檢查命令的接口
 type CommandChecker interface {
 	CheckCommand(name string) error
+	// Inspect 解析命令的完整路徑並運行版本命令，命令無法運行時返回錯誤
+	Inspect(name string) (CommandInfo, error)
+	// Output 運行命令並返回標準輸出
+	Output(name string, args ...string) (string, error)
+}
+
+// CommandInfo 外部命令的路徑和版本
+type CommandInfo struct {
+	Name    string
+	Path    string // 解析出的完整路徑
+	Source  string // 路徑的來源，見 Locator.Find
+	Version string // 解析出的版本號，例如 2024.08.06 或 6.1.1，無法解析時為空
+	Output  string // 版本命令輸出的第一行
+}
+
+// String 返回 "名稱 版本 (路徑)" 形式的描述
+func (i CommandInfo) String() string {
+	version := i.Version
+	if version == "" {
+		version = "版本未知"
+	}
+	if i.Source == "" {
+		return fmt.Sprintf("%s %s (%s)", i.Name, version, i.Path)
+	}
+	return fmt.Sprintf("%s %s (%s，來自 %s)", i.Name, version, i.Path, i.Source)
+}
+
+// dependency 程序需要的外部命令及安裝和升級方法
+type dependency struct {
+	name    string
+	install string
+	upgrade string
+}
+
+// dependencies 已知的外部命令，ffprobe 隨 ffmpeg 一起安裝，yt-dlp 提取音頻時需要
+var dependencies = map[string]dependency{
+	config.YtDlpCommand: {
+		name:    config.YtDlpCommand,
+		install: "pip install yt-dlp 或 brew install yt-dlp",
+		upgrade: "yt-dlp -U、pip install -U yt-dlp 或 brew upgrade yt-dlp",
+	},
+	config.FFmpegCommand: {
+		name:    config.FFmpegCommand,
+		install: "sudo apt install ffmpeg 或 brew install ffmpeg",
+		upgrade: "從 https://ffmpeg.org/download.html 下載新版本或 brew upgrade ffmpeg",
+	},
+	config.FFprobeCommand: {
+		name:    config.FFprobeCommand,
+		install: "sudo apt install ffmpeg 或 brew install ffmpeg（ffprobe 隨 ffmpeg 一起安裝）",
+		upgrade: "從 https://ffmpeg.org/download.html 下載新版本或 brew upgrade ffmpeg",
+	},
 }
 
 // SystemValidator 系統依賴驗證器
 type SystemValidator struct {
-	checker CommandChecker
+	checker     CommandChecker
+	minVersions map[string]string
 }
 
 // NewSystemValidator 創建新的系統驗證器
@@ -21,28 +82,94 @@ func NewSystemValidator(checker CommandChecker) *SystemValidator {
 		checker = &DefaultCommandChecker{}
 	}
 	return &SystemValidator{
-		checker: checker,
+		checker:     checker,
+		minVersions: make(map[string]string),
 	}
 }
 
-// ValidateDependencies 驗證所有必需的依賴
-func (v *SystemValidator) ValidateDependencies() error {
-	// 檢查 yt-dlp
-	if err := v.checker.CheckCommand("yt-dlp"); err != nil {
-		return fmt.Errorf("未找到 yt-dlp，請先安裝: pip install yt-dlp 或 brew install yt-dlp")
+// SetMinVersion 設置命令的最低版本，為空時不檢查版本
+func (v *SystemValidator) SetMinVersion(name, version string) error {
+	if version == "" {
+		delete(v.minVersions, name)
+		return nil
 	}
+	if _, err := parseVersion(version); err != nil {
+		return err
+	}
+	v.minVersions[name] = version
+	return nil
+}
+
+// Mode 運行模式，決定需要哪些外部命令
+type Mode int
+
+const (
+	ModeDownload Mode = iota // 下載遠程 URL，需要 yt-dlp 和 ffmpeg
+	ModeConvert              // 轉換本地文件，只需要 ffmpeg
+)
 
-	// 檢查 ffmpeg
-	if err := v.checker.CheckCommand("ffmpeg"); err != nil {
-		return fmt.Errorf("未找到 ffmpeg，請先安裝: sudo apt install ffmpeg 或 brew install ffmpeg")
+// ValidateDependencies 驗證所選模式需要的依賴
+func (v *SystemValidator) ValidateDependencies(mode Mode) error {
+	_, err := v.Check(mode)
+	return err
+}
+
+// Check 驗證所選模式需要的依賴，返回每個命令解析出的路徑和版本
+func (v *SystemValidator) Check(mode Mode) ([]CommandInfo, error) {
+	names := []string{config.FFmpegCommand}
+	// 只有下載遠程 URL 需要 yt-dlp
+	if mode == ModeDownload {
+		names = []string{config.YtDlpCommand, config.FFmpegCommand}
 	}
 
-	return nil
+	infos := make([]CommandInfo, 0, len(names))
+	for _, name := range names {
+		info, err := v.CheckTool(name)
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// CheckTool 確認命令存在、可以運行並且版本不低於最低版本，返回解析出的路徑和版本
+func (v *SystemValidator) CheckTool(name string) (CommandInfo, error) {
+	dep, ok := dependencies[name]
+	if !ok {
+		return CommandInfo{Name: name}, fmt.Errorf("未知的依賴: %s", name)
+	}
+	if err := v.checker.CheckCommand(dep.name); err != nil {
+		var invalid *InvalidPathError
+		if errors.As(err, &invalid) {
+			return CommandInfo{Name: name}, err
+		}
+		return CommandInfo{Name: name}, fmt.Errorf("未找到 %s，請先安裝: %s", dep.name, dep.install)
+	}
+	info, err := v.checker.Inspect(dep.name)
+	if err != nil {
+		return info, fmt.Errorf("無法運行 %s: %v", dep.name, err)
+	}
+
+	min := v.minVersions[dep.name]
+	if min == "" || info.Version == "" {
+		// 開發版等無法解析版本號時不阻止運行
+		return info, nil
+	}
+	cmp, err := CompareVersions(info.Version, min)
+	if err != nil {
+		return info, nil
+	}
+	if cmp < 0 {
+		return info, fmt.Errorf("%s 版本過舊: %s (%s)，需要 %s 或更高版本，請升級: %s",
+			dep.name, info.Version, info.Path, min, dep.upgrade)
+	}
+	return info, nil
 }
 
 // ValidateYtDlp 單獨驗證 yt-dlp
 func (v *SystemValidator) ValidateYtDlp() error {
-	if err := v.checker.CheckCommand("yt-dlp"); err != nil {
+	if err := v.checker.CheckCommand(config.YtDlpCommand); err != nil {
 		return fmt.Errorf("未找到 yt-dlp")
 	}
 	return nil
@@ -50,17 +177,156 @@ func (v *SystemValidator) ValidateYtDlp() error {
 
 // ValidateFFmpeg 單獨驗證 ffmpeg
 func (v *SystemValidator) ValidateFFmpeg() error {
-	if err := v.checker.CheckCommand("ffmpeg"); err != nil {
+	if err := v.checker.CheckCommand(config.FFmpegCommand); err != nil {
 		return fmt.Errorf("未找到 ffmpeg")
 	}
 	return nil
 }
 
+// versionArgs 輸出版本的參數，ffmpeg 系列只接受單橫線
+var versionArgs = map[string]string{
+	config.FFmpegCommand:  "-version",
+	config.FFprobeCommand: "-version",
+}
+
+// commandTimeout 運行版本等查詢命令的超時時間
+const commandTimeout = 10 * time.Second
+
 // DefaultCommandChecker 默認的命令檢查器
-type DefaultCommandChecker struct{}
+type DefaultCommandChecker struct {
+	Locator *Locator // 查找命令的順序，為 nil 時不使用配置中的路徑
+}
+
+// locator 返回使用的查找器
+func (c *DefaultCommandChecker) locator() *Locator {
+	if c.Locator == nil {
+		return &Locator{}
+	}
+	return c.Locator
+}
 
 // CheckCommand 檢查命令是否存在
 func (c *DefaultCommandChecker) CheckCommand(name string) error {
-	_, err := exec.LookPath(name)
+	_, _, err := c.locator().Find(name)
 	return err
 }
+
+// Inspect 查找命令並運行 --version
+func (c *DefaultCommandChecker) Inspect(name string) (CommandInfo, error) {
+	info := CommandInfo{Name: name}
+	path, source, err := c.locator().Find(name)
+	if err != nil {
+		return info, err
+	}
+	info.Path = path
+	info.Source = source
+
+	arg, ok := versionArgs[name]
+	if !ok {
+		arg = "--version"
+	}
+	out, err := c.run(path, arg)
+	if err != nil {
+		return info, err
+	}
+	info.Output, info.Version = ParseVersionOutput(out)
+	return info, nil
+}
+
+// Output 查找命令後運行並返回標準輸出
+func (c *DefaultCommandChecker) Output(name string, args ...string) (string, error) {
+	path, _, err := c.locator().Find(name)
+	if err != nil {
+		return "", err
+	}
+	return c.run(path, args...)
+}
+
+// run 運行命令並返回標準輸出，失敗時錯誤中帶上標準錯誤的內容
+func (c *DefaultCommandChecker) run(path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// leadingVersionRe 匹配字符串開頭的點分隔版本號
+var leadingVersionRe = regexp.MustCompile(`^[nv]?(\d+(?:\.\d+)*)`)
+
+// ParseVersionOutput 從版本命令的輸出中取第一行和版本號
+// yt-dlp 輸出 "2024.08.06"，ffmpeg 輸出 "ffmpeg version 6.1.1-3ubuntu5 Copyright ..."，
+// ffmpeg 的 git 版本（例如 "N-113000-g..."）沒有版本號，返回空字符串
+func ParseVersionOutput(output string) (line, version string) {
+	line = strings.TrimSpace(output)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+
+	fields := strings.Fields(line)
+	token := ""
+	for i, f := range fields {
+		if f == "version" && i+1 < len(fields) {
+			token = fields[i+1]
+			break
+		}
+	}
+	if token == "" && len(fields) > 0 {
+		token = fields[0]
+	}
+	if m := leadingVersionRe.FindStringSubmatch(token); m != nil {
+		version = m[1]
+	}
+	return line, version
+}
+
+// parseVersion 把點分隔的版本號解析為整數列表
+func parseVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("版本號格式錯誤: %q", version)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// CompareVersions 逐段比較兩個點分隔的版本號，a 較舊時返回 -1，相同返回 0，較新返回 1
+// 缺少的段視為 0，因此 4.0 與 4 相同
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
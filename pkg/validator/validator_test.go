@@ -2,6 +2,9 @@ package validator
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -9,12 +12,21 @@ import (
 // MockCommandChecker 模擬命令檢查器
 type MockCommandChecker struct {
 	commands map[string]error
+	infos    map[string]inspectResult
+	outputs  map[string]string
+}
+
+type inspectResult struct {
+	info CommandInfo
+	err  error
 }
 
 // NewMockCommandChecker 創建新的模擬檢查器
 func NewMockCommandChecker() *MockCommandChecker {
 	return &MockCommandChecker{
 		commands: make(map[string]error),
+		infos:    make(map[string]inspectResult),
+		outputs:  make(map[string]string),
 	}
 }
 
@@ -31,6 +43,33 @@ func (m *MockCommandChecker) CheckCommand(name string) error {
 	return nil
 }
 
+// SetCommandInfo 設置命令的路徑、版本和運行結果
+func (m *MockCommandChecker) SetCommandInfo(name string, info CommandInfo, err error) {
+	m.infos[name] = inspectResult{info: info, err: err}
+}
+
+// Inspect 返回設置的命令信息，未設置時返回 /usr/bin 下未知版本的命令
+func (m *MockCommandChecker) Inspect(name string) (CommandInfo, error) {
+	if r, exists := m.infos[name]; exists {
+		return r.info, r.err
+	}
+	return CommandInfo{Name: name, Path: "/usr/bin/" + name}, nil
+}
+
+// SetOutput 設置命令的輸出
+func (m *MockCommandChecker) SetOutput(command, output string) {
+	m.outputs[command] = output
+}
+
+// Output 返回設置的輸出，鍵為命令和參數用空格連接，未設置時返回錯誤
+func (m *MockCommandChecker) Output(name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	if out, exists := m.outputs[command]; exists {
+		return out, nil
+	}
+	return "", errors.New("unexpected command: " + command)
+}
+
 func TestNewSystemValidator(t *testing.T) {
 	t.Run("with nil checker", func(t *testing.T) {
 		validator := NewSystemValidator(nil)
@@ -61,7 +100,7 @@ func TestValidateDependencies(t *testing.T) {
 		mock.SetCommandResult("ffmpeg", nil)
 
 		validator := NewSystemValidator(mock)
-		err := validator.ValidateDependencies()
+		err := validator.ValidateDependencies(ModeDownload)
 
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
@@ -74,7 +113,7 @@ func TestValidateDependencies(t *testing.T) {
 		mock.SetCommandResult("ffmpeg", nil)
 
 		validator := NewSystemValidator(mock)
-		err := validator.ValidateDependencies()
+		err := validator.ValidateDependencies(ModeDownload)
 
 		if err == nil {
 			t.Error("Expected error when yt-dlp is missing")
@@ -90,7 +129,7 @@ func TestValidateDependencies(t *testing.T) {
 		mock.SetCommandResult("ffmpeg", errors.New("not found"))
 
 		validator := NewSystemValidator(mock)
-		err := validator.ValidateDependencies()
+		err := validator.ValidateDependencies(ModeDownload)
 
 		if err == nil {
 			t.Error("Expected error when ffmpeg is missing")
@@ -106,7 +145,7 @@ func TestValidateDependencies(t *testing.T) {
 		mock.SetCommandResult("ffmpeg", errors.New("not found"))
 
 		validator := NewSystemValidator(mock)
-		err := validator.ValidateDependencies()
+		err := validator.ValidateDependencies(ModeDownload)
 
 		if err == nil {
 			t.Error("Expected error when all dependencies are missing")
@@ -114,6 +153,187 @@ func TestValidateDependencies(t *testing.T) {
 	})
 }
 
+func TestValidateDependenciesConvert(t *testing.T) {
+	t.Run("yt-dlp not required", func(t *testing.T) {
+		mock := NewMockCommandChecker()
+		mock.SetCommandResult("yt-dlp", errors.New("not found"))
+
+		validator := NewSystemValidator(mock)
+		if err := validator.ValidateDependencies(ModeConvert); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("ffmpeg missing", func(t *testing.T) {
+		mock := NewMockCommandChecker()
+		mock.SetCommandResult("ffmpeg", errors.New("not found"))
+
+		validator := NewSystemValidator(mock)
+		err := validator.ValidateDependencies(ModeConvert)
+		if err == nil || !strings.Contains(err.Error(), "ffmpeg") {
+			t.Errorf("Expected error message to mention ffmpeg, got: %v", err)
+		}
+	})
+}
+
+func TestValidateDependenciesVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		min     string
+		wantErr bool
+	}{
+		{"newer", "2024.08.06", "2023.03.04", false},
+		{"same", "2023.03.04", "2023.03.04", false},
+		{"nightly build", "2023.03.04.232712", "2023.03.04", false},
+		{"outdated", "2022.01.21", "2023.03.04", true},
+		{"unknown version", "", "2023.03.04", false},
+		{"no minimum", "2022.01.21", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockCommandChecker()
+			mock.SetCommandInfo("yt-dlp", CommandInfo{Name: "yt-dlp", Path: "/opt/bin/yt-dlp", Version: tt.version}, nil)
+
+			validator := NewSystemValidator(mock)
+			if err := validator.SetMinVersion("yt-dlp", tt.min); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			err := validator.ValidateDependencies(ModeDownload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+			if err != nil {
+				for _, want := range []string{tt.version, tt.min, "/opt/bin/yt-dlp", "yt-dlp -U"} {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Expected error message to mention %q, got: %v", want, err)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("ffmpeg outdated", func(t *testing.T) {
+		mock := NewMockCommandChecker()
+		mock.SetCommandInfo("ffmpeg", CommandInfo{Name: "ffmpeg", Path: "/usr/bin/ffmpeg", Version: "3.4.8"}, nil)
+
+		validator := NewSystemValidator(mock)
+		validator.SetMinVersion("ffmpeg", "4.0")
+		err := validator.ValidateDependencies(ModeConvert)
+		if err == nil || !strings.Contains(err.Error(), "ffmpeg 版本過舊: 3.4.8") {
+			t.Errorf("Expected outdated ffmpeg error, got: %v", err)
+		}
+	})
+
+	t.Run("version command fails", func(t *testing.T) {
+		mock := NewMockCommandChecker()
+		mock.SetCommandInfo("yt-dlp", CommandInfo{Name: "yt-dlp", Path: "/usr/bin/yt-dlp"}, errors.New("exit status 1"))
+
+		validator := NewSystemValidator(mock)
+		err := validator.ValidateDependencies(ModeDownload)
+		if err == nil || !strings.Contains(err.Error(), "無法運行 yt-dlp") {
+			t.Errorf("Expected run error, got: %v", err)
+		}
+	})
+
+	t.Run("invalid minimum", func(t *testing.T) {
+		validator := NewSystemValidator(NewMockCommandChecker())
+		if err := validator.SetMinVersion("yt-dlp", "latest"); err == nil {
+			t.Error("Expected error for invalid minimum version")
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	mock := NewMockCommandChecker()
+	mock.SetCommandInfo("yt-dlp", CommandInfo{Name: "yt-dlp", Path: "/opt/bin/yt-dlp", Version: "2024.08.06"}, nil)
+	mock.SetCommandInfo("ffmpeg", CommandInfo{Name: "ffmpeg", Path: "/usr/bin/ffmpeg", Version: "6.1.1"}, nil)
+	validator := NewSystemValidator(mock)
+
+	t.Run("download", func(t *testing.T) {
+		infos, err := validator.Check(ModeDownload)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(infos) != 2 || infos[0].Path != "/opt/bin/yt-dlp" || infos[1].Path != "/usr/bin/ffmpeg" {
+			t.Errorf("Expected yt-dlp and ffmpeg paths, got %v", infos)
+		}
+		if got := infos[0].String(); got != "yt-dlp 2024.08.06 (/opt/bin/yt-dlp)" {
+			t.Errorf("Expected description, got %q", got)
+		}
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		infos, err := validator.Check(ModeConvert)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(infos) != 1 || infos[0].Name != "ffmpeg" {
+			t.Errorf("Expected only ffmpeg, got %v", infos)
+		}
+	})
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		line    string
+		version string
+	}{
+		{"yt-dlp", "2024.08.06\n", "2024.08.06", "2024.08.06"},
+		{"yt-dlp nightly", "2024.08.06.232712\n", "2024.08.06.232712", "2024.08.06.232712"},
+		{"ffmpeg distro", "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n",
+			"ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers", "6.1.1"},
+		{"ffmpeg release tag", "ffmpeg version n7.0 Copyright (c) 2000-2024\n", "ffmpeg version n7.0 Copyright (c) 2000-2024", "7.0"},
+		{"ffmpeg git build", "ffmpeg version N-113000-g1234abcd Copyright\n", "ffmpeg version N-113000-g1234abcd Copyright", ""},
+		{"empty", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, version := ParseVersionOutput(tt.output)
+			if line != tt.line {
+				t.Errorf("Expected line %q, got %q", tt.line, line)
+			}
+			if version != tt.version {
+				t.Errorf("Expected version %q, got %q", tt.version, version)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2024.08.06", "2023.03.04", 1},
+		{"2023.03.04", "2023.03.04", 0},
+		{"2023.3.4", "2023.03.04", 0},
+		{"4.0", "4", 0},
+		{"4.4.2", "5.0", -1},
+		{"10.0", "9.1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			got, err := CompareVersions(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := CompareVersions("1.x", "1.0"); err == nil {
+		t.Error("Expected error for invalid version")
+	}
+}
+
 func TestValidateYtDlp(t *testing.T) {
 	t.Run("yt-dlp present", func(t *testing.T) {
 		mock := NewMockCommandChecker()
@@ -184,3 +404,33 @@ func TestDefaultCommandChecker(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultCommandCheckerInspect(t *testing.T) {
+	checker := &DefaultCommandChecker{}
+
+	t.Run("resolves path and version", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("shell script commands are not supported on windows")
+		}
+		dir := t.TempDir()
+		script := "#!/bin/sh\necho 2024.08.06\n"
+		if err := os.WriteFile(filepath.Join(dir, "fake-tool"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", dir)
+
+		info, err := checker.Inspect("fake-tool")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if info.Path != filepath.Join(dir, "fake-tool") || info.Version != "2024.08.06" {
+			t.Errorf("Expected resolved path and version, got %+v", info)
+		}
+	})
+
+	t.Run("missing command", func(t *testing.T) {
+		if _, err := checker.Inspect("this-command-definitely-does-not-exist-12345"); err == nil {
+			t.Error("Expected error for non-existing command")
+		}
+	})
+}
@@ -0,0 +1,50 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+)
+
+// TestConverter 轉換用 ffmpeg 生成的本地文件（需要安裝 ffmpeg，不需要 yt-dlp）
+func TestConverter(t *testing.T) {
+	requireFFmpeg(t)
+
+	input := t.TempDir()
+	if err := os.Mkdir(filepath.Join(input, "album"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	generate(t, filepath.Join(input, "intro.wav"), fmt.Sprintf(sineSource, "2"))
+	generate(t, filepath.Join(input, "album", "track.flac"), fmt.Sprintf(sineSource, "3"))
+
+	output := t.TempDir()
+	cfg := config.NewConfig().WithOutputDir(output)
+	result, err := downloader.NewConverter(cfg, nil).Download(input)
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		t.Fatalf("Expected all files to convert, got: %v", failed[0].Err)
+	}
+
+	expected := map[string]float64{
+		filepath.Join(output, "intro.mp3"):          2,
+		filepath.Join(output, "album", "track.mp3"): 3,
+	}
+	if len(result.Files) != len(expected) {
+		t.Fatalf("Expected %d files, got %v", len(expected), result.Files)
+	}
+	for path, duration := range expected {
+		if d := probeDuration(t, path); math.Abs(d-duration) > 0.1 {
+			t.Errorf("Expected %s to last %.0fs, got %.2fs", filepath.Base(path), duration, d)
+		}
+	}
+}
@@ -20,7 +20,7 @@ func TestEndToEndDownload(t *testing.T) {
 
 	// 檢查依賴
 	systemValidator := validator.NewSystemValidator(nil)
-	if err := systemValidator.ValidateDependencies(); err != nil {
+	if err := systemValidator.ValidateDependencies(validator.ModeDownload); err != nil {
 		t.Skipf("Skipping integration test: %v", err)
 	}
 
@@ -38,14 +38,15 @@ func TestEndToEndDownload(t *testing.T) {
 	testURL := "https://www.youtube.com/watch?v=aqz-KE-bpKQ" // Big Buck Bunny 60s test
 
 	// 下載
-	if err := dl.Download(testURL); err != nil {
+	result, err := dl.Download(testURL)
+	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
 
 	// 驗證文件是否被創建
-	files, err := dl.GetOutputFiles()
-	if err != nil {
-		t.Fatalf("Failed to get output files: %v", err)
+	files := result.Files
+	if result.VideoID != "aqz-KE-bpKQ" {
+		t.Errorf("Expected video ID aqz-KE-bpKQ, got: %s", result.VideoID)
 	}
 
 	if len(files) == 0 {
@@ -74,7 +75,7 @@ func TestValidatorIntegration(t *testing.T) {
 	systemValidator := validator.NewSystemValidator(nil)
 
 	t.Run("validate all dependencies", func(t *testing.T) {
-		err := systemValidator.ValidateDependencies()
+		err := systemValidator.ValidateDependencies(validator.ModeDownload)
 		if err != nil {
 			t.Logf("Dependencies not installed: %v", err)
 			t.Logf("To run full integration tests, install: yt-dlp and ffmpeg")
@@ -83,6 +84,48 @@ func TestValidatorIntegration(t *testing.T) {
 		}
 	})
 
+	t.Run("resolve versions", func(t *testing.T) {
+		cfg := config.NewConfig()
+		systemValidator := validator.NewSystemValidator(nil)
+		systemValidator.SetMinVersion("yt-dlp", cfg.MinYtDlpVersion)
+		systemValidator.SetMinVersion("ffmpeg", cfg.MinFFmpegVersion)
+
+		infos, err := systemValidator.Check(validator.ModeDownload)
+		for _, info := range infos {
+			t.Logf("Found %s: %s", info, info.Output)
+		}
+		if err != nil {
+			t.Logf("Dependencies not usable: %v", err)
+		}
+	})
+
+	t.Run("ffmpeg capabilities", func(t *testing.T) {
+		requireFFmpeg(t)
+		checker := &validator.DefaultCommandChecker{}
+		encoders, err := checker.Output("ffmpeg", "-hide_banner", "-encoders")
+		if err != nil {
+			t.Fatalf("Failed to list encoders: %v", err)
+		}
+		filters, err := checker.Output("ffmpeg", "-hide_banner", "-filters")
+		if err != nil {
+			t.Fatalf("Failed to list filters: %v", err)
+		}
+		// 內置的編碼器和濾鏡在任何 ffmpeg 構建中都存在
+		if !validator.ParseEncoders(encoders)["flac"] {
+			t.Error("Expected flac encoder to be listed")
+		}
+		if !validator.ParseFilters(filters)["atrim"] {
+			t.Error("Expected atrim filter to be listed")
+		}
+
+		cfg := config.NewConfig()
+		cfg.Loudness = "loudnorm"
+		cfg.TrimSilence = true
+		if err := systemValidator.ValidateCapabilities(cfg); err != nil {
+			t.Logf("Installed ffmpeg lacks capabilities: %v", err)
+		}
+	})
+
 	t.Run("validate yt-dlp", func(t *testing.T) {
 		err := systemValidator.ValidateYtDlp()
 		if err != nil {
@@ -0,0 +1,182 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"youtube_to_mp3/pkg/config"
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/postprocess"
+)
+
+// 靜音和 440Hz 正弦波片段，用於生成測試音頻
+const (
+	silenceSource = "aevalsrc=0:d=%s:s=44100"
+	sineSource    = "sine=frequency=440:sample_rate=44100:duration=%s"
+)
+
+var meanVolumeRe = regexp.MustCompile(`mean_volume: (-?[\d.]+|-inf) dB`)
+
+// requireFFmpeg 沒有安裝 ffmpeg 和 ffprobe 時跳過測試
+func requireFFmpeg(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("Skipping integration test: %s not found", name)
+		}
+	}
+}
+
+// generate 把依次拼接的片段寫入 path，每個片段為 lavfi 音頻源
+func generate(t *testing.T, path string, sources ...string) {
+	t.Helper()
+	var graph strings.Builder
+	for i, src := range sources {
+		graph.WriteString(src + "[s" + strconv.Itoa(i) + "];")
+	}
+	for i := range sources {
+		graph.WriteString("[s" + strconv.Itoa(i) + "]")
+	}
+	graph.WriteString("concat=n=" + strconv.Itoa(len(sources)) + ":v=0:a=1")
+
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-nostdin", "-loglevel", "error", "-y",
+		"-filter_complex", graph.String(), path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to generate %s: %v %s", filepath.Base(path), err, out)
+	}
+}
+
+// seconds 把秒數格式化為 lavfi 參數
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
+
+// probeDuration 返回文件的時長（秒）
+func probeDuration(t *testing.T, path string) float64 {
+	t.Helper()
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		t.Fatalf("ffprobe failed: %v", err)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		t.Fatalf("Failed to parse duration %q: %v", out, err)
+	}
+	return d
+}
+
+// meanVolume 返回從 start 開始 length 秒內的平均音量（dB）
+func meanVolume(t *testing.T, path string, start, length float64) float64 {
+	t.Helper()
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin", "-ss", seconds(start), "-t", seconds(length), "-i", path,
+		"-af", "volumedetect", "-f", "null", "-")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("volumedetect failed: %v %s", err, stderr.String())
+	}
+	m := meanVolumeRe.FindStringSubmatch(stderr.String())
+	if m == nil {
+		t.Fatalf("volumedetect printed no mean volume: %s", stderr.String())
+	}
+	if m[1] == "-inf" {
+		return math.Inf(-1)
+	}
+	v, _ := strconv.ParseFloat(m[1], 64)
+	return v
+}
+
+// TestTrimmer 用生成的正弦波和靜音測試修剪、淡入淡出和時長限制（需要安裝 ffmpeg）
+func TestTrimmer(t *testing.T) {
+	requireFFmpeg(t)
+
+	tests := []struct {
+		name     string
+		sources  []string
+		modify   func(cfg *config.Config)
+		duration float64
+		check    func(t *testing.T, path string)
+	}{
+		{
+			name:     "trim leading and trailing silence",
+			sources:  []string{"silence:2", "sine:3", "silence:2"},
+			modify:   func(cfg *config.Config) { cfg.TrimSilence = true },
+			duration: 3,
+			check: func(t *testing.T, path string) {
+				if v := meanVolume(t, path, 0, 0.2); v < -30 {
+					t.Errorf("Expected tone at the start, got %.1f dB", v)
+				}
+			},
+		},
+		{
+			name:     "short silence is kept",
+			sources:  []string{"silence:0.3", "sine:3"},
+			modify:   func(cfg *config.Config) { cfg.TrimSilence = true; cfg.SilenceDuration = time.Second },
+			duration: 3.3,
+		},
+		{
+			name:     "fade in and out",
+			sources:  []string{"sine:4"},
+			modify:   func(cfg *config.Config) { cfg.FadeIn = time.Second; cfg.FadeOut = time.Second },
+			duration: 4,
+			check: func(t *testing.T, path string) {
+				full := meanVolume(t, path, 1.5, 1)
+				if v := meanVolume(t, path, 0, 0.1); v > full-15 {
+					t.Errorf("Expected fade in, got %.1f dB against %.1f dB", v, full)
+				}
+				if v := meanVolume(t, path, 3.9, 0.1); v > full-15 {
+					t.Errorf("Expected fade out, got %.1f dB against %.1f dB", v, full)
+				}
+			},
+		},
+		{
+			name:     "max duration",
+			sources:  []string{"sine:3"},
+			modify:   func(cfg *config.Config) { cfg.MaxDuration = 1500 * time.Millisecond },
+			duration: 1.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []string
+			for _, s := range tt.sources {
+				kind, length, _ := strings.Cut(s, ":")
+				if kind == "silence" {
+					sources = append(sources, fmt.Sprintf(silenceSource, length))
+				} else {
+					sources = append(sources, fmt.Sprintf(sineSource, length))
+				}
+			}
+			path := filepath.Join(t.TempDir(), "Fixture.flac")
+			generate(t, path, sources...)
+
+			cfg := config.NewConfig()
+			cfg.AudioFormat = "best"
+			tt.modify(cfg)
+			result := &downloader.Result{Files: []string{path}}
+			if err := postprocess.NewTrimmer(cfg, nil).Process(context.Background(), result); err != nil {
+				t.Fatalf("Trimming failed: %v", err)
+			}
+
+			if d := probeDuration(t, path); math.Abs(d-tt.duration) > 0.1 {
+				t.Errorf("Expected duration %.2fs, got %.2fs", tt.duration, d)
+			}
+			if tt.check != nil {
+				tt.check(t, path)
+			}
+		})
+	}
+}
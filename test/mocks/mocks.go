@@ -1,19 +1,31 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"io"
+	"strings"
+	"sync"
+
+	"youtube_to_mp3/pkg/downloader"
+	"youtube_to_mp3/pkg/validator"
 )
 
 // CommandChecker 模擬命令檢查器
 type CommandChecker struct {
 	commands map[string]error
+	infos    map[string]validator.CommandInfo
+	errs     map[string]error
+	outputs  map[string]string
 }
 
 // NewCommandChecker 創建新的模擬檢查器
 func NewCommandChecker() *CommandChecker {
 	return &CommandChecker{
 		commands: make(map[string]error),
+		infos:    make(map[string]validator.CommandInfo),
+		errs:     make(map[string]error),
+		outputs:  make(map[string]string),
 	}
 }
 
@@ -30,46 +42,98 @@ func (m *CommandChecker) CheckCommand(name string) error {
 	return nil
 }
 
+// SetCommandInfo 設置命令的路徑、版本和運行結果
+func (m *CommandChecker) SetCommandInfo(name string, info validator.CommandInfo, err error) {
+	m.infos[name] = info
+	m.errs[name] = err
+}
+
+// Inspect 返回設置的命令信息（模擬實現），未設置時返回 /usr/bin 下未知版本的命令
+func (m *CommandChecker) Inspect(name string) (validator.CommandInfo, error) {
+	if info, exists := m.infos[name]; exists {
+		return info, m.errs[name]
+	}
+	return validator.CommandInfo{Name: name, Path: "/usr/bin/" + name}, nil
+}
+
+// SetOutput 設置命令的輸出，command 為命令和參數用空格連接，例如 "ffmpeg -hide_banner -encoders"
+func (m *CommandChecker) SetOutput(command, output string) {
+	m.outputs[command] = output
+}
+
+// Output 返回設置的輸出（模擬實現），未設置時返回錯誤
+func (m *CommandChecker) Output(name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	if out, exists := m.outputs[command]; exists {
+		return out, nil
+	}
+	return "", errors.New("unexpected command: " + command)
+}
+
 // CommandExecutor 模擬命令執行器
 type CommandExecutor struct {
-	ExecuteFunc func(name string, args []string, stdout, stderr io.Writer) error
-	LastCommand string
-	LastArgs    []string
-	CallCount   int
+	ExecuteFunc        func(name string, args []string, stdout, stderr io.Writer) error
+	ExecuteContextFunc func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+	LastCommand        string
+	LastArgs           []string
+	CallCount          int
 }
 
 // Execute 執行命令（模擬實現）
 func (m *CommandExecutor) Execute(name string, args []string, stdout, stderr io.Writer) error {
+	return m.ExecuteContext(context.Background(), name, args, stdout, stderr)
+}
+
+// ExecuteContext 執行命令（模擬實現），ctx 已取消時直接返回 ctx.Err()
+func (m *CommandExecutor) ExecuteContext(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
 	m.LastCommand = name
 	m.LastArgs = args
 	m.CallCount++
 
+	if m.ExecuteContextFunc != nil {
+		return m.ExecuteContextFunc(ctx, name, args, stdout, stderr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if m.ExecuteFunc != nil {
 		return m.ExecuteFunc(name, args, stdout, stderr)
 	}
 	return nil
 }
 
-// Downloader 模擬下載器
+// Downloader 模擬下載器，可在多個 goroutine 中共用
 type Downloader struct {
-	DownloadFunc    func(url string) error
+	mu              sync.Mutex
+	DownloadFunc    func(url string) (*downloader.Result, error)
 	GetOutputFunc   func() ([]string, error)
 	DownloadedURLs  []string
 	ShouldFailOnURL string
 }
 
 // Download 模擬下載
-func (m *Downloader) Download(url string) error {
+func (m *Downloader) Download(url string) (*downloader.Result, error) {
+	return m.DownloadContext(context.Background(), url)
+}
+
+// DownloadContext 模擬下載，ctx 已取消時直接返回 ctx.Err()
+func (m *Downloader) DownloadContext(ctx context.Context, url string) (*downloader.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
 	m.DownloadedURLs = append(m.DownloadedURLs, url)
+	m.mu.Unlock()
 
 	if m.ShouldFailOnURL != "" && url == m.ShouldFailOnURL {
-		return errors.New("mock download error")
+		return nil, errors.New("mock download error")
 	}
 
 	if m.DownloadFunc != nil {
 		return m.DownloadFunc(url)
 	}
-	return nil
+	return &downloader.Result{URL: url}, nil
 }
 
 // GetOutputFiles 模擬獲取輸出文件
@@ -79,3 +143,28 @@ func (m *Downloader) GetOutputFiles() ([]string, error) {
 	}
 	return []string{}, nil
 }
+
+// URLs 返回已下載的 URL（並發安全）
+func (m *Downloader) URLs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.DownloadedURLs...)
+}
+
+// Fetcher 模擬縮略圖下載
+type Fetcher struct {
+	FetchFunc func(url string) ([]byte, error)
+	LastURL   string
+}
+
+// Fetch 下載（模擬實現）
+func (m *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	m.LastURL = url
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.FetchFunc != nil {
+		return m.FetchFunc(url)
+	}
+	return nil, errors.New("no thumbnail")
+}